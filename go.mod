@@ -1,10 +1,10 @@
 module github.com/TelephoneTan/GoHTTPRequest
 
-go 1.20
+go 1.21
 
 require (
 	github.com/TelephoneTan/GoPromise v0.1.0
 	golang.org/x/net v0.8.0
 )
 
-require golang.org/x/text v0.8.0 // indirect
+require golang.org/x/text v0.8.0
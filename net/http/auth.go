@@ -0,0 +1,361 @@
+package http
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/TelephoneTan/GoHTTPRequest/net/http/header"
+	"github.com/TelephoneTan/GoHTTPRequest/util"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+type Authenticator interface {
+	Authorize(request *http.Request) error
+	Challenge(response *http.Response) bool
+}
+
+type BasicAuth struct {
+	Username string
+	Password string
+}
+
+func (b *BasicAuth) Authorize(request *http.Request) error {
+	request.Header.Set(header.Authorization, "Basic "+base64.StdEncoding.EncodeToString([]byte(b.Username+":"+b.Password)))
+	return nil
+}
+
+func (b *BasicAuth) Challenge(response *http.Response) bool {
+	return false
+}
+
+type BearerAuth struct {
+	Token string
+}
+
+func (b *BearerAuth) Authorize(request *http.Request) error {
+	request.Header.Set(header.Authorization, "Bearer "+b.Token)
+	return nil
+}
+
+func (b *BearerAuth) Challenge(response *http.Response) bool {
+	return false
+}
+
+type AuthChallenge struct {
+	Scheme string
+	Params map[string]string
+}
+
+func ParseAuthChallenges(headers []string) []AuthChallenge {
+	var challenges []AuthChallenge
+	for _, h := range headers {
+		s := h
+		var current *AuthChallenge
+		for {
+			s = strings.TrimLeft(s, " \t,")
+			if s == "" {
+				break
+			}
+			token := s
+			if i := strings.IndexAny(s, " \t,="); i >= 0 {
+				token = s[:i]
+			}
+			rest := strings.TrimLeft(s[len(token):], " \t")
+			if strings.HasPrefix(rest, "=") && current != nil && !strings.HasPrefix(rest, "==") {
+				value, remain := parseAuthParamValue(strings.TrimLeft(rest[1:], " \t"))
+				current.Params[strings.ToLower(token)] = value
+				s = remain
+				continue
+			}
+			if strings.HasPrefix(rest, "=") {
+				s = strings.TrimLeft(rest, "=")
+				if i := strings.IndexByte(s, ','); i >= 0 {
+					s = s[i:]
+				} else {
+					s = ""
+				}
+				continue
+			}
+			challenges = append(challenges, AuthChallenge{Scheme: token, Params: map[string]string{}})
+			current = &challenges[len(challenges)-1]
+			s = rest
+		}
+	}
+	return challenges
+}
+
+func parseAuthParamValue(s string) (value string, rest string) {
+	if strings.HasPrefix(s, `"`) {
+		sb := strings.Builder{}
+		for i := 1; i < len(s); i++ {
+			switch s[i] {
+			case '\\':
+				if i+1 < len(s) {
+					i++
+					sb.WriteByte(s[i])
+				}
+			case '"':
+				return sb.String(), s[i+1:]
+			default:
+				sb.WriteByte(s[i])
+			}
+		}
+		return sb.String(), ""
+	}
+	if i := strings.IndexAny(s, ", \t"); i >= 0 {
+		return s[:i], s[i:]
+	}
+	return s, ""
+}
+
+type digestChallenge struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	qop       []string
+	userhash  bool
+	nc        uint32
+}
+
+type _DigestAuth struct {
+	Username string
+	Password string
+	//
+	lock       sync.Mutex
+	challenges map[string]*digestChallenge
+}
+
+type DigestAuth = *_DigestAuth
+
+func NewDigestAuth(username, password string, init ...func(DigestAuth)) DigestAuth {
+	return util.New(&_DigestAuth{
+		Username:   username,
+		Password:   password,
+		challenges: map[string]*digestChallenge{},
+	}, init...)
+}
+
+var digestAlgorithmPreference = []string{"SHA-512-256", "SHA-256", "MD5"}
+
+func digestHash(algorithm string) func() hash.Hash {
+	switch strings.TrimSuffix(strings.ToUpper(algorithm), "-SESS") {
+	case "MD5", "":
+		return md5.New
+	case "SHA-256":
+		return sha256.New
+	case "SHA-512-256":
+		return sha512.New512_256
+	default:
+		return nil
+	}
+}
+
+func (d DigestAuth) Challenge(response *http.Response) bool {
+	var best *digestChallenge
+	bestRank := len(digestAlgorithmPreference)
+	stale := false
+	for _, c := range ParseAuthChallenges(response.Header.Values(header.WWWAuthenticate)) {
+		if !strings.EqualFold(c.Scheme, "Digest") || c.Params["nonce"] == "" {
+			continue
+		}
+		algorithm := c.Params["algorithm"]
+		if algorithm == "" {
+			algorithm = "MD5"
+		}
+		if digestHash(algorithm) == nil {
+			continue
+		}
+		rank := len(digestAlgorithmPreference)
+		for i, a := range digestAlgorithmPreference {
+			if strings.EqualFold(strings.TrimSuffix(strings.ToUpper(algorithm), "-SESS"), a) {
+				rank = i
+			}
+		}
+		if best == nil || rank < bestRank {
+			var qop []string
+			for _, q := range strings.Split(c.Params["qop"], ",") {
+				if q = strings.TrimSpace(q); q != "" {
+					qop = append(qop, q)
+				}
+			}
+			best = &digestChallenge{
+				realm:     c.Params["realm"],
+				nonce:     c.Params["nonce"],
+				opaque:    c.Params["opaque"],
+				algorithm: algorithm,
+				qop:       qop,
+				userhash:  strings.EqualFold(c.Params["userhash"], "true"),
+			}
+			bestRank = rank
+			stale = strings.EqualFold(c.Params["stale"], "true")
+		}
+	}
+	if best == nil {
+		return false
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	host := strings.ToLower(response.Request.URL.Host)
+	previous := d.challenges[host]
+	d.challenges[host] = best
+	return previous == nil || stale || previous.nonce != best.nonce
+}
+
+func (d DigestAuth) Authorize(request *http.Request) error {
+	return d.authorizeBody(request, request.GetBody)
+}
+
+func (d DigestAuth) authorizeBody(request *http.Request, getBody func() (io.ReadCloser, error)) error {
+	d.lock.Lock()
+	c := d.challenges[strings.ToLower(request.URL.Host)]
+	if c == nil {
+		d.lock.Unlock()
+		return nil
+	}
+	c.nc++
+	nc := fmt.Sprintf("%08x", c.nc)
+	challenge := *c
+	d.lock.Unlock()
+	newHash := digestHash(challenge.algorithm)
+	h := func(s string) string {
+		hh := newHash()
+		hh.Write([]byte(s))
+		return hex.EncodeToString(hh.Sum(nil))
+	}
+	cnonceBytes := make([]byte, 16)
+	if _, err := rand.Read(cnonceBytes); err != nil {
+		return err
+	}
+	cnonce := hex.EncodeToString(cnonceBytes)
+	qop := ""
+	for _, q := range challenge.qop {
+		if q == "auth-int" && qop == "" && (request.Body == nil || request.Body == http.NoBody || getBody != nil) {
+			qop = q
+		}
+		if q == "auth" {
+			qop = q
+		}
+	}
+	if qop == "" && len(challenge.qop) > 0 {
+		return errors.New("digest auth-int requires a rewindable request body")
+	}
+	uri := request.URL.RequestURI()
+	ha1 := h(d.Username + ":" + challenge.realm + ":" + d.Password)
+	if strings.HasSuffix(strings.ToUpper(challenge.algorithm), "-SESS") {
+		ha1 = h(ha1 + ":" + challenge.nonce + ":" + cnonce)
+	}
+	a2 := request.Method + ":" + uri
+	if qop == "auth-int" {
+		bodyHash := newHash()
+		if getBody != nil {
+			body, err := getBody()
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(bodyHash, body)
+			_ = body.Close()
+			if err != nil {
+				return err
+			}
+		}
+		a2 += ":" + hex.EncodeToString(bodyHash.Sum(nil))
+	}
+	var response string
+	if qop == "" {
+		response = h(ha1 + ":" + challenge.nonce + ":" + h(a2))
+	} else {
+		response = h(ha1 + ":" + challenge.nonce + ":" + nc + ":" + cnonce + ":" + qop + ":" + h(a2))
+	}
+	username := d.Username
+	if challenge.userhash {
+		username = h(d.Username + ":" + challenge.realm)
+	}
+	quote := func(s string) string {
+		return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+	}
+	params := []string{
+		"username=" + quote(username),
+		"realm=" + quote(challenge.realm),
+		"uri=" + quote(uri),
+		"algorithm=" + challenge.algorithm,
+		"nonce=" + quote(challenge.nonce),
+	}
+	if qop != "" {
+		params = append(params, "nc="+nc, "cnonce="+quote(cnonce), "qop="+qop)
+	}
+	params = append(params, "response="+quote(response))
+	if challenge.opaque != "" {
+		params = append(params, "opaque="+quote(challenge.opaque))
+	}
+	if challenge.userhash {
+		params = append(params, "userhash=true")
+	}
+	request.Header.Set(header.Authorization, "Digest "+strings.Join(params, ", "))
+	return nil
+}
+
+type bodyAuthorizer interface {
+	authorizeBody(request *http.Request, getBody func() (io.ReadCloser, error)) error
+}
+
+type authTransport struct {
+	base          http.RoundTripper
+	authenticator Authenticator
+	host          string
+	getBody       func() (io.ReadCloser, error)
+}
+
+func (t *authTransport) authorize(request *http.Request) (*http.Request, error) {
+	authorized := request.Clone(request.Context())
+	authorize := t.authenticator.Authorize
+	if authorizer, ok := t.authenticator.(bodyAuthorizer); ok {
+		authorize = func(request *http.Request) error {
+			return authorizer.authorizeBody(request, t.getBody)
+		}
+	}
+	if err := authorize(authorized); err != nil {
+		return nil, err
+	}
+	return authorized, nil
+}
+
+func (t *authTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if !strings.EqualFold(request.URL.Host, t.host) {
+		return t.base.RoundTrip(request)
+	}
+	authorized, err := t.authorize(request)
+	if err != nil {
+		return nil, err
+	}
+	response, err := t.base.RoundTrip(authorized)
+	if err != nil || response.StatusCode != http.StatusUnauthorized || !t.authenticator.Challenge(response) {
+		return response, err
+	}
+	retry := request.Clone(request.Context())
+	if request.Body != nil && request.Body != http.NoBody {
+		if request.GetBody == nil {
+			return response, nil
+		}
+		body, err := request.GetBody()
+		if err != nil {
+			return response, nil
+		}
+		retry.Body = body
+	}
+	if retry, err = t.authorize(retry); err != nil {
+		return response, nil
+	}
+	_, _ = io.Copy(io.Discard, response.Body)
+	_ = response.Body.Close()
+	return t.base.RoundTrip(retry)
+}
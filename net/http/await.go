@@ -0,0 +1,32 @@
+package http
+
+import (
+	"errors"
+	"github.com/TelephoneTan/GoPromise/async/promise"
+)
+
+var ErrCancelled = errors.New("promise cancelled")
+
+func await[T any](p promise.Promise[T]) (value T, err error) {
+	settled := false
+	promise.Catch[any](promise.Then(p, promise.FulfilledListener[T, any]{
+		OnFulfilled: func(v T) any {
+			value = v
+			settled = true
+			return nil
+		},
+	}), promise.RejectedListener[any]{
+		OnRejected: func(reason error) any {
+			err = reason
+			if err == nil {
+				err = errors.New("promise rejected")
+			}
+			settled = true
+			return nil
+		},
+	}).Await()
+	if !settled {
+		err = ErrCancelled
+	}
+	return value, err
+}
@@ -0,0 +1,162 @@
+package http
+
+import (
+	"context"
+	"github.com/TelephoneTan/GoHTTPRequest/util"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type _Bandwidth struct {
+	BytesPerSecond int64
+	Burst          int64
+	//
+	lock   sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+type Bandwidth = *_Bandwidth
+
+func NewBandwidth(bytesPerSecond int64, init ...func(Bandwidth)) Bandwidth {
+	return util.New(&_Bandwidth{BytesPerSecond: bytesPerSecond}, init...)
+}
+
+func (b Bandwidth) burst() int64 {
+	if b.Burst > 0 {
+		return b.Burst
+	}
+	if b.BytesPerSecond > 0 {
+		return b.BytesPerSecond
+	}
+	return 1
+}
+
+func (b Bandwidth) unlimited() bool {
+	return b == nil || b.BytesPerSecond <= 0
+}
+
+func (b Bandwidth) reserve(n int64) time.Duration {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	now := time.Now()
+	burst := float64(b.burst())
+	if b.last.IsZero() {
+		b.tokens = burst
+	} else {
+		b.tokens += now.Sub(b.last).Seconds() * float64(b.BytesPerSecond)
+		if b.tokens > burst {
+			b.tokens = burst
+		}
+	}
+	b.last = now
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / float64(b.BytesPerSecond) * float64(time.Second))
+}
+
+func (b Bandwidth) Wait(ctx context.Context, n int64) error {
+	if b.unlimited() || n <= 0 {
+		return nil
+	}
+	d := b.reserve(n)
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+var tagBandwidthMap = map[string][2]Bandwidth{}
+var tagBandwidthMapLock = sync.Mutex{}
+
+func SetTagBandwidth(tag string, upload, download Bandwidth) {
+	tagBandwidthMapLock.Lock()
+	defer tagBandwidthMapLock.Unlock()
+	if upload == nil && download == nil {
+		delete(tagBandwidthMap, tag)
+	} else {
+		tagBandwidthMap[tag] = [2]Bandwidth{upload, download}
+	}
+}
+
+func selectTagBandwidth(tag string) (upload, download Bandwidth) {
+	tagBandwidthMapLock.Lock()
+	defer tagBandwidthMapLock.Unlock()
+	bandwidth := tagBandwidthMap[tag]
+	return bandwidth[0], bandwidth[1]
+}
+
+type throttledReader struct {
+	reader    io.Reader
+	ctx       context.Context
+	bandwidth []Bandwidth
+	chunk     int
+}
+
+func newThrottledReader(ctx context.Context, reader io.Reader, bandwidth []Bandwidth) io.Reader {
+	t := &throttledReader{reader: reader, ctx: ctx}
+	for _, b := range bandwidth {
+		if !b.unlimited() {
+			t.bandwidth = append(t.bandwidth, b)
+			if burst := int(b.burst()); t.chunk == 0 || burst < t.chunk {
+				t.chunk = burst
+			}
+		}
+	}
+	if len(t.bandwidth) == 0 {
+		return reader
+	}
+	return t
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	if len(p) > t.chunk {
+		p = p[:t.chunk]
+	}
+	n, err := t.reader.Read(p)
+	for _, b := range t.bandwidth {
+		if werr := b.Wait(t.ctx, int64(n)); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+func (r Request) generateBandwidth() (upload, download []Bandwidth) {
+	if r.UploadRateLimit != nil && *r.UploadRateLimit > 0 {
+		upload = append(upload, NewBandwidth(*r.UploadRateLimit))
+	}
+	if r.DownloadRateLimit != nil && *r.DownloadRateLimit > 0 {
+		download = append(download, NewBandwidth(*r.DownloadRateLimit))
+	}
+	upload = append(upload, r.UploadBandwidth)
+	download = append(download, r.DownloadBandwidth)
+	if r.CookieJarTag != nil {
+		tagUpload, tagDownload := selectTagBandwidth(*r.CookieJarTag)
+		upload = append(upload, tagUpload)
+		download = append(download, tagDownload)
+	}
+	return upload, download
+}
+
+func (r Request) throttleUpload(request *http.Request, bandwidth []Bandwidth) {
+	ctx := request.Context()
+	wrapRequestBody(request, func(body io.Reader) io.Reader {
+		return newThrottledReader(ctx, body, bandwidth)
+	})
+}
+
+func (r Request) throttleDownload(reader io.Reader, bandwidth []Bandwidth) io.Reader {
+	return newThrottledReader(r.getContext().ctx, reader, bandwidth)
+}
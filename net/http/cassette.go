@@ -0,0 +1,319 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/TelephoneTan/GoHTTPRequest/util"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+type CassetteMode int
+
+const (
+	CassetteReplay CassetteMode = iota
+	CassetteRecord
+	CassetteRecordMissing
+	CassettePassthrough
+)
+
+const cassetteVersion = 1
+
+var ErrCassetteMissing = errors.New("cassette: no recorded interaction")
+
+type CassetteInteraction struct {
+	Method             string
+	URL                string
+	RequestHeaderList  [][]string
+	RequestBinary      Binary
+	StatusCode         int
+	StatusMessage      string
+	ResponseHeaderList [][]string
+	ResponseBinary     Binary `json:"responseBinary"`
+}
+
+type cassetteFile struct {
+	Version      int
+	Interactions []CassetteInteraction
+}
+
+type _Cassette struct {
+	Path          string
+	Mode          CassetteMode
+	Transport     http.RoundTripper
+	MatchHeaders  []string
+	MatchBody     bool
+	Matcher       func(request *http.Request, body []byte, interaction CassetteInteraction) bool
+	RedactHeaders []string
+	RedactFields  []string
+	//
+	lock         sync.Mutex
+	loaded       bool
+	interactions []CassetteInteraction
+	used         []bool
+}
+
+type Cassette = *_Cassette
+
+func NewCassette(path string, mode CassetteMode, init ...func(Cassette)) Cassette {
+	return util.New(&_Cassette{Path: path, Mode: mode}, init...)
+}
+
+func (c Cassette) load() error {
+	if c.loaded {
+		return nil
+	}
+	if c.Mode == CassetteRecord || c.Mode == CassettePassthrough {
+		c.loaded = true
+		return nil
+	}
+	bs, err := os.ReadFile(c.Path)
+	if errors.Is(err, os.ErrNotExist) && c.Mode == CassetteRecordMissing {
+		c.loaded = true
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	file := cassetteFile{}
+	if err := json.Unmarshal(bs, &file); err != nil {
+		return err
+	}
+	if file.Version != cassetteVersion {
+		return fmt.Errorf("cassette: unsupported version %d", file.Version)
+	}
+	c.interactions = file.Interactions
+	c.used = make([]bool, len(c.interactions))
+	c.loaded = true
+	return nil
+}
+
+func (c Cassette) Interactions() []CassetteInteraction {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	_ = c.load()
+	return append([]CassetteInteraction{}, c.interactions...)
+}
+
+func (c Cassette) save() error {
+	bs, err := json.MarshalIndent(cassetteFile{Version: cassetteVersion, Interactions: c.interactions}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.Path), 0o755); err != nil {
+		return err
+	}
+	temp := c.Path + ".tmp"
+	if err := os.WriteFile(temp, bs, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(temp, c.Path)
+}
+
+func (c Cassette) Save() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.save()
+}
+
+func (c Cassette) redactsHeader(name string) bool {
+	for _, field := range defaultRedactedFields {
+		if strings.EqualFold(field, name) {
+			return true
+		}
+	}
+	for _, field := range c.RedactHeaders {
+		if strings.EqualFold(field, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c Cassette) headerList(h http.Header) [][]string {
+	var list [][]string
+	for k, vs := range h {
+		for _, v := range vs {
+			if c.redactsHeader(k) {
+				v = redacted
+			}
+			list = append(list, []string{k, v})
+		}
+	}
+	return list
+}
+
+func (c Cassette) redactBody(bs []byte, contentType string) Binary {
+	if bs == nil {
+		return nil
+	}
+	if len(c.RedactFields) == 0 {
+		return bs
+	}
+	return Binary((&_RequestLogger{RedactFields: c.RedactFields}).body(bs, contentType, false))
+}
+
+func headerListValues(list [][]string, name string) []string {
+	var values []string
+	for _, kv := range list {
+		if len(kv) > 1 && strings.EqualFold(kv[0], name) {
+			values = append(values, kv[1])
+		}
+	}
+	return values
+}
+
+func (c Cassette) matches(request *http.Request, body []byte, interaction CassetteInteraction) bool {
+	if c.Matcher != nil {
+		return c.Matcher(request, body, interaction)
+	}
+	if !strings.EqualFold(request.Method, interaction.Method) || request.URL.String() != interaction.URL {
+		return false
+	}
+	for _, name := range c.MatchHeaders {
+		recorded := headerListValues(interaction.RequestHeaderList, name)
+		actual := headerListValues(c.headerList(request.Header), name)
+		if strings.Join(recorded, "\n") != strings.Join(actual, "\n") {
+			return false
+		}
+	}
+	if c.MatchBody && !bytes.Equal(c.redactBody(body, request.Header.Get("Content-Type")), interaction.RequestBinary) {
+		return false
+	}
+	return true
+}
+
+func (c Cassette) find(request *http.Request, body []byte) (CassetteInteraction, bool) {
+	fallback := -1
+	for i, interaction := range c.interactions {
+		if !c.matches(request, body, interaction) {
+			continue
+		}
+		if !c.used[i] {
+			c.used[i] = true
+			return interaction, true
+		}
+		fallback = i
+	}
+	if fallback >= 0 {
+		return c.interactions[fallback], true
+	}
+	return CassetteInteraction{}, false
+}
+
+func (c Cassette) replay(request *http.Request, interaction CassetteInteraction) *http.Response {
+	h := http.Header{}
+	for _, kv := range interaction.ResponseHeaderList {
+		if len(kv) > 1 {
+			h.Add(kv[0], kv[1])
+		}
+	}
+	return &http.Response{
+		Status:        interaction.StatusMessage,
+		StatusCode:    interaction.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        h,
+		Body:          io.NopCloser(bytes.NewReader(interaction.ResponseBinary)),
+		ContentLength: int64(len(interaction.ResponseBinary)),
+		Request:       request,
+	}
+}
+
+func (c Cassette) transport() http.RoundTripper {
+	if c.Transport != nil {
+		return c.Transport
+	}
+	return http.DefaultTransport
+}
+
+type recordingBody struct {
+	body     io.ReadCloser
+	captured bytes.Buffer
+	once     sync.Once
+	done     func(body []byte)
+}
+
+func (r *recordingBody) Read(p []byte) (int, error) {
+	n, err := r.body.Read(p)
+	r.captured.Write(p[:n])
+	if err == io.EOF {
+		r.once.Do(func() {
+			r.done(r.captured.Bytes())
+		})
+	}
+	return n, err
+}
+
+func (r *recordingBody) Close() error {
+	r.once.Do(func() {
+		_, _ = io.Copy(&r.captured, r.body)
+		r.done(r.captured.Bytes())
+	})
+	return r.body.Close()
+}
+
+func (c Cassette) RoundTrip(request *http.Request) (*http.Response, error) {
+	var body []byte
+	if request.Body != nil && request.Body != http.NoBody {
+		var err error
+		body, err = io.ReadAll(request.Body)
+		_ = request.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	c.lock.Lock()
+	if err := c.load(); err != nil {
+		c.lock.Unlock()
+		return nil, err
+	}
+	if c.Mode == CassetteReplay || c.Mode == CassetteRecordMissing {
+		interaction, found := c.find(request, body)
+		if found {
+			c.lock.Unlock()
+			return c.replay(request, interaction), nil
+		}
+		if c.Mode == CassetteReplay {
+			c.lock.Unlock()
+			return nil, fmt.Errorf("%w for %s %s", ErrCassetteMissing, request.Method, request.URL)
+		}
+	}
+	c.lock.Unlock()
+	outgoing := request.Clone(request.Context())
+	if body != nil {
+		outgoing.Body = io.NopCloser(bytes.NewReader(body))
+		outgoing.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+	response, err := c.transport().RoundTrip(outgoing)
+	if err != nil || c.Mode == CassettePassthrough {
+		return response, err
+	}
+	interaction := CassetteInteraction{
+		Method:             request.Method,
+		URL:                request.URL.String(),
+		RequestHeaderList:  c.headerList(request.Header),
+		RequestBinary:      c.redactBody(body, request.Header.Get("Content-Type")),
+		StatusCode:         response.StatusCode,
+		StatusMessage:      response.Status,
+		ResponseHeaderList: c.headerList(response.Header),
+	}
+	response.Body = &recordingBody{body: response.Body, done: func(bs []byte) {
+		interaction.ResponseBinary = c.redactBody(append([]byte{}, bs...), response.Header.Get("Content-Type"))
+		c.lock.Lock()
+		defer c.lock.Unlock()
+		c.interactions = append(c.interactions, interaction)
+		c.used = append(c.used, true)
+		_ = c.save()
+	}}
+	return response, nil
+}
@@ -0,0 +1,360 @@
+package http
+
+import (
+	"bytes"
+	"golang.org/x/net/html/charset"
+	stdmime "mime"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type CharsetSource string
+
+const (
+	CharsetFromBOM      CharsetSource = "bom"
+	CharsetFromHeader   CharsetSource = "header"
+	CharsetFromMeta     CharsetSource = "meta"
+	CharsetFromDetector CharsetSource = "detector"
+	CharsetFromDefault  CharsetSource = "default"
+)
+
+const (
+	charsetPrescanLength = 1024
+	charsetDetectLength  = 64 << 10
+)
+
+type DetectedCharset struct {
+	Name   string
+	Source CharsetSource
+}
+
+type CharsetDetector interface {
+	DetectCharset(content []byte) (name string, ok bool)
+}
+
+var charsetBOMs = []struct {
+	bom  []byte
+	name string
+}{
+	{[]byte{0xEF, 0xBB, 0xBF}, "utf-8"},
+	{[]byte{0xFE, 0xFF}, "utf-16be"},
+	{[]byte{0xFF, 0xFE}, "utf-16le"},
+}
+
+func lookupCharset(label string) (string, bool) {
+	e, name := charset.Lookup(label)
+	if e == nil {
+		return "", false
+	}
+	return name, true
+}
+
+func isHTMLContentType(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := stdmime.ParseMediaType(contentType)
+	if err != nil {
+		return true
+	}
+	return mediaType == "text/html" || mediaType == "application/xhtml+xml"
+}
+
+func SniffCharset(content []byte, contentType string, defaultCharset string, detector CharsetDetector) DetectedCharset {
+	for _, b := range charsetBOMs {
+		if bytes.HasPrefix(content, b.bom) {
+			return DetectedCharset{Name: b.name, Source: CharsetFromBOM}
+		}
+	}
+	if _, params, err := stdmime.ParseMediaType(contentType); err == nil {
+		if name, ok := lookupCharset(params["charset"]); ok {
+			return DetectedCharset{Name: name, Source: CharsetFromHeader}
+		}
+	}
+	if isHTMLContentType(contentType) {
+		if name, ok := prescanCharset(content); ok {
+			return DetectedCharset{Name: name, Source: CharsetFromMeta}
+		}
+	}
+	if detector != nil {
+		if label, ok := detector.DetectCharset(content); ok {
+			if name, ok := lookupCharset(label); ok {
+				return DetectedCharset{Name: name, Source: CharsetFromDetector}
+			}
+		}
+	}
+	if name, ok := lookupCharset(defaultCharset); ok {
+		return DetectedCharset{Name: name, Source: CharsetFromDefault}
+	}
+	if utf8.Valid(content) {
+		return DetectedCharset{Name: "utf-8", Source: CharsetFromDefault}
+	}
+	return DetectedCharset{Name: "windows-1252", Source: CharsetFromDefault}
+}
+
+func decodeCharset(content []byte, detected DetectedCharset) ([]byte, error) {
+	if detected.Source == CharsetFromBOM {
+		for _, b := range charsetBOMs {
+			if bytes.HasPrefix(content, b.bom) {
+				content = content[len(b.bom):]
+				break
+			}
+		}
+	}
+	e, _ := charset.Lookup(detected.Name)
+	if e == nil {
+		return content, nil
+	}
+	return e.NewDecoder().Bytes(content)
+}
+
+type charsetScanner struct {
+	s []byte
+	i int
+}
+
+func isCharsetSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\f' || c == '\r'
+}
+
+func (p *charsetScanner) hasPrefixFold(prefix string) bool {
+	return len(p.s)-p.i >= len(prefix) && strings.EqualFold(string(p.s[p.i:p.i+len(prefix)]), prefix)
+}
+
+func (p *charsetScanner) skipPast(marker string) {
+	if i := bytes.Index(p.s[p.i:], []byte(marker)); i >= 0 {
+		p.i += i + len(marker)
+	} else {
+		p.i = len(p.s)
+	}
+}
+
+func (p *charsetScanner) attribute() (name, value string, ok bool) {
+	for p.i < len(p.s) && (isCharsetSpace(p.s[p.i]) || p.s[p.i] == '/') {
+		p.i++
+	}
+	if p.i >= len(p.s) || p.s[p.i] == '>' {
+		return "", "", false
+	}
+	start := p.i
+	for p.i < len(p.s) {
+		c := p.s[p.i]
+		if (c == '=' && p.i > start) || isCharsetSpace(c) || c == '/' || c == '>' {
+			break
+		}
+		p.i++
+	}
+	name = strings.ToLower(string(p.s[start:p.i]))
+	for p.i < len(p.s) && isCharsetSpace(p.s[p.i]) {
+		p.i++
+	}
+	if p.i >= len(p.s) || p.s[p.i] != '=' {
+		return name, "", true
+	}
+	p.i++
+	for p.i < len(p.s) && isCharsetSpace(p.s[p.i]) {
+		p.i++
+	}
+	if p.i >= len(p.s) {
+		return name, "", true
+	}
+	if quote := p.s[p.i]; quote == '"' || quote == '\'' {
+		p.i++
+		start = p.i
+		for p.i < len(p.s) && p.s[p.i] != quote {
+			p.i++
+		}
+		value = string(p.s[start:p.i])
+		if p.i < len(p.s) {
+			p.i++
+		}
+		return name, strings.ToLower(value), true
+	}
+	start = p.i
+	for p.i < len(p.s) && !isCharsetSpace(p.s[p.i]) && p.s[p.i] != '>' {
+		p.i++
+	}
+	return name, strings.ToLower(string(p.s[start:p.i])), true
+}
+
+func charsetFromContent(content string) (string, bool) {
+	for {
+		i := strings.Index(content, "charset")
+		if i < 0 {
+			return "", false
+		}
+		content = strings.TrimLeft(content[i+len("charset"):], " \t\n\f\r")
+		if !strings.HasPrefix(content, "=") {
+			continue
+		}
+		content = strings.TrimLeft(content[1:], " \t\n\f\r")
+		if content == "" {
+			return "", false
+		}
+		if quote := content[0]; quote == '"' || quote == '\'' {
+			end := strings.IndexByte(content[1:], quote)
+			if end < 0 {
+				return "", false
+			}
+			return content[1 : end+1], true
+		}
+		end := strings.IndexAny(content, " \t\n\f\r;")
+		if end < 0 {
+			end = len(content)
+		}
+		return content[:end], true
+	}
+}
+
+func (p *charsetScanner) meta() (string, bool) {
+	gotPragma := false
+	needPragma := 0
+	charsetName := ""
+	seen := map[string]bool{}
+	for {
+		name, value, ok := p.attribute()
+		if !ok {
+			break
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		switch name {
+		case "http-equiv":
+			gotPragma = gotPragma || value == "content-type"
+		case "content":
+			if charsetName == "" {
+				if cs, found := charsetFromContent(value); found {
+					charsetName, needPragma = cs, 1
+				}
+			}
+		case "charset":
+			if charsetName == "" {
+				charsetName, needPragma = value, -1
+			}
+		}
+	}
+	if needPragma == 0 || needPragma == 1 && !gotPragma || charsetName == "" {
+		return "", false
+	}
+	name, ok := lookupCharset(charsetName)
+	if !ok {
+		return "", false
+	}
+	switch name {
+	case "utf-16be", "utf-16le":
+		name = "utf-8"
+	case "x-user-defined":
+		name = "windows-1252"
+	}
+	return name, true
+}
+
+func isASCIILetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func prescanCharset(content []byte) (string, bool) {
+	if len(content) > charsetPrescanLength {
+		content = content[:charsetPrescanLength]
+	}
+	p := &charsetScanner{s: content}
+	for p.i < len(p.s) {
+		switch {
+		case p.hasPrefixFold("<!--"):
+			p.i += len("<!--")
+			p.skipPast("-->")
+		case p.hasPrefixFold("<meta") && len(p.s) > p.i+5 && (isCharsetSpace(p.s[p.i+5]) || p.s[p.i+5] == '/'):
+			p.i += len("<meta")
+			if name, ok := p.meta(); ok {
+				return name, true
+			}
+		case p.s[p.i] == '<' && p.i+1 < len(p.s) && (isASCIILetter(p.s[p.i+1]) || p.s[p.i+1] == '/' && p.i+2 < len(p.s) && isASCIILetter(p.s[p.i+2])):
+			for p.i < len(p.s) && !isCharsetSpace(p.s[p.i]) && p.s[p.i] != '>' {
+				p.i++
+			}
+			for {
+				if _, _, ok := p.attribute(); !ok {
+					break
+				}
+			}
+		case p.hasPrefixFold("<!") || p.hasPrefixFold("</") || p.hasPrefixFold("<?"):
+			p.skipPast(">")
+		default:
+			p.i++
+		}
+	}
+	return "", false
+}
+
+type heuristicCharsetDetector struct {
+	candidates []string
+}
+
+var defaultDetectorCandidates = []string{"utf-8", "gbk", "big5", "shift_jis", "euc-jp", "euc-kr"}
+
+func NewHeuristicCharsetDetector(candidates ...string) CharsetDetector {
+	if len(candidates) == 0 {
+		candidates = defaultDetectorCandidates
+	}
+	return &heuristicCharsetDetector{candidates: candidates}
+}
+
+func charsetScore(name string, text string) float64 {
+	nonASCII, plausible, distinctive := 0, 0, 0
+	for _, r := range text {
+		if r < utf8.RuneSelf {
+			continue
+		}
+		nonASCII++
+		switch {
+		case unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r):
+			plausible++
+			if name == "shift_jis" || name == "euc-jp" {
+				distinctive++
+			}
+		case unicode.Is(unicode.Hangul, r):
+			plausible++
+			if name == "euc-kr" {
+				distinctive++
+			}
+		case unicode.Is(unicode.Han, r), 0x3000 <= r && r <= 0x303F, 0xFF00 <= r && r <= 0xFFEF:
+			plausible++
+		case unicode.IsLetter(r) || unicode.IsPunct(r) || unicode.IsSpace(r):
+			plausible++
+		}
+	}
+	if nonASCII == 0 {
+		return 0
+	}
+	return float64(plausible)/float64(nonASCII) + 0.5*float64(distinctive)/float64(nonASCII)
+}
+
+func (d *heuristicCharsetDetector) DetectCharset(content []byte) (string, bool) {
+	if len(content) > charsetDetectLength {
+		content = content[:charsetDetectLength]
+	}
+	best, bestScore := "", 0.0
+	for _, candidate := range d.candidates {
+		e, name := charset.Lookup(candidate)
+		if e == nil {
+			continue
+		}
+		if name == "utf-8" {
+			if valid := bytes.ToValidUTF8(content, nil); len(content)-len(valid) <= utf8.UTFMax && bytes.IndexFunc(content, func(r rune) bool { return r >= utf8.RuneSelf }) >= 0 {
+				return name, true
+			}
+			continue
+		}
+		decoded, err := e.NewDecoder().Bytes(content)
+		if err != nil || bytes.Count(decoded, []byte("�")) > 1 {
+			continue
+		}
+		if score := charsetScore(name, string(decoded)); score > bestScore {
+			best, bestScore = name, score
+		}
+	}
+	return best, bestScore >= 0.9
+}
@@ -6,6 +6,8 @@ import (
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
+	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -119,15 +121,66 @@ func (c CookieJar) SetCookiesManually(urlCookieList [][]string) {
 	}
 }
 
+type recordedCookie struct {
+	url    string
+	cookie http.Cookie
+}
+
 type _timeJar struct {
 	lastAccessSecond atomic.Int64
 	jar              http.CookieJar
+	lock             sync.Mutex
+	recorded         map[string]recordedCookie
 }
 type timeJar = *_timeJar
 
 func (t timeJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
-	t.lastAccessSecond.Store(time.Now().Unix())
+	now := time.Now()
+	t.lastAccessSecond.Store(now.Unix())
 	t.jar.SetCookies(u, cookies)
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.recorded == nil {
+		t.recorded = map[string]recordedCookie{}
+	}
+	for _, c := range cookies {
+		domain := strings.ToLower(strings.TrimPrefix(c.Domain, "."))
+		if domain == "" {
+			domain = strings.ToLower(u.Hostname())
+		}
+		key := domain + ";" + c.Path + ";" + c.Name
+		if c.MaxAge < 0 || (!c.Expires.IsZero() && !c.Expires.After(now)) {
+			delete(t.recorded, key)
+			continue
+		}
+		cookie := *c
+		if cookie.MaxAge > 0 {
+			cookie.Expires, cookie.MaxAge = now.Add(time.Duration(cookie.MaxAge)*time.Second), 0
+		}
+		cookie.Raw, cookie.RawExpires, cookie.Unparsed = "", "", nil
+		t.recorded[key] = recordedCookie{url: u.String(), cookie: cookie}
+	}
+}
+
+func (t timeJar) snapshot(u *url.URL) [][]string {
+	current := map[string]bool{}
+	for _, c := range t.Cookies(u) {
+		current[c.Name+"="+c.Value] = true
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	keys := make([]string, 0, len(t.recorded))
+	for key := range t.recorded {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var list [][]string
+	for _, key := range keys {
+		if r := t.recorded[key]; current[r.cookie.Name+"="+r.cookie.Value] {
+			list = append(list, []string{r.url, r.cookie.String()})
+		}
+	}
+	return list
 }
 
 func (t timeJar) Cookies(u *url.URL) []*http.Cookie {
@@ -157,6 +210,9 @@ func selectJar(tag string, clear bool) http.CookieJar {
 	jar.lastAccessSecond.Store(time.Now().Unix())
 	if jar.jar == nil || clear {
 		jar.jar, _ = cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+		jar.lock.Lock()
+		jar.recorded = nil
+		jar.lock.Unlock()
 	}
 	if len(jarMap) > 10_0000 {
 		cleanJarMap()
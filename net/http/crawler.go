@@ -0,0 +1,750 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/TelephoneTan/GoHTTPRequest/net/http/header"
+	"github.com/TelephoneTan/GoHTTPRequest/util"
+	"io"
+	stdmime "mime"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	crawlerStateVersion = 1
+	maxRobotsSize       = 500 << 10
+)
+
+var (
+	defaultCrawlerUserAgent       = "GoHTTPRequest-Crawler"
+	defaultCrawlerMaxDepth        = -1
+	defaultCrawlerMaxPages        = 0
+	defaultCrawlerMaxPagesPerHost = 0
+	defaultCrawlerConcurrency     = 4
+	defaultCrawlerDelay           = Duration(time.Second)
+	defaultCrawlerMaxCrawlDelay   = Duration(time.Minute)
+	defaultCrawlerRobotsTTL       = Duration(24 * time.Hour)
+	defaultCrawlerRobotsRetry     = Duration(time.Minute)
+	defaultCrawlerSaveInterval    = Duration(30 * time.Second)
+	defaultCrawlerStayOnSeedHosts = true
+	defaultCrawlerRespectRobots   = true
+	defaultCrawlerFollowSitemaps  = true
+	defaultCrawlerRespectNofollow = true
+	defaultCrawlerLinkSelector    = "a[href], area[href], frame[src], iframe[src]"
+	defaultCrawlerNormalize       = true
+	defaultCrawlerNormalization   = URLNormalization{
+		LowercaseHost:            &defaultCrawlerNormalize,
+		NormalizePercentEncoding: &defaultCrawlerNormalize,
+		RemoveDotSegments:        &defaultCrawlerNormalize,
+		StripDefaultPort:         &defaultCrawlerNormalize,
+		RemoveFragment:           &defaultCrawlerNormalize,
+	}
+)
+
+var (
+	ErrCrawlerRunning   = errors.New("crawler: already running")
+	ErrCrawlOutOfScope  = errors.New("crawler: url out of scope")
+	ErrRobotsDisallowed = errors.New("crawler: disallowed by robots.txt")
+	ErrCrawlStatus      = errors.New("crawler: unexpected status")
+)
+
+type CrawlTask struct {
+	URL      string
+	Depth    int
+	Referrer string
+	Sitemap  bool
+	Optional bool
+	Request  string
+}
+
+type _CrawlPage struct {
+	Task     CrawlTask
+	Request  Request
+	Document HTMLSelection
+	Links    []string
+}
+
+type CrawlPage = *_CrawlPage
+
+type CrawlHandler interface {
+	HandleCrawl(page CrawlPage) error
+}
+
+type CrawlHandlerFunc func(page CrawlPage) error
+
+func (f CrawlHandlerFunc) HandleCrawl(page CrawlPage) error {
+	return f(page)
+}
+
+type crawlHost struct {
+	robots        Robots
+	robotsExpires time.Time
+	busy          bool
+	next          time.Time
+	pages         int
+	delay         time.Duration
+}
+
+type crawlerState struct {
+	Version   int
+	Frontier  []CrawlTask
+	Seen      []string
+	SeedHosts []string
+	Pages     int
+	HostPages map[string]int
+}
+
+type _Crawler struct {
+	StateFile        string
+	UserAgent        *string
+	MaxDepth         *int
+	MaxPages         *int
+	MaxPagesPerHost  *int
+	Concurrency      *int
+	Delay            *Duration
+	MaxCrawlDelay    *Duration
+	RobotsTTL        *Duration
+	RobotsRetry      *Duration
+	SaveInterval     *Duration
+	AllowedHosts     []string
+	StayOnSeedHosts  *bool
+	Scope            func(u *url.URL) bool
+	RespectRobots    *bool
+	FollowSitemaps   *bool
+	RespectNofollow  *bool
+	LinkSelector     *string
+	URLNormalization *URLNormalization
+	Prepare          func(Request)
+	Handlers         []CrawlHandler
+	OnError          func(task CrawlTask, err error)
+	//
+	lock         sync.Mutex
+	loaded       bool
+	running      bool
+	paused       bool
+	wake         chan struct{}
+	linkSelector Selector
+	frontier     []CrawlTask
+	active       map[string]CrawlTask
+	seen         map[string]bool
+	seedHosts    map[string]bool
+	hosts        map[string]*crawlHost
+	pages        int
+}
+
+type Crawler = *_Crawler
+
+func NewCrawler(init ...func(Crawler)) Crawler {
+	return util.New(&_Crawler{}, init...)
+}
+
+func (c Crawler) generateDefaults() {
+	if c.UserAgent == nil {
+		c.UserAgent = &defaultCrawlerUserAgent
+	}
+	if c.MaxDepth == nil {
+		c.MaxDepth = &defaultCrawlerMaxDepth
+	}
+	if c.MaxPages == nil {
+		c.MaxPages = &defaultCrawlerMaxPages
+	}
+	if c.MaxPagesPerHost == nil {
+		c.MaxPagesPerHost = &defaultCrawlerMaxPagesPerHost
+	}
+	if c.Concurrency == nil || *c.Concurrency < 1 {
+		c.Concurrency = &defaultCrawlerConcurrency
+	}
+	if c.Delay == nil {
+		c.Delay = &defaultCrawlerDelay
+	}
+	if c.MaxCrawlDelay == nil {
+		c.MaxCrawlDelay = &defaultCrawlerMaxCrawlDelay
+	}
+	if c.RobotsTTL == nil {
+		c.RobotsTTL = &defaultCrawlerRobotsTTL
+	}
+	if c.RobotsRetry == nil {
+		c.RobotsRetry = &defaultCrawlerRobotsRetry
+	}
+	if c.SaveInterval == nil {
+		c.SaveInterval = &defaultCrawlerSaveInterval
+	}
+	if c.StayOnSeedHosts == nil {
+		c.StayOnSeedHosts = &defaultCrawlerStayOnSeedHosts
+	}
+	if c.RespectRobots == nil {
+		c.RespectRobots = &defaultCrawlerRespectRobots
+	}
+	if c.FollowSitemaps == nil {
+		c.FollowSitemaps = &defaultCrawlerFollowSitemaps
+	}
+	if c.RespectNofollow == nil {
+		c.RespectNofollow = &defaultCrawlerRespectNofollow
+	}
+	if c.LinkSelector == nil {
+		c.LinkSelector = &defaultCrawlerLinkSelector
+	}
+	if c.URLNormalization == nil {
+		c.URLNormalization = &defaultCrawlerNormalization
+	}
+}
+
+func (c Crawler) load() error {
+	if c.loaded {
+		return nil
+	}
+	c.generateDefaults()
+	selector, err := CompileSelector(*c.LinkSelector)
+	if err != nil {
+		return err
+	}
+	c.linkSelector = selector
+	c.seen = map[string]bool{}
+	c.seedHosts = map[string]bool{}
+	c.hosts = map[string]*crawlHost{}
+	c.active = map[string]CrawlTask{}
+	if c.StateFile != "" {
+		bs, err := os.ReadFile(c.StateFile)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+		case err != nil:
+			return err
+		default:
+			state := crawlerState{}
+			if err := json.Unmarshal(bs, &state); err != nil {
+				return fmt.Errorf("crawler: %s: %w", c.StateFile, err)
+			}
+			if state.Version > crawlerStateVersion {
+				return fmt.Errorf("%w: %d", ErrUnsupportedVersion, state.Version)
+			}
+			c.frontier = state.Frontier
+			for _, key := range state.Seen {
+				c.seen[key] = true
+			}
+			for _, host := range state.SeedHosts {
+				c.seedHosts[host] = true
+			}
+			c.pages = state.Pages
+			for key, pages := range state.HostPages {
+				c.host(key).pages = pages
+			}
+		}
+	}
+	c.loaded = true
+	return nil
+}
+
+func (c Crawler) save() error {
+	if c.StateFile == "" {
+		return nil
+	}
+	state := crawlerState{Version: crawlerStateVersion, Pages: c.pages, HostPages: map[string]int{}}
+	for key, host := range c.hosts {
+		state.HostPages[key] = host.pages
+	}
+	var active []CrawlTask
+	for _, task := range c.active {
+		active = append(active, task)
+		if u, err := url.Parse(task.URL); err == nil && !task.Sitemap {
+			state.Pages--
+			state.HostPages[crawlHostKey(u)]--
+		}
+	}
+	sort.Slice(active, func(i, j int) bool {
+		return active[i].URL < active[j].URL
+	})
+	state.Frontier = append(active, c.frontier...)
+	for key := range c.seen {
+		state.Seen = append(state.Seen, key)
+	}
+	sort.Strings(state.Seen)
+	for host := range c.seedHosts {
+		state.SeedHosts = append(state.SeedHosts, host)
+	}
+	sort.Strings(state.SeedHosts)
+	for key, pages := range state.HostPages {
+		if pages <= 0 {
+			delete(state.HostPages, key)
+		}
+	}
+	bs, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	temp := c.StateFile + ".tmp"
+	if err := os.WriteFile(temp, bs, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(temp, c.StateFile)
+}
+
+func (c Crawler) Save() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if err := c.load(); err != nil {
+		return err
+	}
+	return c.save()
+}
+
+func crawlHostKey(u *url.URL) string {
+	return u.Scheme + "://" + u.Host
+}
+
+func (c Crawler) host(key string) *crawlHost {
+	host, has := c.hosts[key]
+	if !has {
+		host = &crawlHost{}
+		c.hosts[key] = host
+	}
+	return host
+}
+
+func (c Crawler) inScope(u *url.URL) bool {
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return false
+	}
+	if len(c.AllowedHosts) > 0 || *c.StayOnSeedHosts {
+		hostname := strings.ToLower(u.Hostname())
+		allowed := *c.StayOnSeedHosts && c.seedHosts[hostname]
+		for _, host := range c.AllowedHosts {
+			host = strings.ToLower(host)
+			if hostname == host || strings.HasPrefix(host, ".") && strings.HasSuffix(hostname, host) {
+				allowed = true
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+	return c.Scope == nil || c.Scope(u)
+}
+
+func (c Crawler) enqueue(rawURL string, depth int, referrer string, sitemap bool, optional bool, init ...func(Request)) error {
+	normalized, err := NormalizeURL(rawURL, c.URLNormalization)
+	if err != nil {
+		return err
+	}
+	u, err := url.Parse(normalized)
+	if err != nil {
+		return err
+	}
+	if !c.inScope(u) {
+		return fmt.Errorf("%w: %s", ErrCrawlOutOfScope, normalized)
+	}
+	if !sitemap && *c.MaxDepth >= 0 && depth > *c.MaxDepth || c.seen[normalized] {
+		return nil
+	}
+	request := NewRequest(func(request Request) {
+		request.URL = normalized
+		if referrer != "" {
+			request.CustomizedHeaderList = append(request.CustomizedHeaderList, []string{header.Referer, referrer})
+		}
+	})
+	if len(init) > 0 {
+		init[0](request)
+	}
+	serialized, err := request.Serialize()
+	if err != nil {
+		return err
+	}
+	c.seen[normalized] = true
+	c.frontier = append(c.frontier, CrawlTask{URL: normalized, Depth: depth, Referrer: referrer, Sitemap: sitemap, Optional: optional, Request: serialized})
+	return nil
+}
+
+// Add queues rawURL with init applied to its serialized request. Fields that
+// are not serialized, such as Transport, CookieJar or Authenticator, are lost
+// from the frontier; set them per request in Prepare instead.
+func (c Crawler) Add(rawURL string, init ...func(Request)) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if err := c.load(); err != nil {
+		return err
+	}
+	if u, err := url.Parse(rawURL); err == nil && u.Hostname() != "" {
+		if normalized, err := NormalizeURL(rawURL, c.URLNormalization); err == nil {
+			if u, err := url.Parse(normalized); err == nil {
+				c.seedHosts[strings.ToLower(u.Hostname())] = true
+			}
+		}
+	}
+	err := c.enqueue(rawURL, 0, "", false, false, init...)
+	c.notify()
+	return err
+}
+
+func (c Crawler) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return len(c.frontier)
+}
+
+func (c Crawler) Pages() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.pages
+}
+
+func (c Crawler) notify() {
+	if c.wake != nil {
+		select {
+		case c.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (c Crawler) Pause() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.running {
+		c.paused = true
+		c.notify()
+	}
+}
+
+func (c Crawler) next(now time.Time) (CrawlTask, *crawlHost, bool, time.Duration) {
+	wait := time.Duration(-1)
+	for i := 0; i < len(c.frontier); i++ {
+		task := c.frontier[i]
+		if !task.Sitemap && *c.MaxPages > 0 && c.pages >= *c.MaxPages {
+			continue
+		}
+		u, err := url.Parse(task.URL)
+		if err != nil {
+			c.frontier = append(c.frontier[:i], c.frontier[i+1:]...)
+			i--
+			continue
+		}
+		host := c.host(crawlHostKey(u))
+		if !task.Sitemap && *c.MaxPagesPerHost > 0 && host.pages >= *c.MaxPagesPerHost {
+			c.frontier = append(c.frontier[:i], c.frontier[i+1:]...)
+			i--
+			continue
+		}
+		if host.busy {
+			continue
+		}
+		if d := host.next.Sub(now); d > 0 {
+			if wait < 0 || d < wait {
+				wait = d
+			}
+			continue
+		}
+		c.frontier = append(c.frontier[:i], c.frontier[i+1:]...)
+		c.active[task.URL] = task
+		host.busy = true
+		if !task.Sitemap {
+			c.pages++
+			host.pages++
+		}
+		return task, host, true, 0
+	}
+	return CrawlTask{}, nil, false, wait
+}
+
+func (c Crawler) Run() error {
+	c.lock.Lock()
+	if c.running {
+		c.lock.Unlock()
+		return ErrCrawlerRunning
+	}
+	if err := c.load(); err != nil {
+		c.lock.Unlock()
+		return err
+	}
+	c.running, c.paused = true, false
+	c.wake = make(chan struct{}, 1)
+	wake := c.wake
+	c.lock.Unlock()
+	done := make(chan struct{}, *c.Concurrency)
+	inFlight := 0
+	saved := time.Now()
+	for {
+		c.lock.Lock()
+		paused := c.paused
+		task, host, found, wait := CrawlTask{}, (*crawlHost)(nil), false, time.Duration(-1)
+		if !paused && inFlight < *c.Concurrency {
+			task, host, found, wait = c.next(time.Now())
+		}
+		c.lock.Unlock()
+		if found {
+			inFlight++
+			go func() {
+				defer func() {
+					done <- struct{}{}
+				}()
+				c.process(task, host)
+			}()
+			continue
+		}
+		if inFlight == 0 && (paused || wait < 0) {
+			break
+		}
+		var timer *time.Timer
+		var timeout <-chan time.Time
+		if !paused && wait >= 0 && inFlight < *c.Concurrency {
+			timer = time.NewTimer(wait)
+			timeout = timer.C
+		}
+		select {
+		case <-done:
+			inFlight--
+		case <-wake:
+		case <-timeout:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if c.StateFile != "" && time.Since(saved) >= time.Duration(*c.SaveInterval) {
+			saved = time.Now()
+			c.lock.Lock()
+			err := c.save()
+			c.lock.Unlock()
+			c.report(CrawlTask{}, err)
+		}
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.running, c.wake = false, nil
+	return c.save()
+}
+
+func (c Crawler) report(task CrawlTask, err error) {
+	if c.OnError != nil && err != nil {
+		c.OnError(task, err)
+	}
+}
+
+func (c Crawler) prepare(request Request) {
+	if c.Prepare != nil {
+		c.Prepare(request)
+	}
+	for _, kv := range request.CustomizedHeaderList {
+		if len(kv) > 0 && strings.EqualFold(kv[0], header.UserAgent) {
+			return
+		}
+	}
+	if *c.UserAgent != "" {
+		request.CustomizedHeaderList = append(request.CustomizedHeaderList, []string{header.UserAgent, *c.UserAgent})
+	}
+}
+
+func (c Crawler) fetchRobots(u *url.URL) (Robots, bool) {
+	request := NewRequest(func(request Request) {
+		request.URL = crawlHostKey(u) + "/robots.txt"
+	})
+	c.prepare(request)
+	res, err := await(request.Stream())
+	if err != nil {
+		return ParseRobots("User-agent: *\nDisallow: /"), false
+	}
+	defer res.Result.Done()
+	switch {
+	case request.StatusCode >= 500:
+		return ParseRobots("User-agent: *\nDisallow: /"), false
+	case request.StatusCode >= 200 && request.StatusCode < 300:
+		content, err := io.ReadAll(io.LimitReader(res.Result.Reader, maxRobotsSize))
+		if len(content) == maxRobotsSize {
+			request.Cancel()
+		}
+		if err != nil {
+			return ParseRobots("User-agent: *\nDisallow: /"), false
+		}
+		return ParseRobots(string(content)), true
+	}
+	return ParseRobots(""), true
+}
+
+func (c Crawler) discover(task CrawlTask, u *url.URL, host *crawlHost) {
+	host.robots, host.robotsExpires = ParseRobots(""), time.Now().Add(time.Duration(*c.RobotsTTL))
+	if *c.RespectRobots || *c.FollowSitemaps {
+		if robots, ok := c.fetchRobots(u); ok {
+			host.robots = robots
+		} else {
+			host.robots, host.robotsExpires = robots, time.Now().Add(time.Duration(*c.RobotsRetry))
+		}
+	}
+	host.delay = time.Duration(*c.Delay)
+	if delay, ok := host.robots.CrawlDelay(*c.UserAgent); ok && *c.RespectRobots {
+		if delay > time.Duration(*c.MaxCrawlDelay) {
+			delay = time.Duration(*c.MaxCrawlDelay)
+		}
+		if delay > host.delay {
+			host.delay = delay
+		}
+	}
+	if !*c.FollowSitemaps {
+		return
+	}
+	sitemaps, optional := host.robots.Sitemaps, false
+	if len(sitemaps) == 0 {
+		sitemaps, optional = []string{crawlHostKey(u) + "/sitemap.xml"}, true
+	}
+	c.enqueueAll(task, sitemaps, task.Depth, "", true, optional)
+}
+
+func (c Crawler) enqueueAll(task CrawlTask, links []string, depth int, referrer string, sitemap bool, optional bool) {
+	var errs []error
+	c.lock.Lock()
+	for _, link := range links {
+		if err := c.enqueue(link, depth, referrer, sitemap, optional); err != nil && !errors.Is(err, ErrCrawlOutOfScope) {
+			errs = append(errs, err)
+		}
+	}
+	c.lock.Unlock()
+	for _, err := range errs {
+		c.report(task, err)
+	}
+}
+
+func (c Crawler) process(task CrawlTask, host *crawlHost) {
+	fetched := false
+	defer func() {
+		c.lock.Lock()
+		defer c.lock.Unlock()
+		host.busy = false
+		delete(c.active, task.URL)
+		if fetched {
+			host.next = time.Now().Add(host.delay)
+		}
+		c.notify()
+	}()
+	u, err := url.Parse(task.URL)
+	if err != nil {
+		c.report(task, err)
+		return
+	}
+	if !time.Now().Before(host.robotsExpires) {
+		c.discover(task, u, host)
+		if *c.RespectRobots || *c.FollowSitemaps {
+			fetched = true
+			c.lock.Lock()
+			c.frontier = append([]CrawlTask{task}, c.frontier...)
+			if !task.Sitemap {
+				c.pages--
+				host.pages--
+			}
+			c.lock.Unlock()
+			return
+		}
+	}
+	if *c.RespectRobots && !host.robots.Allowed(*c.UserAgent, u.RequestURI()) {
+		c.lock.Lock()
+		if !task.Sitemap {
+			c.pages--
+			host.pages--
+		}
+		c.lock.Unlock()
+		c.report(task, fmt.Errorf("%w: %s", ErrRobotsDisallowed, task.URL))
+		return
+	}
+	request, err := NewRequest().Deserialize(task.Request)
+	if err != nil {
+		c.report(task, err)
+		return
+	}
+	c.prepare(request)
+	fetched = true
+	res, err := await(request.ByteSlice())
+	if err == nil && task.Optional && request.StatusCode >= 400 && request.StatusCode < 500 {
+		return
+	}
+	if err == nil && (request.StatusCode < 200 || request.StatusCode >= 300) {
+		err = fmt.Errorf("%w: %d %s", ErrCrawlStatus, request.StatusCode, request.StatusMessage)
+	}
+	if err != nil {
+		c.report(task, err)
+		return
+	}
+	finalURL := request.FinalURL
+	if finalURL == "" {
+		finalURL = task.URL
+	}
+	if normalized, err := NormalizeURL(finalURL, c.URLNormalization); err == nil {
+		c.lock.Lock()
+		c.seen[normalized] = true
+		c.lock.Unlock()
+	}
+	if task.Sitemap {
+		c.handleSitemap(task, res.Result)
+		return
+	}
+	page := &_CrawlPage{Task: task, Request: request}
+	if isHTMLResponse(request, res.Result) {
+		docRes, err := await(request.HTMLSelection())
+		if err != nil {
+			c.report(task, err)
+			return
+		}
+		page.Document = docRes.Result
+		page.Links = c.extractLinks(page.Document)
+	}
+	for _, handler := range c.Handlers {
+		if err := handler.HandleCrawl(page); err != nil {
+			c.report(task, err)
+			break
+		}
+	}
+	c.enqueueAll(task, page.Links, task.Depth+1, finalURL, false, false)
+}
+
+func (c Crawler) handleSitemap(task CrawlTask, content []byte) {
+	sitemap, err := ParseSitemap(content)
+	if err != nil {
+		c.report(task, err)
+		return
+	}
+	c.enqueueAll(task, sitemap.Sitemaps, task.Depth, "", true, false)
+	c.enqueueAll(task, sitemap.URLs, task.Depth+1, "", false, false)
+}
+
+func isHTMLResponse(request Request, content []byte) bool {
+	contentType := ""
+	if value := request.GetFirstResponseHeader(header.ContentType); value != nil {
+		contentType = *value
+	}
+	if contentType == "" {
+		contentType = http.DetectContentType(content)
+	}
+	mediaType, _, err := stdmime.ParseMediaType(contentType)
+	return err == nil && (mediaType == "text/html" || mediaType == "application/xhtml+xml")
+}
+
+func (c Crawler) extractLinks(document HTMLSelection) []string {
+	if *c.RespectNofollow {
+		if metas, err := document.QuerySelectorAll("meta[name=robots i]"); err == nil {
+			for _, content := range metas.Attrs("content") {
+				for _, directive := range strings.Split(strings.ToLower(content), ",") {
+					if directive = strings.TrimSpace(directive); directive == "nofollow" || directive == "none" {
+						return nil
+					}
+				}
+			}
+		}
+	}
+	var links []string
+	seen := map[string]bool{}
+	document.Find(c.linkSelector).Each(func(_ int, node HTMLSelection) {
+		if rel, _ := node.Attr("rel"); *c.RespectNofollow && strings.Contains(" "+strings.ToLower(rel)+" ", " nofollow ") {
+			return
+		}
+		link, has := node.URL("href")
+		if !has {
+			link, has = node.URL("src")
+		}
+		if has && !seen[link] {
+			seen[link] = true
+			links = append(links, link)
+		}
+	})
+	return links
+}
@@ -0,0 +1,748 @@
+package http
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/TelephoneTan/GoHTTPRequest/net"
+	"github.com/TelephoneTan/GoHTTPRequest/net/http/header"
+	"github.com/TelephoneTan/GoHTTPRequest/net/http/method"
+	"github.com/TelephoneTan/GoHTTPRequest/net/mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var ErrCurlSyntax = errors.New("curl: invalid command")
+
+func shellQuote(s string) string {
+	printable := utf8.ValidString(s)
+	for _, c := range s {
+		if c < 0x20 || c == 0x7f {
+			printable = false
+		}
+	}
+	if printable {
+		return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+	}
+	sb := strings.Builder{}
+	sb.WriteString("$'")
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' || c == '\'':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		case c == '\n':
+			sb.WriteString(`\n`)
+		case c == '\r':
+			sb.WriteString(`\r`)
+		case c == '\t':
+			sb.WriteString(`\t`)
+		case c < 0x20 || c >= 0x7f:
+			sb.WriteString(fmt.Sprintf(`\x%02x`, c))
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	sb.WriteString("'")
+	return sb.String()
+}
+
+func printfQuote(bs []byte) string {
+	sb := strings.Builder{}
+	for _, c := range bs {
+		if c < 0x20 || c >= 0x7f || c == '\\' || c == '%' || c == '\'' {
+			sb.WriteString(fmt.Sprintf(`\%03o`, c))
+		} else {
+			sb.WriteByte(c)
+		}
+	}
+	return "printf '" + sb.String() + "'"
+}
+
+func shellSafe(bs []byte) bool {
+	return utf8.Valid(bs) && bytes.IndexByte(bs, 0) < 0
+}
+
+func unprintf(format string) (string, error) {
+	sb := strings.Builder{}
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		switch {
+		case c == '%':
+			if i+1 >= len(format) || format[i+1] != '%' {
+				return "", fmt.Errorf("%w: unsupported printf directive", ErrCurlSyntax)
+			}
+			i++
+			sb.WriteByte('%')
+		case c == '\\' && i+1 < len(format):
+			i++
+			switch e := format[i]; {
+			case e >= '0' && e <= '7':
+				j := i
+				for j < len(format) && j < i+3 && format[j] >= '0' && format[j] <= '7' {
+					j++
+				}
+				v, _ := strconv.ParseUint(format[i:j], 8, 8)
+				sb.WriteByte(byte(v))
+				i = j - 1
+			case e == 'n':
+				sb.WriteByte('\n')
+			case e == 'r':
+				sb.WriteByte('\r')
+			case e == 't':
+				sb.WriteByte('\t')
+			default:
+				sb.WriteByte(e)
+			}
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String(), nil
+}
+
+func formatSeconds(d Duration) string {
+	return strconv.FormatFloat(time.Duration(d).Seconds(), 'f', -1, 64)
+}
+
+func (r Request) curlCookies() []string {
+	u, err := r.parsedURL()
+	if err != nil {
+		return nil
+	}
+	var cookies []string
+	seen := map[string]bool{}
+	add := func(c *http.Cookie) {
+		if !seen[c.Name] {
+			seen[c.Name] = true
+			cookies = append(cookies, c.Name+"="+c.Value)
+		}
+	}
+	if r.CookieJar != nil {
+		for _, c := range r.CookieJar.Cookies(u) {
+			add(c)
+		}
+	}
+	for _, urlCookie := range r.SetCookies {
+		if len(urlCookie) < 2 {
+			continue
+		}
+		if cu, err := url.Parse(urlCookie[0]); err != nil || !strings.EqualFold(cu.Hostname(), u.Hostname()) {
+			continue
+		}
+		for _, c := range (&http.Response{Header: http.Header{"Set-Cookie": {urlCookie[1]}}}).Cookies() {
+			add(c)
+		}
+	}
+	return cookies
+}
+
+func (r Request) Curl() (string, error) {
+	encodedURL, err := r.encodedURL()
+	if err != nil {
+		return "", err
+	}
+	args := []string{"curl"}
+	ct := r.calContentType()
+	var body []string
+	stdin := ""
+	if s := r.RequestString; s != "" || len(r.RequestForm) > 0 {
+		if form := encodeForm(r.RequestForm); form != "" {
+			s = form
+			ct = string(mime.XWWWFormURLEncoded)
+		}
+		switch {
+		case s != "" && !shellSafe([]byte(s)):
+			stdin, body = printfQuote([]byte(s)), []string{"--data-binary", "@-"}
+		case s != "":
+			body = []string{"--data-raw", shellQuote(s)}
+		}
+		if s != "" {
+			if ct == "" {
+				ct = string(mime.TextPlainUTF8)
+			}
+		}
+	}
+	if len(r.RequestBinary) > 0 {
+		body = []string{"--data-binary", shellQuote(string(r.RequestBinary))}
+		if !shellSafe(r.RequestBinary) {
+			stdin, body = printfQuote(r.RequestBinary), []string{"--data-binary", "@-"}
+		}
+		if ct == "" {
+			ct = http.DetectContentType(r.RequestBinary)
+		}
+	}
+	if r.RequestFile != nil {
+		stdin, body = "", []string{"--data-binary", shellQuote("@" + r.RequestFile.Name())}
+	}
+	if body == nil && r.RequestBody != nil {
+		body = []string{"--data-binary", "@-"}
+	}
+	if body != nil && ct == "" {
+		ct = string(mime.ApplicationOctetStream)
+	}
+	m := r.generateRequestMethod()
+	switch {
+	case m == string(*method.GET) && body == nil:
+	case m == string(*method.HEAD):
+		args = append(args, "-I")
+	default:
+		args = append(args, "-X", m)
+	}
+	args = append(args, shellQuote(encodedURL))
+	if ct != "" {
+		args = append(args, "-H", shellQuote(header.ContentType+": "+ct))
+	}
+	for _, kv := range r.CustomizedHeaderList {
+		if len(kv) > 0 {
+			v := ""
+			if len(kv) > 1 {
+				v = kv[1]
+			}
+			args = append(args, "-H", shellQuote(kv[0]+": "+v))
+		}
+	}
+	switch auth := r.Authenticator.(type) {
+	case *BasicAuth:
+		args = append(args, "-u", shellQuote(auth.Username+":"+auth.Password))
+	case *BearerAuth:
+		args = append(args, "-H", shellQuote(header.Authorization+": Bearer "+auth.Token))
+	case DigestAuth:
+		args = append(args, "--digest", "-u", shellQuote(auth.Username+":"+auth.Password))
+	}
+	args = append(args, body...)
+	if cookies := r.curlCookies(); len(cookies) > 0 {
+		args = append(args, "-b", shellQuote(strings.Join(cookies, "; ")))
+	}
+	if r.Proxy != nil {
+		if u := r.Proxy.URL(); u != nil {
+			args = append(args, "-x", shellQuote(u.String()))
+		}
+	}
+	if r.ConnectTimeout != nil {
+		args = append(args, "--connect-timeout", formatSeconds(*r.ConnectTimeout))
+	}
+	if r.Timeout != nil {
+		args = append(args, "--max-time", formatSeconds(*r.Timeout))
+	}
+	if r.FollowRedirect == nil || *r.FollowRedirect {
+		args = append(args, "-L")
+	}
+	if r.InsecureSkipVerify != nil && *r.InsecureSkipVerify {
+		args = append(args, "-k")
+	}
+	if stdin != "" {
+		args = append([]string{stdin, "|"}, args...)
+	}
+	return strings.Join(args, " "), nil
+}
+
+func splitShellWords(command string) ([]string, error) {
+	var words []string
+	sb := strings.Builder{}
+	inWord := false
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if inWord {
+				words = append(words, sb.String())
+				sb.Reset()
+				inWord = false
+			}
+		case c == '\\':
+			if i+1 < len(command) {
+				i++
+				if command[i] == '\n' {
+					continue
+				}
+				if command[i] == '\r' && i+1 < len(command) && command[i+1] == '\n' {
+					i++
+					continue
+				}
+				sb.WriteByte(command[i])
+				inWord = true
+			}
+		case c == '\'':
+			end := strings.IndexByte(command[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("%w: unterminated single quote", ErrCurlSyntax)
+			}
+			sb.WriteString(command[i+1 : i+1+end])
+			i += end + 1
+			inWord = true
+		case c == '$' && i+1 < len(command) && command[i+1] == '\'':
+			n, err := readANSIQuoted(command[i+2:], &sb)
+			if err != nil {
+				return nil, err
+			}
+			i += n + 2
+			inWord = true
+		case c == '"':
+			i++
+			for ; i < len(command) && command[i] != '"'; i++ {
+				if command[i] == '\\' && i+1 < len(command) && strings.IndexByte("\"\\$`\n", command[i+1]) >= 0 {
+					i++
+					if command[i] == '\n' {
+						continue
+					}
+				}
+				sb.WriteByte(command[i])
+			}
+			if i >= len(command) {
+				return nil, fmt.Errorf("%w: unterminated double quote", ErrCurlSyntax)
+			}
+			inWord = true
+		default:
+			sb.WriteByte(c)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, sb.String())
+	}
+	return words, nil
+}
+
+func readANSIQuoted(s string, sb *strings.Builder) (int, error) {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '\'' {
+			return i, nil
+		}
+		if c != '\\' || i+1 >= len(s) {
+			sb.WriteByte(c)
+			continue
+		}
+		i++
+		switch e := s[i]; e {
+		case 'n':
+			sb.WriteByte('\n')
+		case 'r':
+			sb.WriteByte('\r')
+		case 't':
+			sb.WriteByte('\t')
+		case 'a':
+			sb.WriteByte('\a')
+		case 'b':
+			sb.WriteByte('\b')
+		case 'e', 'E':
+			sb.WriteByte(0x1b)
+		case 'x', 'u', 'U':
+			digits := map[byte]int{'x': 2, 'u': 4, 'U': 8}[e]
+			j := i + 1
+			for j < len(s) && j < i+1+digits && strings.IndexByte("0123456789abcdefABCDEF", s[j]) >= 0 {
+				j++
+			}
+			v, err := strconv.ParseUint(s[i+1:j], 16, 32)
+			if err != nil {
+				return 0, fmt.Errorf("%w: bad escape \\%c", ErrCurlSyntax, e)
+			}
+			if e == 'x' {
+				sb.WriteByte(byte(v))
+			} else {
+				sb.WriteRune(rune(v))
+			}
+			i = j - 1
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			j := i
+			for j < len(s) && j < i+3 && s[j] >= '0' && s[j] <= '7' {
+				j++
+			}
+			v, _ := strconv.ParseUint(s[i:j], 8, 8)
+			sb.WriteByte(byte(v))
+			i = j - 1
+		default:
+			sb.WriteByte(e)
+		}
+	}
+	return 0, fmt.Errorf("%w: unterminated $' quote", ErrCurlSyntax)
+}
+
+var curlValueFlags = map[string]string{
+	"-X": "--request", "-H": "--header", "-d": "--data", "-F": "--form", "-b": "--cookie",
+	"-x": "--proxy", "-u": "--user", "-A": "--user-agent", "-e": "--referer", "-m": "--max-time",
+	"-o": "--output", "-w": "--write-out", "-T": "--upload-file",
+}
+
+var curlBoolFlags = map[string]string{
+	"-L": "--location", "-k": "--insecure", "-s": "--silent", "-S": "--show-error", "-v": "--verbose",
+	"-i": "--include", "-I": "--head", "-G": "--get", "-f": "--fail", "-g": "--globoff", "-N": "--no-buffer",
+}
+
+var curlLongValueFlags = map[string]bool{
+	"--request": true, "--header": true, "--data": true, "--data-raw": true, "--data-ascii": true,
+	"--data-binary": true, "--data-urlencode": true, "--form": true, "--form-string": true, "--cookie": true,
+	"--proxy": true, "--user": true, "--user-agent": true, "--referer": true, "--max-time": true,
+	"--connect-timeout": true, "--url": true, "--output": true, "--write-out": true, "--upload-file": true,
+	"--json": true,
+}
+
+var curlLongBoolFlags = map[string]bool{
+	"--location": true, "--insecure": true, "--silent": true, "--show-error": true, "--verbose": true,
+	"--include": true, "--head": true, "--get": true, "--fail": true, "--globoff": true, "--no-buffer": true,
+	"--compressed": true, "--digest": true, "--basic": true, "--http1.1": true, "--http2": true,
+}
+
+type curlOption struct {
+	name  string
+	value string
+}
+
+func parseCurlOptions(words []string) ([]curlOption, error) {
+	if len(words) > 0 && (words[0] == "curl" || filepath.Base(words[0]) == "curl") {
+		words = words[1:]
+	}
+	var options []curlOption
+	for i := 0; i < len(words); i++ {
+		word := words[i]
+		next := func(name string) (string, error) {
+			if i+1 >= len(words) {
+				return "", fmt.Errorf("%w: %s requires a value", ErrCurlSyntax, name)
+			}
+			i++
+			return words[i], nil
+		}
+		switch {
+		case word == "--":
+			for _, rest := range words[i+1:] {
+				options = append(options, curlOption{name: "--url", value: rest})
+			}
+			i = len(words)
+		case strings.HasPrefix(word, "--"):
+			switch {
+			case curlLongBoolFlags[word]:
+				options = append(options, curlOption{name: word})
+			case curlLongValueFlags[word]:
+				value, err := next(word)
+				if err != nil {
+					return nil, err
+				}
+				options = append(options, curlOption{name: word, value: value})
+			default:
+				return nil, fmt.Errorf("%w: unsupported option %s", ErrCurlSyntax, word)
+			}
+		case strings.HasPrefix(word, "-") && len(word) > 1:
+			for j := 1; j < len(word); j++ {
+				flag := "-" + word[j:j+1]
+				if long, has := curlBoolFlags[flag]; has {
+					options = append(options, curlOption{name: long})
+					continue
+				}
+				long, has := curlValueFlags[flag]
+				if !has {
+					return nil, fmt.Errorf("%w: unsupported option %s", ErrCurlSyntax, flag)
+				}
+				value := word[j+1:]
+				if value == "" {
+					var err error
+					if value, err = next(flag); err != nil {
+						return nil, err
+					}
+				}
+				options = append(options, curlOption{name: long, value: value})
+				break
+			}
+		default:
+			options = append(options, curlOption{name: "--url", value: word})
+		}
+	}
+	return options, nil
+}
+
+func readCurlData(value string, stdin *string, keepNewlines bool) (string, error) {
+	if !strings.HasPrefix(value, "@") {
+		return value, nil
+	}
+	if value == "@-" && stdin != nil {
+		if !keepNewlines {
+			return strings.NewReplacer("\r", "", "\n", "").Replace(*stdin), nil
+		}
+		return *stdin, nil
+	}
+	bs, err := os.ReadFile(strings.TrimPrefix(value, "@"))
+	if err != nil {
+		return "", err
+	}
+	if !keepNewlines {
+		bs = bytes.ReplaceAll(bytes.ReplaceAll(bs, []byte("\r"), nil), []byte("\n"), nil)
+	}
+	return string(bs), nil
+}
+
+func curlURLEncode(value string) (string, error) {
+	name, content, hasEq := strings.Cut(value, "=")
+	if !hasEq {
+		if n, file, hasAt := strings.Cut(value, "@"); hasAt {
+			bs, err := os.ReadFile(file)
+			if err != nil {
+				return "", err
+			}
+			if n == "" {
+				return url.QueryEscape(string(bs)), nil
+			}
+			return url.QueryEscape(n) + "=" + url.QueryEscape(string(bs)), nil
+		}
+		return url.QueryEscape(value), nil
+	}
+	if name == "" {
+		return url.QueryEscape(content), nil
+	}
+	return url.QueryEscape(name) + "=" + url.QueryEscape(content), nil
+}
+
+func parseCurlProxy(value string) (*net.Proxy, error) {
+	if !strings.Contains(value, "://") {
+		value = "http://" + value
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		return nil, err
+	}
+	if u.User != nil {
+		return nil, fmt.Errorf("%w: proxy credentials are not supported", ErrCurlSyntax)
+	}
+	proxy := &net.Proxy{Host: u.Hostname()}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+		proxy.Type = net.HTTP
+	case "socks5", "socks5h":
+		proxy.Type = net.SOCKS
+	default:
+		return nil, fmt.Errorf("%w: unsupported proxy scheme %s", ErrCurlSyntax, u.Scheme)
+	}
+	port := 1080
+	if p := u.Port(); p != "" {
+		if port, err = strconv.Atoi(p); err != nil {
+			return nil, err
+		}
+	}
+	proxy.Port = net.Port(port)
+	return proxy, nil
+}
+
+func parseSeconds(value string) (*Duration, error) {
+	seconds, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: bad duration %s", ErrCurlSyntax, value)
+	}
+	d := Duration(seconds * float64(time.Second))
+	return &d, nil
+}
+
+func knownMethod(m string) *method.Method {
+	for _, known := range []*method.Method{method.GET, method.POST, method.PUT, method.DELETE, method.HEAD, method.OPTIONS, method.TRACE, method.PATCH} {
+		if string(*known) == m {
+			return known
+		}
+	}
+	custom := method.Method(m)
+	return &custom
+}
+
+func ParseCurl(command string) (Request, error) {
+	words, err := splitShellWords(command)
+	if err != nil {
+		return nil, err
+	}
+	var stdin *string
+	if len(words) > 3 && words[0] == "printf" && words[2] == "|" {
+		input, err := unprintf(words[1])
+		if err != nil {
+			return nil, err
+		}
+		stdin, words = &input, words[3:]
+	}
+	options, err := parseCurlOptions(words)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		rawURL, explicitMethod, user string
+		data, cookies                []string
+		headers                      [][]string
+		binary, get, head, digest    bool
+		form                         *multipart.Writer
+		formBody                     = &bytes.Buffer{}
+	)
+	request := NewRequest()
+	followRedirect := false
+	request.FollowRedirect = &followRedirect
+	for _, option := range options {
+		switch option.name {
+		case "--url":
+			if rawURL != "" {
+				return nil, fmt.Errorf("%w: multiple URLs", ErrCurlSyntax)
+			}
+			rawURL = option.value
+		case "--request":
+			explicitMethod = strings.ToUpper(option.value)
+		case "--header":
+			name, value, _ := strings.Cut(option.value, ":")
+			headers = append(headers, []string{strings.TrimSpace(name), strings.TrimSpace(value)})
+		case "--data", "--data-ascii":
+			value, err := readCurlData(option.value, stdin, false)
+			if err != nil {
+				return nil, err
+			}
+			data = append(data, value)
+		case "--data-raw":
+			data = append(data, option.value)
+		case "--data-binary":
+			value, err := readCurlData(option.value, stdin, true)
+			if err != nil {
+				return nil, err
+			}
+			data = append(data, value)
+			binary = binary || !utf8.ValidString(value)
+		case "--data-urlencode":
+			value, err := curlURLEncode(option.value)
+			if err != nil {
+				return nil, err
+			}
+			data = append(data, value)
+		case "--json":
+			value, err := readCurlData(option.value, stdin, true)
+			if err != nil {
+				return nil, err
+			}
+			data = append(data, value)
+			headers = append(headers, []string{header.ContentType, string(mime.ApplicationJSON)}, []string{"Accept", string(mime.ApplicationJSON)})
+		case "--form", "--form-string":
+			if form == nil {
+				form = multipart.NewWriter(formBody)
+			}
+			name, value, _ := strings.Cut(option.value, "=")
+			switch {
+			case option.name == "--form" && strings.HasPrefix(value, "@"):
+				file := strings.Split(strings.TrimPrefix(value, "@"), ";")[0]
+				bs, err := os.ReadFile(file)
+				if err != nil {
+					return nil, err
+				}
+				w, err := form.CreateFormFile(name, filepath.Base(file))
+				if err != nil {
+					return nil, err
+				}
+				_, _ = w.Write(bs)
+			case option.name == "--form" && strings.HasPrefix(value, "<"):
+				bs, err := os.ReadFile(strings.Split(strings.TrimPrefix(value, "<"), ";")[0])
+				if err != nil {
+					return nil, err
+				}
+				_ = form.WriteField(name, string(bs))
+			default:
+				_ = form.WriteField(name, value)
+			}
+		case "--cookie":
+			if !strings.Contains(option.value, "=") {
+				return nil, fmt.Errorf("%w: cookie files are not supported", ErrCurlSyntax)
+			}
+			cookies = append(cookies, option.value)
+		case "--proxy":
+			if request.Proxy, err = parseCurlProxy(option.value); err != nil {
+				return nil, err
+			}
+		case "--user":
+			user = option.value
+		case "--user-agent":
+			headers = append(headers, []string{"User-Agent", option.value})
+		case "--referer":
+			headers = append(headers, []string{"Referer", option.value})
+		case "--max-time":
+			if request.Timeout, err = parseSeconds(option.value); err != nil {
+				return nil, err
+			}
+		case "--connect-timeout":
+			if request.ConnectTimeout, err = parseSeconds(option.value); err != nil {
+				return nil, err
+			}
+		case "--upload-file":
+			if request.RequestBinary, err = os.ReadFile(option.value); err != nil {
+				return nil, err
+			}
+			if explicitMethod == "" {
+				explicitMethod = string(*method.PUT)
+			}
+		case "--location":
+			followRedirect = true
+		case "--insecure":
+			insecure := true
+			request.InsecureSkipVerify = &insecure
+		case "--get":
+			get = true
+		case "--head":
+			head = true
+		case "--digest":
+			digest = true
+		case "--basic":
+			digest = false
+		}
+	}
+	if rawURL == "" {
+		return nil, fmt.Errorf("%w: missing URL", ErrCurlSyntax)
+	}
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "http://" + rawURL
+	}
+	m := string(*method.GET)
+	body := strings.Join(data, "&")
+	switch {
+	case get && len(data) > 0:
+		separator := "?"
+		if strings.Contains(rawURL, "?") {
+			separator = "&"
+		}
+		rawURL += separator + body
+	case len(data) > 0:
+		m = string(*method.POST)
+		if binary {
+			request.RequestBinary = []byte(body)
+		} else {
+			request.RequestString = body
+		}
+		request.RequestContentTypeHeader = string(mime.XWWWFormURLEncoded)
+	case form != nil:
+		if err := form.Close(); err != nil {
+			return nil, err
+		}
+		m = string(*method.POST)
+		request.RequestBinary = formBody.Bytes()
+		request.RequestContentTypeHeader = form.FormDataContentType()
+	}
+	if head {
+		m = string(*method.HEAD)
+	}
+	if explicitMethod != "" {
+		m = explicitMethod
+	}
+	request.Method = knownMethod(m)
+	request.URL = rawURL
+	for _, kv := range headers {
+		if strings.EqualFold(kv[0], header.ContentType) {
+			request.RequestContentTypeHeader = kv[1]
+			continue
+		}
+		request.CustomizedHeaderList = append(request.CustomizedHeaderList, kv)
+	}
+	if len(cookies) > 0 {
+		request.CustomizedHeaderList = append(request.CustomizedHeaderList, []string{"Cookie", strings.Join(cookies, "; ")})
+	}
+	if user != "" {
+		username, password, _ := strings.Cut(user, ":")
+		if digest {
+			request.Authenticator = NewDigestAuth(username, password)
+		} else {
+			request.Authenticator = &BasicAuth{Username: username, Password: password}
+		}
+	}
+	return request, nil
+}
@@ -0,0 +1,562 @@
+package http
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/TelephoneTan/GoHTTPRequest/net/http/header"
+	"github.com/TelephoneTan/GoHTTPRequest/net/http/method"
+	"github.com/TelephoneTan/GoHTTPRequest/util"
+	"github.com/TelephoneTan/GoPromise/async/promise"
+	"github.com/TelephoneTan/GoPromise/async/task"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	defaultDownloadSegments       = 1
+	defaultDownloadMinSegmentSize = int64(1 << 20)
+	defaultDownloadMaxAttempts    = 3
+	defaultDownloadResume         = true
+	defaultVerifyDigestHeader     = true
+	downloadStateSaveInterval     = int64(4 << 20)
+)
+
+var (
+	ErrDownloadSize     = errors.New("download size mismatch")
+	ErrDownloadChecksum = errors.New("download checksum mismatch")
+	errRangeIgnored     = errors.New("server ignored range request")
+)
+
+type downloadSegment struct {
+	Start    int64
+	End      int64
+	Done     int64
+	Complete bool
+}
+
+type downloadState struct {
+	URL          string
+	ETag         string
+	LastModified string
+	Digest       string
+	ContentMD5   string
+	Size         int64
+	Segments     []*downloadSegment
+}
+
+func (s *downloadState) validator() string {
+	if s.ETag != "" && !strings.HasPrefix(s.ETag, "W/") {
+		return s.ETag
+	}
+	return s.LastModified
+}
+
+type _Download struct {
+	Request            Request
+	Path               string
+	Segments           *int
+	MinSegmentSize     *int64
+	MaxAttempts        *int
+	Resume             *bool
+	SHA256             string
+	MD5                string
+	VerifyDigestHeader *bool
+	OnProgress         func(Progress)
+	ProgressInterval   *Duration
+	//
+	Size      int64
+	ETag      string
+	Resumed   bool
+	SHA256Sum string
+	MD5Sum    string
+	//
+	state      *downloadState
+	stateLock  sync.Mutex
+	file       *os.File
+	downloaded atomic.Int64
+	progress   *progressTracker
+	requests   []Request
+	cancelled  atomic.Bool
+	//
+	task task.Once[Download]
+}
+
+type Download = *_Download
+
+func (d Download) tempPath() string {
+	return d.Path + ".part"
+}
+
+func (d Download) statePath() string {
+	return d.Path + ".part.json"
+}
+
+func (d Download) generateDefaults() {
+	if d.Segments == nil || *d.Segments < 1 {
+		d.Segments = &defaultDownloadSegments
+	}
+	if d.MinSegmentSize == nil {
+		d.MinSegmentSize = &defaultDownloadMinSegmentSize
+	}
+	if d.MaxAttempts == nil || *d.MaxAttempts < 1 {
+		d.MaxAttempts = &defaultDownloadMaxAttempts
+	}
+	if d.Resume == nil {
+		d.Resume = &defaultDownloadResume
+	}
+	if d.VerifyDigestHeader == nil {
+		d.VerifyDigestHeader = &defaultVerifyDigestHeader
+	}
+	if d.ProgressInterval == nil {
+		d.ProgressInterval = &defaultProgressInterval
+	}
+}
+
+func (d Download) newRequest() Request {
+	request := d.Request.Clone()
+	if request.Timeout == nil {
+		noTimeout := Duration(0)
+		request.Timeout = &noTimeout
+	}
+	d.stateLock.Lock()
+	defer d.stateLock.Unlock()
+	d.requests = append(d.requests, request)
+	if d.cancelled.Load() {
+		request.Cancel()
+	}
+	return request
+}
+
+func (d Download) Cancel() {
+	d.cancelled.Store(true)
+	d.stateLock.Lock()
+	defer d.stateLock.Unlock()
+	for _, request := range d.requests {
+		request.Cancel()
+	}
+}
+
+func (d Download) loadState(encodedURL string) bool {
+	bs, err := os.ReadFile(d.statePath())
+	if err != nil {
+		return false
+	}
+	state := &downloadState{}
+	if json.Unmarshal(bs, state) != nil || state.URL != encodedURL || state.validator() == "" || len(state.Segments) == 0 {
+		return false
+	}
+	fi, err := os.Stat(d.tempPath())
+	if err != nil {
+		return false
+	}
+	for _, seg := range state.Segments {
+		if seg.Start+seg.Done > fi.Size() {
+			return false
+		}
+		d.downloaded.Add(seg.Done)
+	}
+	d.state = state
+	return true
+}
+
+func (d Download) saveState() {
+	d.stateLock.Lock()
+	bs, err := json.Marshal(d.state)
+	d.stateLock.Unlock()
+	if err == nil {
+		_ = os.WriteFile(d.statePath(), bs, 0644)
+	}
+}
+
+func (d Download) learn(request Request, full bool) {
+	d.stateLock.Lock()
+	defer d.stateLock.Unlock()
+	if etag := request.GetFirstResponseHeader(header.ETag); etag != nil && d.state.ETag == "" {
+		d.state.ETag = *etag
+	}
+	if lm := request.GetFirstResponseHeader(header.LastModified); lm != nil && d.state.LastModified == "" {
+		d.state.LastModified = *lm
+	}
+	if digest := request.GetFirstResponseHeader(header.Digest); digest != nil {
+		d.state.Digest = *digest
+	}
+	if full {
+		if contentMD5 := request.GetFirstResponseHeader(header.ContentMD5); contentMD5 != nil {
+			d.state.ContentMD5 = *contentMD5
+		}
+	}
+}
+
+func (d Download) probe() (acceptRanges bool) {
+	request := d.newRequest()
+	request.Method = method.HEAD
+	if _, err := await(request.Send()); err != nil || request.StatusCode != http.StatusOK {
+		return false
+	}
+	d.learn(request, true)
+	if cl := request.GetFirstResponseHeader(header.ContentLength); cl != nil {
+		if size, err := strconv.ParseInt(*cl, 10, 64); err == nil {
+			d.state.Size = size
+		}
+	}
+	ar := request.GetFirstResponseHeader(header.AcceptRanges)
+	return ar != nil && strings.EqualFold(strings.TrimSpace(*ar), "bytes")
+}
+
+func (d Download) freshState(encodedURL string) {
+	d.downloaded.Store(0)
+	d.state = &downloadState{URL: encodedURL, Size: -1}
+	segments := 1
+	if *d.Segments > 1 && d.probe() && d.state.Size > 0 {
+		segments = *d.Segments
+		if maxSegments := (d.state.Size + *d.MinSegmentSize - 1) / *d.MinSegmentSize; int64(segments) > maxSegments {
+			segments = int(maxSegments)
+		}
+	}
+	if segments <= 1 {
+		d.state.Segments = []*downloadSegment{{Start: 0, End: -1}}
+		if d.state.Size >= 0 {
+			d.state.Segments[0].End = d.state.Size - 1
+		}
+		return
+	}
+	size := d.state.Size / int64(segments)
+	for i := 0; i < segments; i++ {
+		seg := &downloadSegment{Start: int64(i) * size, End: int64(i+1)*size - 1}
+		if i == segments-1 {
+			seg.End = d.state.Size - 1
+		}
+		d.state.Segments = append(d.state.Segments, seg)
+	}
+}
+
+func parseContentRange(s string) (start, end, total int64, ok bool) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "bytes ") {
+		return 0, 0, 0, false
+	}
+	rangeTotal := strings.SplitN(strings.TrimSpace(s[len("bytes "):]), "/", 2)
+	if len(rangeTotal) != 2 {
+		return 0, 0, 0, false
+	}
+	startEnd := strings.SplitN(rangeTotal[0], "-", 2)
+	if len(startEnd) != 2 {
+		return 0, 0, 0, false
+	}
+	var err error
+	if start, err = strconv.ParseInt(startEnd[0], 10, 64); err != nil {
+		return 0, 0, 0, false
+	}
+	if end, err = strconv.ParseInt(startEnd[1], 10, 64); err != nil {
+		return 0, 0, 0, false
+	}
+	total = -1
+	if rangeTotal[1] != "*" {
+		if total, err = strconv.ParseInt(rangeTotal[1], 10, 64); err != nil {
+			return 0, 0, 0, false
+		}
+	}
+	return start, end, total, true
+}
+
+func (d Download) fetchRange(seg *downloadSegment) error {
+	from := seg.Start + seg.Done
+	request := d.newRequest()
+	if from > 0 || seg.End >= 0 && len(d.state.Segments) > 1 {
+		rangeValue := "bytes=" + strconv.FormatInt(from, 10) + "-"
+		if seg.End >= 0 {
+			rangeValue += strconv.FormatInt(seg.End, 10)
+		}
+		request.CustomizedHeaderList = append(request.CustomizedHeaderList, []string{header.Range, rangeValue})
+		if validator := d.state.validator(); validator != "" {
+			request.CustomizedHeaderList = append(request.CustomizedHeaderList, []string{header.IfRange, validator})
+		}
+	}
+	streamRes, err := await(request.Stream())
+	if err != nil {
+		return err
+	}
+	defer streamRes.Result.Done()
+	switch request.StatusCode {
+	case http.StatusPartialContent:
+		cr := request.GetFirstResponseHeader(header.ContentRange)
+		if cr == nil {
+			return errRangeIgnored
+		}
+		start, _, total, ok := parseContentRange(*cr)
+		if !ok || start != from {
+			return errRangeIgnored
+		}
+		d.learn(request, false)
+		if d.state.Size < 0 && total >= 0 {
+			d.stateLock.Lock()
+			d.state.Size = total
+			seg.End = total - 1
+			d.stateLock.Unlock()
+			d.progress.setTotal(total)
+		}
+	case http.StatusOK:
+		if from != 0 || len(d.state.Segments) > 1 {
+			return errRangeIgnored
+		}
+		d.learn(request, true)
+		if cl := request.GetFirstResponseHeader(header.ContentLength); cl != nil && d.state.Size < 0 {
+			if size, err := strconv.ParseInt(*cl, 10, 64); err == nil {
+				d.stateLock.Lock()
+				d.state.Size = size
+				seg.End = size - 1
+				d.stateLock.Unlock()
+				d.progress.setTotal(size)
+			}
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		if d.state.Size >= 0 && from >= d.state.Size {
+			d.stateLock.Lock()
+			seg.Complete = true
+			d.stateLock.Unlock()
+			return nil
+		}
+		return fmt.Errorf("%w: %s", errRangeIgnored, request.StatusMessage)
+	default:
+		return fmt.Errorf("unexpected download status: %s", request.StatusMessage)
+	}
+	reader := streamRes.Result.Reader
+	if seg.End >= 0 {
+		reader = io.LimitReader(reader, seg.End-from+1)
+	}
+	buf := make([]byte, 32*1024)
+	unsaved := int64(0)
+	defer d.saveState()
+	for {
+		n, err := reader.Read(buf)
+		if n > 0 {
+			if _, werr := d.file.WriteAt(buf[:n], seg.Start+seg.Done); werr != nil {
+				return werr
+			}
+			d.stateLock.Lock()
+			seg.Done += int64(n)
+			d.stateLock.Unlock()
+			d.downloaded.Add(int64(n))
+			d.progress.add(int64(n))
+			if unsaved += int64(n); unsaved >= downloadStateSaveInterval {
+				unsaved = 0
+				d.saveState()
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if seg.End >= 0 && seg.Start+seg.Done <= seg.End {
+		return io.ErrUnexpectedEOF
+	}
+	d.stateLock.Lock()
+	defer d.stateLock.Unlock()
+	if seg.End < 0 {
+		seg.End = seg.Start + seg.Done - 1
+		d.state.Size = seg.Start + seg.Done
+	}
+	seg.Complete = true
+	return nil
+}
+
+func (d Download) fetchSegment(seg *downloadSegment) (err error) {
+	for attempt := 1; !seg.Complete; attempt++ {
+		err = d.fetchRange(seg)
+		if err == nil || errors.Is(err, errRangeIgnored) || d.cancelled.Load() || attempt >= *d.MaxAttempts {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d Download) fetchAll() error {
+	errs := make([]error, len(d.state.Segments))
+	var all []promise.Promise[any]
+	for i, seg := range d.state.Segments {
+		i, seg := i, seg
+		all = append(all, promise.NewPromise(promise.Job[any]{
+			Do: func(rs promise.Resolver[any], re promise.Rejector) {
+				errs[i] = d.fetchSegment(seg)
+				rs.ResolveValue(nil)
+			},
+		}))
+	}
+	promise.AwaitAll(all)
+	for _, err := range errs {
+		if errors.Is(err, errRangeIgnored) {
+			return err
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func decodeDigest(s string, size int) []byte {
+	s = strings.TrimSpace(s)
+	if bs, err := hex.DecodeString(s); err == nil && len(bs) == size {
+		return bs
+	}
+	if bs, err := base64.StdEncoding.DecodeString(s); err == nil && len(bs) == size {
+		return bs
+	}
+	return []byte(s)
+}
+
+func (d Download) verify() error {
+	total := int64(0)
+	for _, seg := range d.state.Segments {
+		total += seg.Done
+	}
+	fi, err := d.file.Stat()
+	if err != nil {
+		return err
+	}
+	if d.state.Size >= 0 && (total != d.state.Size || fi.Size() != d.state.Size) {
+		return fmt.Errorf("%w: expected %d bytes, got %d", ErrDownloadSize, d.state.Size, total)
+	}
+	expected := map[string][]byte{}
+	if d.SHA256 != "" {
+		expected["sha-256"] = decodeDigest(d.SHA256, sha256.Size)
+	}
+	if d.MD5 != "" {
+		expected["md5"] = decodeDigest(d.MD5, md5.Size)
+	}
+	if *d.VerifyDigestHeader {
+		for _, item := range strings.Split(d.state.Digest, ",") {
+			if algorithm, value, ok := strings.Cut(strings.TrimSpace(item), "="); ok {
+				algorithm = strings.ToLower(algorithm)
+				if _, has := expected[algorithm]; !has {
+					switch algorithm {
+					case "sha-256":
+						expected[algorithm] = decodeDigest(value, sha256.Size)
+					case "md5":
+						expected[algorithm] = decodeDigest(value, md5.Size)
+					}
+				}
+			}
+		}
+		if _, has := expected["md5"]; !has && d.state.ContentMD5 != "" {
+			expected["md5"] = decodeDigest(d.state.ContentMD5, md5.Size)
+		}
+	}
+	if len(expected) == 0 {
+		return nil
+	}
+	sha256Hash, md5Hash := sha256.New(), md5.New()
+	if _, err := io.Copy(io.MultiWriter(sha256Hash, md5Hash), io.NewSectionReader(d.file, 0, fi.Size())); err != nil {
+		return err
+	}
+	d.SHA256Sum = hex.EncodeToString(sha256Hash.Sum(nil))
+	d.MD5Sum = hex.EncodeToString(md5Hash.Sum(nil))
+	for algorithm, hashes := range map[string]hash.Hash{"sha-256": sha256Hash, "md5": md5Hash} {
+		if want, has := expected[algorithm]; has && string(want) != string(hashes.Sum(nil)) {
+			return fmt.Errorf("%w: %s", ErrDownloadChecksum, algorithm)
+		}
+	}
+	return nil
+}
+
+func (d Download) run() {
+	d.generateDefaults()
+	encodedURL, err := d.Request.encodedURL()
+	if err != nil {
+		panic(err)
+	}
+	if *d.Resume && d.loadState(encodedURL) {
+		d.Resumed = true
+	} else {
+		d.freshState(encodedURL)
+	}
+	d.file, err = os.OpenFile(d.tempPath(), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		panic(err)
+	}
+	defer func() {
+		if d.file != nil {
+			_ = d.file.Close()
+		}
+	}()
+	prepare := func() {
+		if d.Resumed && len(d.state.Segments) == 1 {
+			if err := d.file.Truncate(d.state.Segments[0].Done); err != nil {
+				panic(err)
+			}
+		}
+		if !d.Resumed {
+			if err := d.file.Truncate(0); err != nil {
+				panic(err)
+			}
+			if len(d.state.Segments) > 1 {
+				if err := d.file.Truncate(d.state.Size); err != nil {
+					panic(err)
+				}
+			}
+		}
+		if d.OnProgress != nil {
+			d.progress = newProgressTracker(d.OnProgress, *d.ProgressInterval, d.state.Size, d.downloaded.Load())
+		}
+		d.saveState()
+	}
+	prepare()
+	err = d.fetchAll()
+	if errors.Is(err, errRangeIgnored) && !d.cancelled.Load() {
+		d.Resumed = false
+		d.freshState(encodedURL)
+		prepare()
+		err = d.fetchAll()
+	}
+	d.progress.finish()
+	if err != nil {
+		panic(err)
+	}
+	if err := d.verify(); err != nil {
+		_ = d.file.Close()
+		d.file = nil
+		_ = os.Remove(d.tempPath())
+		_ = os.Remove(d.statePath())
+		panic(err)
+	}
+	if err := d.file.Sync(); err != nil {
+		panic(err)
+	}
+	_ = d.file.Close()
+	d.file = nil
+	if err := os.Rename(d.tempPath(), d.Path); err != nil {
+		panic(err)
+	}
+	_ = os.Remove(d.statePath())
+	d.Size = d.state.Size
+	d.ETag = d.state.ETag
+}
+
+func (d Download) init() Download {
+	d.task = task.NewOnceTask(promise.Job[Download]{
+		Do: func(rs promise.Resolver[Download], re promise.Rejector) {
+			d.run()
+			rs.ResolveValue(d)
+		},
+	})
+	return d
+}
+
+func NewDownload(request Request, path string, init ...func(Download)) Download {
+	return util.New((&_Download{Request: request, Path: path}).init(), init...)
+}
+
+func (d Download) Start() promise.Promise[Download] {
+	return d.task.Do()
+}
@@ -0,0 +1,395 @@
+package http
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"github.com/TelephoneTan/GoHTTPRequest/net/http/header"
+	"github.com/TelephoneTan/GoHTTPRequest/net/http/method"
+	"github.com/TelephoneTan/GoHTTPRequest/net/mime"
+	"io"
+	stdnet "net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const harBase64 = "base64"
+
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type HARCookie struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Path     string `json:"path,omitempty"`
+	Domain   string `json:"domain,omitempty"`
+	Expires  string `json:"expires,omitempty"`
+	HTTPOnly bool   `json:"httpOnly,omitempty"`
+	Secure   bool   `json:"secure,omitempty"`
+}
+
+type HARPostData struct {
+	MimeType string         `json:"mimeType"`
+	Params   []HARNameValue `json:"params"`
+	Text     string         `json:"text"`
+	Encoding string         `json:"_encoding,omitempty"`
+	Comment  string         `json:"comment,omitempty"`
+}
+
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARCookie    `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type HARContent struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARCookie    `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type HARTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+	SSL     float64 `json:"ssl"`
+}
+
+type HAREntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+	ServerIPAddress string      `json:"serverIPAddress,omitempty"`
+	Connection      string      `json:"connection,omitempty"`
+}
+
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type HARLog struct {
+	Version string     `json:"version"`
+	Creator HARCreator `json:"creator"`
+	Entries []HAREntry `json:"entries"`
+}
+
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+func harMilliseconds(d PreciseDuration) float64 {
+	return float64(time.Duration(d)) / float64(time.Millisecond)
+}
+
+func harHeaders(list [][]string) []HARNameValue {
+	headers := []HARNameValue{}
+	for _, kv := range list {
+		if len(kv) > 0 {
+			nv := HARNameValue{Name: kv[0]}
+			if len(kv) > 1 {
+				nv.Value = kv[1]
+			}
+			headers = append(headers, nv)
+		}
+	}
+	return headers
+}
+
+func harCookies(cookies []*http.Cookie) []HARCookie {
+	list := []HARCookie{}
+	for _, c := range cookies {
+		hc := HARCookie{Name: c.Name, Value: c.Value, Path: c.Path, Domain: c.Domain, HTTPOnly: c.HttpOnly, Secure: c.Secure}
+		if !c.Expires.IsZero() {
+			hc.Expires = c.Expires.UTC().Format(time.RFC3339)
+		}
+		list = append(list, hc)
+	}
+	return list
+}
+
+func harText(bs []byte) (text string, encoded bool) {
+	if utf8.Valid(bs) {
+		return string(bs), false
+	}
+	return base64.StdEncoding.EncodeToString(bs), true
+}
+
+func harPairs(raw string) []HARNameValue {
+	pairs := []HARNameValue{}
+	for _, kv := range strings.Split(raw, "&") {
+		if kv == "" {
+			continue
+		}
+		k, v, _ := strings.Cut(kv, "=")
+		k, _ = url.QueryUnescape(k)
+		v, _ = url.QueryUnescape(v)
+		pairs = append(pairs, HARNameValue{Name: k, Value: v})
+	}
+	return pairs
+}
+
+func (r Request) harRequestHeaders() [][]string {
+	var list [][]string
+	if ct := r.calContentType(); ct != "" {
+		list = append(list, []string{header.ContentType, ct})
+	}
+	return append(list, r.CustomizedHeaderList...)
+}
+
+func (r Request) harRequestBody() []byte {
+	switch {
+	case len(r.RequestBinary) > 0:
+		return r.RequestBinary
+	case r.RequestString != "":
+		return []byte(r.RequestString)
+	}
+	return nil
+}
+
+func harRequest(m, rawURL string, headers [][]string, body []byte, contentType string) HARRequest {
+	requestHeader := http.Header{}
+	for _, kv := range headers {
+		if len(kv) > 1 {
+			requestHeader.Add(kv[0], kv[1])
+		}
+	}
+	request := HARRequest{
+		Method:      m,
+		URL:         rawURL,
+		HTTPVersion: "HTTP/1.1",
+		Cookies:     harCookies((&http.Request{Header: requestHeader}).Cookies()),
+		Headers:     harHeaders(headers),
+		QueryString: []HARNameValue{},
+		HeadersSize: -1,
+		BodySize:    0,
+	}
+	if u, err := url.Parse(rawURL); err == nil {
+		request.QueryString = harPairs(u.RawQuery)
+	}
+	if body != nil {
+		postData := &HARPostData{MimeType: contentType, Params: []HARNameValue{}}
+		text, encoded := harText(body)
+		postData.Text = text
+		if encoded {
+			postData.Encoding = harBase64
+		}
+		if strings.HasPrefix(postData.MimeType, string(mime.XWWWFormURLEncoded)) {
+			postData.Params = harPairs(string(body))
+		}
+		request.PostData = postData
+		request.BodySize = int64(len(body))
+	}
+	return request
+}
+
+func harResponse(statusCode int, statusMessage string, headers [][]string, body []byte) HARResponse {
+	responseHeader := http.Header{}
+	for _, kv := range headers {
+		if len(kv) > 1 {
+			responseHeader.Add(kv[0], kv[1])
+		}
+	}
+	response := HARResponse{
+		Status:      statusCode,
+		StatusText:  strings.TrimSpace(strings.TrimPrefix(statusMessage, strconv.Itoa(statusCode))),
+		HTTPVersion: "HTTP/1.1",
+		Cookies:     harCookies((&http.Response{Header: responseHeader}).Cookies()),
+		Headers:     harHeaders(headers),
+		Content:     HARContent{Size: int64(len(body)), MimeType: responseHeader.Get(header.ContentType)},
+		RedirectURL: responseHeader.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    int64(len(body)),
+	}
+	if len(body) > 0 {
+		text, encoded := harText(body)
+		response.Content.Text = text
+		if encoded {
+			response.Content.Encoding = harBase64
+		}
+	}
+	return response
+}
+
+func (r Request) HAREntries() ([]HAREntry, error) {
+	encodedURL, err := r.encodedURL()
+	if err != nil {
+		return nil, err
+	}
+	originalMethod := r.generateRequestMethod()
+	body := r.harRequestBody()
+	bodyFor := func(m string) []byte {
+		if m != originalMethod && (m == string(*method.GET) || m == string(*method.HEAD)) {
+			return nil
+		}
+		return body
+	}
+	started := ""
+	if r.Timing != nil {
+		started = r.Timing.Start.UTC().Format(time.RFC3339Nano)
+	}
+	var entries []HAREntry
+	for _, hop := range r.RedirectHistory {
+		entries = append(entries, HAREntry{
+			StartedDateTime: started,
+			Request:         harRequest(hop.Method, hop.URL, hop.RequestHeaderList, bodyFor(hop.Method), r.calContentType()),
+			Response:        harResponse(hop.StatusCode, hop.StatusMessage, hop.HeaderList, nil),
+			Timings:         HARTimings{Blocked: -1, DNS: -1, Connect: -1, SSL: -1},
+		})
+	}
+	m, finalURL, requestHeaders := originalMethod, encodedURL, r.harRequestHeaders()
+	if r.FinalMethod != "" {
+		m = r.FinalMethod
+	}
+	if r.FinalURL != "" {
+		finalURL = r.FinalURL
+	}
+	if r.RequestHeaderList != nil {
+		requestHeaders = r.RequestHeaderList
+	}
+	entry := HAREntry{
+		StartedDateTime: started,
+		Request:         harRequest(m, finalURL, requestHeaders, bodyFor(m), r.calContentType()),
+		Response:        harResponse(r.StatusCode, r.StatusMessage, r.ResponseHeaderList, r.ResponseBinary.bin),
+		Timings:         HARTimings{Blocked: -1, DNS: -1, Connect: -1, SSL: -1},
+	}
+	if t := r.Timing; t != nil {
+		entry.Time = harMilliseconds(t.Total)
+		if !t.ConnectionReused {
+			entry.Timings.DNS = harMilliseconds(t.DNS)
+			entry.Timings.Connect = harMilliseconds(t.Connect + t.TLSHandshake)
+			if t.TLSHandshake > 0 {
+				entry.Timings.SSL = harMilliseconds(t.TLSHandshake)
+			}
+		}
+		entry.Timings.Wait = harMilliseconds(t.ServerProcessing)
+		entry.Timings.Receive = harMilliseconds(t.Transfer)
+		if host, port, err := stdnet.SplitHostPort(t.RemoteAddress); err == nil {
+			entry.ServerIPAddress = host
+			entry.Connection = port
+		}
+	}
+	return append(entries, entry), nil
+}
+
+func (r Request) HAREntry() (HAREntry, error) {
+	entries, err := r.HAREntries()
+	if err != nil {
+		return HAREntry{}, err
+	}
+	return entries[len(entries)-1], nil
+}
+
+func ExportHAR(requests ...Request) (HAR, error) {
+	har := HAR{Log: HARLog{
+		Version: "1.2",
+		Creator: HARCreator{Name: "GoHTTPRequest", Version: "1"},
+		Entries: []HAREntry{},
+	}}
+	for _, r := range requests {
+		entries, err := r.HAREntries()
+		if err != nil {
+			return HAR{}, err
+		}
+		har.Log.Entries = append(har.Log.Entries, entries...)
+	}
+	return har, nil
+}
+
+func (h HAR) Write(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(h)
+}
+
+func ParseHAR(reader io.Reader) (HAR, error) {
+	har := HAR{}
+	err := json.NewDecoder(reader).Decode(&har)
+	return har, err
+}
+
+var harSkippedHeaders = map[string]bool{
+	"host":              true,
+	"content-length":    true,
+	"connection":        true,
+	"keep-alive":        true,
+	"transfer-encoding": true,
+	"accept-encoding":   true,
+	"upgrade":           true,
+}
+
+func (e HAREntry) ToRequest() Request {
+	return NewRequest(func(request Request) {
+		m := method.Method(strings.ToUpper(e.Request.Method))
+		request.Method = &m
+		request.URL = e.Request.URL
+		preserveRawQuery := true
+		request.PreserveRawQuery = &preserveRawQuery
+		for _, h := range e.Request.Headers {
+			name := strings.ToLower(h.Name)
+			if strings.HasPrefix(name, ":") || harSkippedHeaders[name] {
+				continue
+			}
+			if name == "content-type" {
+				request.RequestContentTypeHeader = h.Value
+				continue
+			}
+			request.CustomizedHeaderList = append(request.CustomizedHeaderList, []string{h.Name, h.Value})
+		}
+		if postData := e.Request.PostData; postData != nil {
+			if postData.MimeType != "" {
+				request.RequestContentTypeHeader = postData.MimeType
+			}
+			switch {
+			case postData.Text == "" && len(postData.Params) > 0:
+				for _, p := range postData.Params {
+					request.RequestForm = append(request.RequestForm, []string{p.Name, p.Value})
+				}
+			case postData.Encoding == harBase64 || postData.Comment == harBase64:
+				if bs, err := base64.StdEncoding.DecodeString(postData.Text); err == nil {
+					request.RequestBinary = bs
+				}
+			default:
+				request.RequestString = postData.Text
+			}
+		}
+	})
+}
+
+func (h HAR) Requests() []Request {
+	var requests []Request
+	for _, e := range h.Log.Entries {
+		requests = append(requests, e.ToRequest())
+	}
+	return requests
+}
@@ -7,4 +7,17 @@ const (
 	ContentLength   Header = "Content-Length"
 	ContentEncoding Header = "Content-Encoding"
 	Referer         Header = "Referer"
+	Range           Header = "Range"
+	IfRange         Header = "If-Range"
+	ContentRange    Header = "Content-Range"
+	AcceptRanges    Header = "Accept-Ranges"
+	ETag            Header = "ETag"
+	LastModified    Header = "Last-Modified"
+	Digest          Header = "Digest"
+	ContentMD5      Header = "Content-MD5"
+	Authorization   Header = "Authorization"
+	WWWAuthenticate Header = "WWW-Authenticate"
+	Cookie          Header = "Cookie"
+	Location        Header = "Location"
+	UserAgent       Header = "User-Agent"
 )
@@ -0,0 +1,512 @@
+package http
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/TelephoneTan/GoHTTPRequest/net/http/header"
+	"github.com/TelephoneTan/GoHTTPRequest/net/http/method"
+	"github.com/TelephoneTan/GoHTTPRequest/net/mime"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+	"mime/multipart"
+	"net/url"
+	"strings"
+	"unicode"
+)
+
+const (
+	FormURLEncoded = "application/x-www-form-urlencoded"
+	FormMultipart  = "multipart/form-data"
+	FormTextPlain  = "text/plain"
+)
+
+var (
+	ErrFormNotFound  = errors.New("form not found")
+	ErrFormSubmitter = errors.New("form submitter not found")
+)
+
+type HTMLFormField struct {
+	Name     string
+	Type     string
+	Value    string
+	Options  []string
+	Checked  bool
+	Disabled bool
+	Multiple bool
+}
+
+type formFile struct {
+	name     string
+	filename string
+	content  []byte
+}
+
+type _HTMLForm struct {
+	ID      string
+	Name    string
+	Action  string
+	Method  string
+	Enctype string
+	Fields  []HTMLFormField
+	//
+	node      *html.Node
+	selection HTMLSelection
+	entries   [][]string
+	files     []formFile
+	submitter *html.Node
+}
+
+type HTMLForm = *_HTMLForm
+
+func documentRoot(n *html.Node) *html.Node {
+	for n.Parent != nil {
+		n = n.Parent
+	}
+	return n
+}
+
+func disabledByFieldset(n *html.Node) bool {
+	for p := n.Parent; p != nil; p = p.Parent {
+		if p.Type == html.ElementNode && p.Data == "fieldset" {
+			if _, disabled := nodeAttr(p, "disabled"); disabled {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isFormControl(n *html.Node) bool {
+	switch n.Data {
+	case "input", "select", "textarea", "button":
+		return true
+	}
+	return false
+}
+
+func formControls(form *html.Node) []*html.Node {
+	id, hasID := nodeAttr(form, "id")
+	var controls []*html.Node
+	walkElements(documentRoot(form), func(n *html.Node) bool {
+		if !isFormControl(n) {
+			return true
+		}
+		if owner, has := nodeAttr(n, "form"); has {
+			if hasID && owner == id {
+				controls = append(controls, n)
+			}
+			return true
+		}
+		for p := n.Parent; p != nil; p = p.Parent {
+			if p == form {
+				controls = append(controls, n)
+				break
+			}
+			if p.Type == html.ElementNode && p.Data == "form" {
+				break
+			}
+		}
+		return true
+	})
+	return controls
+}
+
+func nodeText(n *html.Node) string {
+	sb := strings.Builder{}
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			sb.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return sb.String()
+}
+
+func controlType(n *html.Node) string {
+	switch n.Data {
+	case "input":
+		t, _ := nodeAttr(n, "type")
+		if t = strings.ToLower(strings.TrimSpace(t)); t == "" {
+			return "text"
+		}
+		return t
+	case "button":
+		t, _ := nodeAttr(n, "type")
+		if t = strings.ToLower(strings.TrimSpace(t)); t == "" {
+			return "submit"
+		}
+		return t
+	}
+	return n.Data
+}
+
+func isSubmitter(n *html.Node) bool {
+	switch controlType(n) {
+	case "submit", "image":
+		return n.Data == "input" || n.Data == "button"
+	}
+	return false
+}
+
+func selectOptions(n *html.Node) (options []string, selected []string) {
+	var first *string
+	walkElements(n, func(o *html.Node) bool {
+		if o.Data != "option" {
+			return true
+		}
+		value, has := nodeAttr(o, "value")
+		if !has {
+			value = normalizeSpace(nodeText(o))
+		}
+		options = append(options, value)
+		if first == nil {
+			first = &value
+		}
+		if _, isSelected := nodeAttr(o, "selected"); isSelected {
+			selected = append(selected, value)
+		}
+		return true
+	})
+	if _, multiple := nodeAttr(n, "multiple"); len(selected) == 0 && !multiple && first != nil {
+		selected = []string{*first}
+	}
+	return options, selected
+}
+
+func (s HTMLSelection) newForm(n *html.Node) HTMLForm {
+	f := &_HTMLForm{node: n, selection: s}
+	f.ID, _ = nodeAttr(n, "id")
+	f.Name, _ = nodeAttr(n, "name")
+	f.Method, f.Enctype, f.Action = f.submission(nil)
+	radios := map[string]int{}
+	for _, c := range formControls(n) {
+		name, _ := nodeAttr(c, "name")
+		_, disabled := nodeAttr(c, "disabled")
+		field := HTMLFormField{Name: name, Type: controlType(c), Disabled: disabled || disabledByFieldset(c)}
+		_, field.Multiple = nodeAttr(c, "multiple")
+		switch c.Data {
+		case "select":
+			options, selected := selectOptions(c)
+			field.Options = options
+			if len(selected) > 0 {
+				field.Value = selected[0]
+			}
+			if !field.Disabled && name != "" {
+				for _, v := range selected {
+					f.entries = append(f.entries, []string{name, v})
+				}
+			}
+		case "textarea":
+			field.Value = strings.TrimPrefix(strings.ReplaceAll(nodeText(c), "\r\n", "\n"), "\n")
+			if !field.Disabled && name != "" {
+				f.entries = append(f.entries, []string{name, field.Value})
+			}
+		default:
+			value, hasValue := nodeAttr(c, "value")
+			_, field.Checked = nodeAttr(c, "checked")
+			if (field.Type == "checkbox" || field.Type == "radio") && !hasValue {
+				value = "on"
+			}
+			field.Value = value
+			if field.Type == "radio" {
+				if i, has := radios[name]; has && name != "" {
+					f.Fields[i].Options = append(f.Fields[i].Options, value)
+					if field.Checked {
+						f.Fields[i].Value, f.Fields[i].Checked = value, true
+					}
+					if field.Checked && !field.Disabled {
+						f.Del(name)
+						f.entries = append(f.entries, []string{name, value})
+					}
+					continue
+				}
+				radios[name] = len(f.Fields)
+				field.Options = []string{value}
+			}
+			if field.Disabled || name == "" {
+				break
+			}
+			switch field.Type {
+			case "submit", "image", "button", "reset", "file":
+			case "checkbox", "radio":
+				if field.Checked {
+					f.entries = append(f.entries, []string{name, value})
+				}
+			default:
+				f.entries = append(f.entries, []string{name, value})
+			}
+		}
+		f.Fields = append(f.Fields, field)
+	}
+	return f
+}
+
+func (s HTMLSelection) Forms() []HTMLForm {
+	var forms []HTMLForm
+	seen := map[*html.Node]bool{}
+	for _, root := range s.nodes {
+		walkElements(root, func(n *html.Node) bool {
+			if n.Data == "form" && !seen[n] {
+				seen[n] = true
+				forms = append(forms, s.newForm(n))
+			}
+			return true
+		})
+	}
+	return forms
+}
+
+func (s HTMLSelection) Form(selector string) (HTMLForm, error) {
+	compiled, err := CompileSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	for _, form := range s.Forms() {
+		if compiled.Match(form.node) {
+			return form, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrFormNotFound, selector)
+}
+
+func (f HTMLForm) submission(submitter *html.Node) (m string, enctype string, action string) {
+	attr := func(formName, name string) string {
+		if submitter != nil {
+			if v, has := nodeAttr(submitter, formName); has {
+				return v
+			}
+		}
+		v, _ := nodeAttr(f.node, name)
+		return v
+	}
+	switch m = strings.ToUpper(strings.TrimSpace(attr("formmethod", "method"))); m {
+	case "POST":
+	default:
+		m = "GET"
+	}
+	switch enctype = strings.ToLower(strings.TrimSpace(attr("formenctype", "enctype"))); enctype {
+	case FormMultipart, FormTextPlain:
+	default:
+		enctype = FormURLEncoded
+	}
+	action = f.selection.pageURL
+	if ref := strings.TrimSpace(attr("formaction", "action")); ref != "" {
+		if resolved, ok := f.selection.resolve(ref); ok {
+			action = resolved
+		}
+	}
+	return m, enctype, action
+}
+
+func (f HTMLForm) Get(name string) string {
+	for _, kv := range f.entries {
+		if kv[0] == name {
+			return kv[1]
+		}
+	}
+	return ""
+}
+
+func (f HTMLForm) Values() [][]string {
+	values := make([][]string, 0, len(f.entries))
+	for _, kv := range f.entries {
+		values = append(values, []string{kv[0], kv[1]})
+	}
+	return values
+}
+
+func (f HTMLForm) Del(name string) HTMLForm {
+	entries := f.entries[:0]
+	for _, kv := range f.entries {
+		if kv[0] != name {
+			entries = append(entries, kv)
+		}
+	}
+	f.entries = entries
+	return f
+}
+
+func (f HTMLForm) Add(name, value string) HTMLForm {
+	f.entries = append(f.entries, []string{name, value})
+	return f
+}
+
+func (f HTMLForm) Set(name, value string) HTMLForm {
+	for i, kv := range f.entries {
+		if kv[0] == name {
+			f.entries[i] = []string{name, value}
+			return f.dedupe(name, i)
+		}
+	}
+	return f.Add(name, value)
+}
+
+func (f HTMLForm) dedupe(name string, keep int) HTMLForm {
+	entries := make([][]string, 0, len(f.entries))
+	for i, kv := range f.entries {
+		if kv[0] != name || i == keep {
+			entries = append(entries, kv)
+		}
+	}
+	f.entries = entries
+	return f
+}
+
+func (f HTMLForm) SetFile(name, filename string, content []byte) HTMLForm {
+	f.Del(name)
+	files := f.files[:0]
+	for _, file := range f.files {
+		if file.name != name {
+			files = append(files, file)
+		}
+	}
+	f.files = append(files, formFile{name: name, filename: filename, content: content})
+	f.Enctype = FormMultipart
+	return f
+}
+
+func (f HTMLForm) Click(name string) (HTMLForm, error) {
+	for _, c := range formControls(f.node) {
+		if n, _ := nodeAttr(c, "name"); isSubmitter(c) && (n == name || name == "" && n == "") {
+			f.submitter = c
+			enctype := f.Enctype
+			f.Method, f.Enctype, f.Action = f.submission(c)
+			if enctype == FormMultipart {
+				f.Enctype = enctype
+			}
+			return f, nil
+		}
+	}
+	return f, fmt.Errorf("%w: %q", ErrFormSubmitter, name)
+}
+
+func (f HTMLForm) submitterEntries() [][]string {
+	if f.submitter == nil {
+		return nil
+	}
+	name, hasName := nodeAttr(f.submitter, "name")
+	if controlType(f.submitter) == "image" {
+		prefix := ""
+		if hasName && name != "" {
+			prefix = name + "."
+		}
+		return [][]string{{prefix + "x", "0"}, {prefix + "y", "0"}}
+	}
+	if !hasName || name == "" {
+		return nil
+	}
+	value, _ := nodeAttr(f.submitter, "value")
+	return [][]string{{name, value}}
+}
+
+func (f HTMLForm) encoder() (*encoding.Encoder, string) {
+	acceptCharset, _ := nodeAttr(f.node, "accept-charset")
+	labels := strings.FieldsFunc(acceptCharset, func(c rune) bool {
+		return c == ',' || unicode.IsSpace(c)
+	})
+	for _, label := range append(labels, f.selection.charset) {
+		e, name := charset.Lookup(label)
+		switch {
+		case e == nil:
+		case name == "utf-8" || name == "utf-16be" || name == "utf-16le":
+			return nil, "utf-8"
+		default:
+			return encoding.HTMLEscapeUnsupported(e.NewEncoder()), name
+		}
+	}
+	return nil, "utf-8"
+}
+
+func (f HTMLForm) Request(init ...func(Request)) (Request, error) {
+	entries := append(f.Values(), f.submitterEntries()...)
+	e, charsetName := f.encoder()
+	if e != nil {
+		for _, kv := range entries {
+			for i, s := range kv {
+				encoded, err := e.String(s)
+				if err != nil {
+					return nil, err
+				}
+				kv[i] = encoded
+			}
+		}
+	}
+	source := f.selection.request
+	request := NewRequest(func(request Request) {
+		if source != nil {
+			request.CookieJar = source.CookieJar
+			request.CookieJarTag = source.CookieJarTag
+			request.Proxy = source.Proxy
+			request.Transport = source.Transport
+			request.InsecureSkipVerify = source.InsecureSkipVerify
+		}
+		if referer := f.selection.pageURL; referer != "" {
+			if u, err := url.Parse(referer); err == nil {
+				u.Fragment, u.RawFragment, u.User = "", "", nil
+				request.CustomizedHeaderList = append(request.CustomizedHeaderList, []string{header.Referer, u.String()})
+			}
+		}
+	})
+	action, err := url.Parse(f.Action)
+	if err != nil {
+		return nil, err
+	}
+	action.Fragment, action.RawFragment = "", ""
+	if f.Method != "POST" {
+		request.Method = method.GET
+		action.RawQuery = ""
+		request.URL = action.String()
+		request.QueryList = entries
+	} else {
+		request.Method = method.POST
+		request.URL = action.String()
+		switch f.Enctype {
+		case FormMultipart:
+			body := &bytes.Buffer{}
+			writer := multipart.NewWriter(body)
+			for _, kv := range entries {
+				if err := writer.WriteField(kv[0], kv[1]); err != nil {
+					return nil, err
+				}
+			}
+			for _, file := range f.files {
+				w, err := writer.CreateFormFile(file.name, file.filename)
+				if err != nil {
+					return nil, err
+				}
+				if _, err := w.Write(file.content); err != nil {
+					return nil, err
+				}
+			}
+			if err := writer.Close(); err != nil {
+				return nil, err
+			}
+			request.RequestBinary = body.Bytes()
+			request.RequestContentTypeHeader = writer.FormDataContentType()
+		case FormTextPlain:
+			sb := strings.Builder{}
+			for _, kv := range entries {
+				sb.WriteString(kv[0] + "=" + kv[1] + "\r\n")
+			}
+			request.RequestString = sb.String()
+			request.RequestContentType = &mime.TextPlainUTF8
+			if e != nil {
+				request.RequestContentType = nil
+				request.RequestContentTypeHeader = FormTextPlain + "; charset=" + charsetName
+			}
+		default:
+			request.RequestForm = entries
+			request.RequestContentType = &mime.XWWWFormURLEncoded
+		}
+	}
+	if len(init) > 0 {
+		init[0](request)
+	}
+	return request, nil
+}
@@ -0,0 +1,255 @@
+package http
+
+import (
+	"bytes"
+	"github.com/TelephoneTan/GoPromise/async/promise"
+	"golang.org/x/net/html"
+	"net/url"
+	"strings"
+)
+
+type _HTMLSelection struct {
+	nodes   []*html.Node
+	baseURL *url.URL
+	pageURL string
+	charset string
+	request Request
+}
+
+type HTMLSelection = *_HTMLSelection
+
+func documentBaseURL(document *html.Node, baseURL string) *url.URL {
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		base = nil
+	}
+	walkElements(document, func(n *html.Node) bool {
+		if n.Data != "base" {
+			return true
+		}
+		href, has := nodeAttr(n, "href")
+		if !has {
+			return true
+		}
+		if u, err := url.Parse(strings.TrimSpace(href)); err == nil {
+			if base != nil {
+				u = base.ResolveReference(u)
+			}
+			base = u
+		}
+		return false
+	})
+	return base
+}
+
+func NewHTMLSelection(document *html.Node, baseURL string) HTMLSelection {
+	return &_HTMLSelection{nodes: []*html.Node{document}, baseURL: documentBaseURL(document, baseURL), pageURL: baseURL}
+}
+
+func ParseHTMLSelection(source string, baseURL string) (HTMLSelection, error) {
+	document, err := html.Parse(strings.NewReader(source))
+	if err != nil {
+		return nil, err
+	}
+	return NewHTMLSelection(document, baseURL), nil
+}
+
+func (r Request) HTMLSelection() promise.Promise[Result[HTMLSelection]] {
+	return promise.Then(r.HTMLDocument(), promise.FulfilledListener[Result[*html.Node], Result[HTMLSelection]]{
+		OnFulfilled: func(docRes Result[*html.Node]) any {
+			baseURL := r.FinalURL
+			if baseURL == "" {
+				encodedURL, err := r.encodedURL()
+				if err != nil {
+					panic(err)
+				}
+				baseURL = encodedURL
+			}
+			selection := NewHTMLSelection(docRes.Result, baseURL)
+			selection.request = r
+			if docRes.Charset != nil {
+				selection.charset = docRes.Charset.Name
+			}
+			return Result[HTMLSelection]{
+				Request: r,
+				Result:  selection,
+				Charset: docRes.Charset,
+			}
+		},
+	})
+}
+
+func (s HTMLSelection) derive(nodes []*html.Node) HTMLSelection {
+	return &_HTMLSelection{nodes: nodes, baseURL: s.baseURL, pageURL: s.pageURL, charset: s.charset, request: s.request}
+}
+
+func (s HTMLSelection) find(selector Selector, first bool) HTMLSelection {
+	var nodes []*html.Node
+	seen := map[*html.Node]bool{}
+	for _, root := range s.nodes {
+		walkElements(root, func(n *html.Node) bool {
+			if n == root || seen[n] || !selector.Match(n) {
+				return true
+			}
+			seen[n] = true
+			nodes = append(nodes, n)
+			return !first
+		})
+		if first && len(nodes) > 0 {
+			break
+		}
+	}
+	return s.derive(nodes)
+}
+
+func (s HTMLSelection) QuerySelectorAll(selector string) (HTMLSelection, error) {
+	compiled, err := CompileSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	return s.find(compiled, false), nil
+}
+
+func (s HTMLSelection) QuerySelector(selector string) (HTMLSelection, error) {
+	compiled, err := CompileSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	return s.find(compiled, true), nil
+}
+
+func (s HTMLSelection) Find(selector Selector) HTMLSelection {
+	return s.find(selector, false)
+}
+
+func (s HTMLSelection) Filter(selector Selector) HTMLSelection {
+	var nodes []*html.Node
+	for _, n := range s.nodes {
+		if selector.Match(n) {
+			nodes = append(nodes, n)
+		}
+	}
+	return s.derive(nodes)
+}
+
+func (s HTMLSelection) Nodes() []*html.Node {
+	return append([]*html.Node{}, s.nodes...)
+}
+
+func (s HTMLSelection) Len() int {
+	return len(s.nodes)
+}
+
+func (s HTMLSelection) Eq(i int) HTMLSelection {
+	if i < 0 || i >= len(s.nodes) {
+		return s.derive(nil)
+	}
+	return s.derive(s.nodes[i : i+1])
+}
+
+func (s HTMLSelection) Each(f func(i int, selection HTMLSelection)) {
+	for i := range s.nodes {
+		f(i, s.Eq(i))
+	}
+}
+
+func (s HTMLSelection) BaseURL() string {
+	if s.baseURL == nil {
+		return ""
+	}
+	return s.baseURL.String()
+}
+
+func collectText(n *html.Node, sb *strings.Builder) {
+	switch n.Type {
+	case html.TextNode:
+		sb.WriteString(n.Data)
+		return
+	case html.ElementNode:
+		switch n.Data {
+		case "script", "style", "template", "noscript":
+			return
+		case "br", "p", "div", "li", "tr", "td", "th", "h1", "h2", "h3", "h4", "h5", "h6":
+			defer sb.WriteByte(' ')
+			sb.WriteByte(' ')
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		collectText(c, sb)
+	}
+}
+
+func normalizeSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func (s HTMLSelection) Text() string {
+	sb := strings.Builder{}
+	for _, n := range s.nodes {
+		collectText(n, &sb)
+		sb.WriteByte(' ')
+	}
+	return normalizeSpace(sb.String())
+}
+
+func (s HTMLSelection) Texts() []string {
+	var texts []string
+	for i := range s.nodes {
+		texts = append(texts, s.Eq(i).Text())
+	}
+	return texts
+}
+
+func (s HTMLSelection) Attr(name string) (string, bool) {
+	if len(s.nodes) == 0 {
+		return "", false
+	}
+	return nodeAttr(s.nodes[0], name)
+}
+
+func (s HTMLSelection) Attrs(name string) []string {
+	var values []string
+	for _, n := range s.nodes {
+		if v, has := nodeAttr(n, name); has {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+func (s HTMLSelection) resolve(ref string) (string, bool) {
+	u, err := url.Parse(strings.TrimSpace(ref))
+	if err != nil {
+		return "", false
+	}
+	if s.baseURL != nil {
+		u = s.baseURL.ResolveReference(u)
+	}
+	return u.String(), true
+}
+
+func (s HTMLSelection) URL(name string) (string, bool) {
+	ref, has := s.Attr(name)
+	if !has {
+		return "", false
+	}
+	return s.resolve(ref)
+}
+
+func (s HTMLSelection) URLs(name string) []string {
+	var urls []string
+	for _, ref := range s.Attrs(name) {
+		if u, ok := s.resolve(ref); ok {
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+func (s HTMLSelection) HTML() string {
+	buffer := &bytes.Buffer{}
+	for _, n := range s.nodes {
+		_ = html.Render(buffer, n)
+	}
+	return buffer.String()
+}
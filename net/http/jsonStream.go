@@ -0,0 +1,290 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/TelephoneTan/GoPromise/async/promise"
+	"io"
+)
+
+type JSONStreamFormat string
+
+const (
+	NDJSON           JSONStreamFormat = "ndjson"
+	ConcatenatedJSON JSONStreamFormat = "concatenated"
+	JSONSeq          JSONStreamFormat = "json-seq"
+)
+
+const jsonSeqRS = 0x1e
+
+type JSONRecord[T any] struct {
+	Value T
+	Line  int
+	Index int
+}
+
+type JSONRecordError struct {
+	Line  int
+	Index int
+	Raw   []byte
+	Err   error
+}
+
+func (e *JSONRecordError) Error() string {
+	return fmt.Sprintf("malformed json record #%d at line %d: %v", e.Index, e.Line, e.Err)
+}
+
+func (e *JSONRecordError) Unwrap() error {
+	return e.Err
+}
+
+type _JSONStreamDecoder[T any] struct {
+	Format        JSONStreamFormat
+	SkipMalformed bool
+	OnMalformed   func(e *JSONRecordError)
+	//
+	reader  *bufio.Reader
+	decoder *json.Decoder
+	tracker *lineTracker
+	line    int
+	index   int
+	skipped int
+}
+
+type JSONStreamDecoder[T any] struct {
+	*_JSONStreamDecoder[T]
+}
+
+func NewJSONStreamDecoder[T any](reader io.Reader, init ...func(JSONStreamDecoder[T])) JSONStreamDecoder[T] {
+	d := JSONStreamDecoder[T]{&_JSONStreamDecoder[T]{Format: NDJSON}}
+	if len(init) > 0 {
+		init[0](d)
+	}
+	return d.init(reader)
+}
+
+func (d JSONStreamDecoder[T]) init(reader io.Reader) JSONStreamDecoder[T] {
+	if d.Format == ConcatenatedJSON {
+		d.tracker = &lineTracker{reader: reader, line: 1}
+		d.decoder = json.NewDecoder(d.tracker)
+	} else {
+		d.reader = bufio.NewReader(reader)
+	}
+	return d
+}
+
+func (d JSONStreamDecoder[T]) Skipped() int {
+	return d.skipped
+}
+
+func (d JSONStreamDecoder[T]) malformed(e *JSONRecordError, skippable bool) error {
+	if d.OnMalformed != nil {
+		d.OnMalformed(e)
+	}
+	if d.SkipMalformed && skippable {
+		d.skipped++
+		return nil
+	}
+	return e
+}
+
+func (d JSONStreamDecoder[T]) unmarshal(raw []byte, line int) (record JSONRecord[T], ok bool, err error) {
+	d.index++
+	record.Line = line
+	record.Index = d.index
+	if e := json.Unmarshal(raw, &record.Value); e != nil {
+		return record, false, d.malformed(&JSONRecordError{
+			Line:  line,
+			Index: d.index,
+			Raw:   append([]byte{}, raw...),
+			Err:   e,
+		}, true)
+	}
+	return record, true, nil
+}
+
+func (d JSONStreamDecoder[T]) nextLine() (JSONRecord[T], error) {
+	for {
+		bs, err := d.reader.ReadBytes('\n')
+		if len(bs) == 0 && err != nil {
+			return JSONRecord[T]{}, err
+		}
+		if err != nil && err != io.EOF {
+			return JSONRecord[T]{}, err
+		}
+		d.line++
+		bs = bytes.TrimSpace(bs)
+		if len(bs) == 0 {
+			continue
+		}
+		record, ok, e := d.unmarshal(bs, d.line)
+		if e != nil {
+			return record, e
+		}
+		if ok {
+			return record, nil
+		}
+	}
+}
+
+func (d JSONStreamDecoder[T]) nextSeq() (JSONRecord[T], error) {
+	for {
+		bs, err := d.reader.ReadBytes(jsonSeqRS)
+		if err != nil && err != io.EOF {
+			return JSONRecord[T]{}, err
+		}
+		if len(bs) > 0 && bs[len(bs)-1] == jsonSeqRS {
+			bs = bs[:len(bs)-1]
+		}
+		start := d.line + 1
+		d.line += bytes.Count(bs, []byte{'\n'})
+		if trimmed := bytes.TrimSpace(bs); len(trimmed) > 0 {
+			start += bytes.Count(bs[:bytes.Index(bs, trimmed)], []byte{'\n'})
+			var record JSONRecord[T]
+			var ok bool
+			var e error
+			if bs[len(bs)-1] != '\n' && bytes.IndexByte([]byte("{[\""), trimmed[0]) < 0 {
+				d.index++
+				e = d.malformed(&JSONRecordError{
+					Line:  start,
+					Index: d.index,
+					Raw:   append([]byte{}, trimmed...),
+					Err:   errors.New("truncated json-seq record"),
+				}, true)
+			} else {
+				record, ok, e = d.unmarshal(trimmed, start)
+			}
+			if e != nil {
+				return record, e
+			}
+			if ok {
+				return record, nil
+			}
+		}
+		if err == io.EOF {
+			return JSONRecord[T]{}, io.EOF
+		}
+	}
+}
+
+func (d JSONStreamDecoder[T]) nextConcatenated() (JSONRecord[T], error) {
+	for {
+		var raw json.RawMessage
+		if err := d.decoder.Decode(&raw); err != nil {
+			if err != io.EOF {
+				var syntaxErr *json.SyntaxError
+				if errors.As(err, &syntaxErr) {
+					d.index++
+					// json.Decoder cannot resume after a syntax error, so
+					// SkipMalformed does not apply to concatenated streams here.
+					return JSONRecord[T]{}, d.malformed(&JSONRecordError{
+						Line:  d.tracker.lineAt(syntaxErr.Offset),
+						Index: d.index,
+						Err:   err,
+					}, false)
+				}
+			}
+			return JSONRecord[T]{}, err
+		}
+		end := d.decoder.InputOffset()
+		line := d.tracker.lineAt(end - int64(len(raw)))
+		d.tracker.consume(end)
+		record, ok, e := d.unmarshal(raw, line)
+		if e != nil {
+			return record, e
+		}
+		if ok {
+			return record, nil
+		}
+	}
+}
+
+func (d JSONStreamDecoder[T]) Next() (JSONRecord[T], error) {
+	switch d.Format {
+	case ConcatenatedJSON:
+		return d.nextConcatenated()
+	case JSONSeq:
+		return d.nextSeq()
+	default:
+		return d.nextLine()
+	}
+}
+
+type lineTracker struct {
+	reader io.Reader
+	buf    []byte
+	base   int64
+	line   int
+}
+
+func (t *lineTracker) Read(p []byte) (int, error) {
+	n, err := t.reader.Read(p)
+	t.buf = append(t.buf, p[:n]...)
+	return n, err
+}
+
+func (t *lineTracker) lineAt(offset int64) int {
+	i := offset - t.base
+	if i < 0 {
+		i = 0
+	}
+	if i > int64(len(t.buf)) {
+		i = int64(len(t.buf))
+	}
+	return t.line + bytes.Count(t.buf[:i], []byte{'\n'})
+}
+
+func (t *lineTracker) consume(offset int64) {
+	i := offset - t.base
+	if i <= 0 {
+		return
+	}
+	t.line = t.lineAt(offset)
+	t.buf = append(t.buf[:0], t.buf[i:]...)
+	t.base = offset
+}
+
+func JSONStream[T any](r Request, onRecord func(record JSONRecord[T]) bool, init ...func(JSONStreamDecoder[T])) promise.Promise[Result[int]] {
+	return promise.Then(r.Stream(), promise.FulfilledListener[Result[Stream], Result[int]]{
+		OnFulfilled: func(streamRes Result[Stream]) any {
+			defer streamRes.Result.Done()
+			d := NewJSONStreamDecoder[T](streamRes.Result.Reader, init...)
+			n := 0
+			for {
+				record, err := d.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					panic(err)
+				}
+				n++
+				if onRecord != nil && !onRecord(record) {
+					break
+				}
+			}
+			return Result[int]{
+				Request: r,
+				Result:  n,
+			}
+		},
+	})
+}
+
+func JSONStreamAll[T any](r Request, init ...func(JSONStreamDecoder[T])) promise.Promise[Result[[]T]] {
+	var all []T
+	return promise.Then(JSONStream[T](r, func(record JSONRecord[T]) bool {
+		all = append(all, record.Value)
+		return true
+	}, init...), promise.FulfilledListener[Result[int], Result[[]T]]{
+		OnFulfilled: func(nRes Result[int]) any {
+			return Result[[]T]{
+				Request: r,
+				Result:  all,
+			}
+		},
+	})
+}
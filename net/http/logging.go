@@ -0,0 +1,315 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/TelephoneTan/GoHTTPRequest/util"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type LogVerbosity int
+
+const (
+	LogBasic LogVerbosity = iota
+	LogHeaders
+	LogBodies
+)
+
+const redacted = "[REDACTED]"
+
+var (
+	defaultLogMaxBodySize = 4096
+	defaultRedactedFields = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+)
+
+type _RequestLogger struct {
+	Logger       *slog.Logger
+	Verbosity    LogVerbosity
+	Level        slog.Level
+	MaxBodySize  int
+	RedactFields []string
+}
+
+type RequestLogger = *_RequestLogger
+
+func NewRequestLogger(logger *slog.Logger, init ...func(RequestLogger)) RequestLogger {
+	return util.New(&_RequestLogger{Logger: logger, Level: slog.LevelInfo, MaxBodySize: defaultLogMaxBodySize}, init...)
+}
+
+func (l RequestLogger) logger() *slog.Logger {
+	if l.Logger != nil {
+		return l.Logger
+	}
+	return slog.Default()
+}
+
+func (l RequestLogger) redacts(name string) bool {
+	for _, field := range defaultRedactedFields {
+		if strings.EqualFold(field, name) {
+			return true
+		}
+	}
+	for _, field := range l.RedactFields {
+		if strings.EqualFold(field, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (l RequestLogger) redactURL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	clone := *u
+	if clone.User != nil {
+		clone.User = url.UserPassword(clone.User.Username(), redacted)
+		if _, has := u.User.Password(); !has {
+			clone.User = url.User(redacted)
+		}
+	}
+	if clone.RawQuery != "" {
+		var pairs []string
+		for _, pair := range strings.Split(clone.RawQuery, "&") {
+			k, _, has := strings.Cut(pair, "=")
+			if name, err := url.QueryUnescape(k); err == nil && has && l.redacts(name) {
+				pair = k + "=" + url.QueryEscape(redacted)
+			}
+			pairs = append(pairs, pair)
+		}
+		clone.RawQuery = strings.Join(pairs, "&")
+	}
+	return clone.String()
+}
+
+func (l RequestLogger) headers(header http.Header) slog.Attr {
+	var attrs []any
+	for k, vs := range header {
+		if l.redacts(k) {
+			attrs = append(attrs, slog.String(k, redacted))
+		} else {
+			attrs = append(attrs, slog.String(k, strings.Join(vs, ", ")))
+		}
+	}
+	return slog.Group("headers", attrs...)
+}
+
+func (l RequestLogger) redactJSON(v any) any {
+	switch x := v.(type) {
+	case map[string]any:
+		for k, vv := range x {
+			if l.redacts(k) {
+				x[k] = redacted
+			} else {
+				x[k] = l.redactJSON(vv)
+			}
+		}
+	case []any:
+		for i, vv := range x {
+			x[i] = l.redactJSON(vv)
+		}
+	}
+	return v
+}
+
+func (l RequestLogger) body(bs []byte, contentType string, truncated bool) string {
+	mediaType := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	switch {
+	case !truncated && (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json") || json.Valid(bs) && bytes.ContainsAny(bs[:1], "{[")):
+		var v any
+		if json.Unmarshal(bs, &v) == nil {
+			if redactedJSON, err := json.Marshal(l.redactJSON(v)); err == nil {
+				bs = redactedJSON
+			}
+		}
+	case mediaType == "application/x-www-form-urlencoded":
+		if values, err := url.ParseQuery(string(bs)); err == nil {
+			for k := range values {
+				if l.redacts(k) {
+					values[k] = []string{redacted}
+				}
+			}
+			bs = []byte(values.Encode())
+		}
+	}
+	s := string(bs)
+	if truncated {
+		s += "...(truncated)"
+	}
+	return s
+}
+
+func (l RequestLogger) requestBody(request *http.Request, getBody func() (io.ReadCloser, error)) (string, bool) {
+	if request.Body == nil || request.Body == http.NoBody {
+		return "", true
+	}
+	if getBody == nil {
+		return "", false
+	}
+	body, err := getBody()
+	if err != nil {
+		return "", false
+	}
+	defer func() {
+		_ = body.Close()
+	}()
+	bs, _ := io.ReadAll(io.LimitReader(body, int64(l.MaxBodySize)+1))
+	truncated := len(bs) > l.MaxBodySize
+	if truncated {
+		bs = bs[:l.MaxBodySize]
+	}
+	return l.body(bs, request.Header.Get("Content-Type"), truncated), true
+}
+
+type loggingTransport struct {
+	base    http.RoundTripper
+	logger  RequestLogger
+	attempt *atomic.Int32
+	proxy   *url.URL
+	getBody func() (io.ReadCloser, error)
+}
+
+func (t *loggingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	attempt := t.attempt.Add(1)
+	attrs := []slog.Attr{
+		slog.String("method", request.Method),
+		slog.String("url", t.logger.redactURL(request.URL)),
+		slog.Int("attempt", int(attempt)),
+	}
+	startAttrs := append([]slog.Attr{}, attrs...)
+	if request.ContentLength > 0 {
+		startAttrs = append(startAttrs, slog.Int64("request_size", request.ContentLength))
+	}
+	if t.proxy != nil {
+		startAttrs = append(startAttrs, slog.String("proxy", t.logger.redactURL(t.proxy)))
+	}
+	if t.logger.Verbosity >= LogHeaders {
+		startAttrs = append(startAttrs, t.logger.headers(request.Header))
+	}
+	if t.logger.Verbosity >= LogBodies {
+		if body, ok := t.logger.requestBody(request, t.getBody); ok && body != "" {
+			startAttrs = append(startAttrs, slog.String("body", body))
+		}
+	}
+	ctx := request.Context()
+	t.logger.logger().LogAttrs(ctx, t.logger.Level, "http request start", startAttrs...)
+	start := time.Now()
+	response, err := t.base.RoundTrip(request)
+	attrs = append(attrs, slog.Duration("duration", time.Since(start)))
+	if err != nil {
+		t.logger.logger().LogAttrs(ctx, slog.LevelError, "http request failed", append(attrs, slog.String("error", err.Error()))...)
+		return response, err
+	}
+	attrs = append(attrs, slog.Int("status", response.StatusCode))
+	if response.ContentLength >= 0 {
+		attrs = append(attrs, slog.Int64("content_length", response.ContentLength))
+	}
+	if t.logger.Verbosity >= LogHeaders {
+		attrs = append(attrs, t.logger.headers(response.Header))
+	}
+	t.logger.logger().LogAttrs(ctx, t.logger.Level, "http response", attrs...)
+	return response, err
+}
+
+type loggingReader struct {
+	reader   io.Reader
+	limit    int
+	lock     sync.Mutex
+	size     int64
+	captured bytes.Buffer
+}
+
+func (l *loggingReader) Read(p []byte) (int, error) {
+	n, err := l.reader.Read(p)
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.size += int64(n)
+	if rest := l.limit - l.captured.Len(); rest > 0 {
+		if rest > n {
+			rest = n
+		}
+		l.captured.Write(p[:rest])
+	}
+	return n, err
+}
+
+func (r Request) generateLoggingTransport(base http.RoundTripper, request *http.Request) http.RoundTripper {
+	if r.Logger == nil {
+		return base
+	}
+	t := &loggingTransport{base: base, logger: r.Logger, attempt: r.logAttempt, getBody: r.rawGetBody}
+	if transport, isTransport := base.(*http.Transport); isTransport && transport.Proxy != nil {
+		t.proxy, _ = transport.Proxy(request)
+	}
+	return t
+}
+
+func (r Request) logResponseBody(reader io.Reader) (io.Reader, *loggingReader) {
+	if r.Logger == nil {
+		return reader, nil
+	}
+	limit := 0
+	if r.Logger.Verbosity >= LogBodies {
+		limit = r.Logger.MaxBodySize
+	}
+	lr := &loggingReader{reader: reader, limit: limit}
+	return lr, lr
+}
+
+func (r Request) logFinish(ctx context.Context, request *http.Request, lr *loggingReader, drained int64) {
+	if r.Logger == nil || lr == nil {
+		return
+	}
+	lr.lock.Lock()
+	defer lr.lock.Unlock()
+	size := lr.size + drained
+	attrs := []slog.Attr{
+		slog.String("method", request.Method),
+		slog.String("url", r.Logger.redactURL(request.URL)),
+		slog.Int("status", r.StatusCode),
+		slog.Int("attempts", int(r.logAttempt.Load())),
+		slog.Int64("response_size", size),
+	}
+	if request.ContentLength > 0 {
+		attrs = append(attrs, slog.Int64("request_size", request.ContentLength))
+	}
+	if r.Timing != nil {
+		attrs = append(attrs,
+			slog.Duration("duration", time.Duration(r.Timing.Total)),
+			slog.Duration("time_to_first_byte", time.Duration(r.Timing.TimeToFirstByte)),
+		)
+	}
+	if r.Logger.Verbosity >= LogBodies {
+		var contentType string
+		if ct := r.GetFirstResponseHeader("Content-Type"); ct != nil {
+			contentType = *ct
+		}
+		attrs = append(attrs, slog.String("body", r.Logger.body(lr.captured.Bytes(), contentType, size > int64(lr.captured.Len()))))
+	}
+	r.Logger.logger().LogAttrs(ctx, r.Logger.Level, "http request finish", attrs...)
+}
+
+func (r Request) logFailure(ctx context.Context, reason any) {
+	if r.Logger == nil {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String("method", r.generateRequestMethod()),
+		slog.Any("error", reason),
+	}
+	if u, err := r.parsedURL(); err == nil {
+		attrs = append(attrs, slog.String("url", r.Logger.redactURL(u)))
+	}
+	if r.logAttempt != nil {
+		attrs = append(attrs, slog.Int("attempts", int(r.logAttempt.Load())))
+	}
+	r.Logger.logger().LogAttrs(ctx, slog.LevelError, "http request failed", attrs...)
+}
@@ -0,0 +1,380 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/TelephoneTan/GoHTTPRequest/util"
+	"io"
+	stdnet "net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	ErrorClassTimeout     = "timeout"
+	ErrorClassCanceled    = "canceled"
+	ErrorClassRateLimited = "rate_limited"
+	ErrorClassDNS         = "dns"
+	ErrorClassTLS         = "tls"
+	ErrorClassConnection  = "connection"
+	ErrorClassBody        = "body"
+	ErrorClassClient      = "client_error"
+	ErrorClassServer      = "server_error"
+	ErrorClassOther       = "other"
+)
+
+var defaultMetricsBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type RequestMetrics struct {
+	Host       string
+	Method     string
+	StatusCode int
+	ErrorClass string
+	Duration   time.Duration
+	BytesIn    int64
+	BytesOut   int64
+}
+
+type MetricsObserver interface {
+	ObserveStart(host, method string)
+	ObserveEnd(metrics RequestMetrics)
+}
+
+func ClassifyError(err error) string {
+	var dnsErr *stdnet.DNSError
+	var netErr stdnet.Error
+	var opErr *stdnet.OpError
+	var recordErr tls.RecordHeaderError
+	var alertErr tls.AlertError
+	var certErr *tls.CertificateVerificationError
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, context.Canceled) || errors.Is(err, ErrCancelled):
+		return ErrorClassCanceled
+	case errors.Is(err, ErrRateLimited):
+		return ErrorClassRateLimited
+	case errors.As(err, &dnsErr):
+		return ErrorClassDNS
+	case errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr) && netErr.Timeout():
+		return ErrorClassTimeout
+	case errors.As(err, &recordErr) || errors.As(err, &alertErr) || errors.As(err, &certErr) ||
+		errors.As(err, &authorityErr) || errors.As(err, &hostnameErr) || errors.As(err, &invalidErr):
+		return ErrorClassTLS
+	case errors.As(err, &opErr):
+		return ErrorClassConnection
+	default:
+		return ErrorClassOther
+	}
+}
+
+func classifyStatus(statusCode int) string {
+	switch {
+	case statusCode >= 500:
+		return ErrorClassServer
+	case statusCode >= 400:
+		return ErrorClassClient
+	default:
+		return ""
+	}
+}
+
+type metricsRecorder struct {
+	observer MetricsObserver
+	metrics  RequestMetrics
+	start    time.Time
+	bytesIn  atomic.Int64
+	bytesOut atomic.Int64
+	lock     sync.Mutex
+	ended    bool
+}
+
+type countingReader struct {
+	reader io.Reader
+	count  *atomic.Int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	c.count.Add(int64(n))
+	return n, err
+}
+
+func (r Request) startMetrics(request *http.Request) {
+	if r.Metrics == nil {
+		return
+	}
+	m := &metricsRecorder{
+		observer: r.Metrics,
+		metrics:  RequestMetrics{Host: strings.ToLower(request.URL.Host), Method: request.Method},
+		start:    time.Now(),
+	}
+	r.metrics = m
+	wrapRequestBody(request, func(body io.Reader) io.Reader {
+		return &countingReader{reader: body, count: &m.bytesOut}
+	})
+	m.observer.ObserveStart(m.metrics.Host, m.metrics.Method)
+}
+
+func (r Request) countResponseBody(reader io.Reader) io.Reader {
+	if r.metrics == nil {
+		return reader
+	}
+	return &countingReader{reader: reader, count: &r.metrics.bytesIn}
+}
+
+func (r Request) failBody(err error) {
+	if r.metrics == nil {
+		return
+	}
+	r.metrics.lock.Lock()
+	defer r.metrics.lock.Unlock()
+	if r.metrics.metrics.ErrorClass == "" {
+		r.metrics.metrics.ErrorClass = ErrorClassBody
+		if class := ClassifyError(err); class != ErrorClassOther {
+			r.metrics.metrics.ErrorClass = class
+		}
+	}
+}
+
+func (r Request) endMetrics(reason any, drained int64) {
+	m := r.metrics
+	if m == nil {
+		return
+	}
+	m.bytesIn.Add(drained)
+	m.lock.Lock()
+	if m.ended {
+		m.lock.Unlock()
+		return
+	}
+	m.ended = true
+	metrics := m.metrics
+	m.lock.Unlock()
+	metrics.StatusCode = r.StatusCode
+	metrics.Duration = time.Since(m.start)
+	metrics.BytesIn = m.bytesIn.Load()
+	metrics.BytesOut = m.bytesOut.Load()
+	if reason != nil {
+		err, isErr := reason.(error)
+		if !isErr {
+			err = fmt.Errorf("%v", reason)
+		}
+		metrics.ErrorClass = ClassifyError(err)
+	}
+	if metrics.ErrorClass == "" {
+		metrics.ErrorClass = classifyStatus(metrics.StatusCode)
+	}
+	m.observer.ObserveEnd(metrics)
+}
+
+type MetricsSeries struct {
+	Host            string
+	Method          string
+	StatusCode      int
+	ErrorClass      string
+	Requests        int64
+	BytesIn         int64
+	BytesOut        int64
+	DurationSum     Duration
+	DurationBuckets []int64
+}
+
+type MetricsSnapshot struct {
+	Buckets  []float64
+	Series   []MetricsSeries
+	InFlight map[string]int64
+}
+
+type metricsKey struct {
+	host       string
+	method     string
+	statusCode int
+	errorClass string
+}
+
+type _Metrics struct {
+	Buckets []float64
+	//
+	lock     sync.Mutex
+	series   map[metricsKey]*MetricsSeries
+	inFlight map[string]int64
+}
+
+type Metrics = *_Metrics
+
+func NewMetrics(init ...func(Metrics)) Metrics {
+	return util.New(&_Metrics{
+		Buckets:  append([]float64{}, defaultMetricsBuckets...),
+		series:   map[metricsKey]*MetricsSeries{},
+		inFlight: map[string]int64{},
+	}, init...)
+}
+
+func (m Metrics) generateDefaults() {
+	if m.Buckets == nil {
+		m.Buckets = append([]float64{}, defaultMetricsBuckets...)
+	}
+	if m.series == nil {
+		m.series = map[metricsKey]*MetricsSeries{}
+	}
+	if m.inFlight == nil {
+		m.inFlight = map[string]int64{}
+	}
+}
+
+func (m Metrics) ObserveStart(host, method string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.generateDefaults()
+	m.inFlight[host]++
+}
+
+func (m Metrics) ObserveEnd(metrics RequestMetrics) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.generateDefaults()
+	if m.inFlight[metrics.Host]--; m.inFlight[metrics.Host] <= 0 {
+		delete(m.inFlight, metrics.Host)
+	}
+	key := metricsKey{host: metrics.Host, method: metrics.Method, statusCode: metrics.StatusCode, errorClass: metrics.ErrorClass}
+	series, has := m.series[key]
+	if !has {
+		series = &MetricsSeries{
+			Host:            metrics.Host,
+			Method:          metrics.Method,
+			StatusCode:      metrics.StatusCode,
+			ErrorClass:      metrics.ErrorClass,
+			DurationBuckets: make([]int64, len(m.Buckets)),
+		}
+		m.series[key] = series
+	}
+	series.Requests++
+	series.BytesIn += metrics.BytesIn
+	series.BytesOut += metrics.BytesOut
+	series.DurationSum += Duration(metrics.Duration)
+	for i, bound := range m.Buckets {
+		if i < len(series.DurationBuckets) && metrics.Duration.Seconds() <= bound {
+			series.DurationBuckets[i]++
+		}
+	}
+}
+
+func (m Metrics) Snapshot() MetricsSnapshot {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.generateDefaults()
+	snapshot := MetricsSnapshot{
+		Buckets:  append([]float64{}, m.Buckets...),
+		InFlight: map[string]int64{},
+	}
+	for host, n := range m.inFlight {
+		snapshot.InFlight[host] = n
+	}
+	for _, series := range m.series {
+		s := *series
+		s.DurationBuckets = append([]int64{}, series.DurationBuckets...)
+		snapshot.Series = append(snapshot.Series, s)
+	}
+	sort.Slice(snapshot.Series, func(i, j int) bool {
+		a, b := snapshot.Series[i], snapshot.Series[j]
+		if a.Host != b.Host {
+			return a.Host < b.Host
+		}
+		if a.Method != b.Method {
+			return a.Method < b.Method
+		}
+		if a.StatusCode != b.StatusCode {
+			return a.StatusCode < b.StatusCode
+		}
+		return a.ErrorClass < b.ErrorClass
+	})
+	return snapshot
+}
+
+func (m Metrics) Reset() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.series = map[metricsKey]*MetricsSeries{}
+}
+
+func (m Metrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_ = m.Snapshot().WritePrometheus(w)
+}
+
+func prometheusLabels(pairs ...string) string {
+	var labels []string
+	for i := 0; i+1 < len(pairs); i += 2 {
+		value := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(pairs[i+1])
+		labels = append(labels, pairs[i]+`="`+value+`"`)
+	}
+	return "{" + strings.Join(labels, ",") + "}"
+}
+
+func prometheusFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func (s MetricsSnapshot) WritePrometheus(w io.Writer) error {
+	sb := strings.Builder{}
+	family := func(name, kind, help string) {
+		sb.WriteString("# HELP " + name + " " + help + "\n# TYPE " + name + " " + kind + "\n")
+	}
+	sample := func(name, labels string, value string) {
+		sb.WriteString(name + labels + " " + value + "\n")
+	}
+	seriesLabels := func(series MetricsSeries, extra ...string) string {
+		return prometheusLabels(append([]string{
+			"host", series.Host,
+			"method", series.Method,
+			"status", strconv.Itoa(series.StatusCode),
+			"error_class", series.ErrorClass,
+		}, extra...)...)
+	}
+	family("http_client_requests_total", "counter", "Total HTTP client requests.")
+	for _, series := range s.Series {
+		sample("http_client_requests_total", seriesLabels(series), strconv.FormatInt(series.Requests, 10))
+	}
+	family("http_client_request_duration_seconds", "histogram", "HTTP client request duration in seconds.")
+	for _, series := range s.Series {
+		for i, bound := range s.Buckets {
+			if i < len(series.DurationBuckets) {
+				sample("http_client_request_duration_seconds_bucket", seriesLabels(series, "le", prometheusFloat(bound)), strconv.FormatInt(series.DurationBuckets[i], 10))
+			}
+		}
+		sample("http_client_request_duration_seconds_bucket", seriesLabels(series, "le", "+Inf"), strconv.FormatInt(series.Requests, 10))
+		sample("http_client_request_duration_seconds_sum", seriesLabels(series), prometheusFloat(time.Duration(series.DurationSum).Seconds()))
+		sample("http_client_request_duration_seconds_count", seriesLabels(series), strconv.FormatInt(series.Requests, 10))
+	}
+	family("http_client_response_bytes_total", "counter", "Total HTTP client response body bytes received.")
+	for _, series := range s.Series {
+		sample("http_client_response_bytes_total", seriesLabels(series), strconv.FormatInt(series.BytesIn, 10))
+	}
+	family("http_client_request_bytes_total", "counter", "Total HTTP client request body bytes sent.")
+	for _, series := range s.Series {
+		sample("http_client_request_bytes_total", seriesLabels(series), strconv.FormatInt(series.BytesOut, 10))
+	}
+	family("http_client_requests_in_flight", "gauge", "HTTP client requests currently in flight.")
+	var hosts []string
+	for host := range s.InFlight {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	for _, host := range hosts {
+		sample("http_client_requests_in_flight", prometheusLabels("host", host), strconv.FormatInt(s.InFlight[host], 10))
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
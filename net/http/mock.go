@@ -0,0 +1,323 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/TelephoneTan/GoHTTPRequest/net/http/header"
+	"github.com/TelephoneTan/GoHTTPRequest/net/http/method"
+	"github.com/TelephoneTan/GoHTTPRequest/util"
+	"io"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrMockUnmatched = errors.New("mock: unmatched request")
+
+type MockMatcher func(request *http.Request, body []byte) bool
+
+func MatchMethod(m *method.Method) MockMatcher {
+	return func(request *http.Request, _ []byte) bool {
+		return strings.EqualFold(request.Method, string(*m))
+	}
+}
+
+func globRegexp(pattern string) *regexp.Regexp {
+	parts := strings.Split(pattern, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	return regexp.MustCompile("^" + strings.Join(parts, ".*") + "$")
+}
+
+func MatchURL(pattern string) MockMatcher {
+	expr := globRegexp(pattern)
+	full := strings.Contains(pattern, "://")
+	return func(request *http.Request, _ []byte) bool {
+		if full {
+			u := *request.URL
+			u.RawQuery, u.Fragment, u.User = "", "", nil
+			return expr.MatchString(u.String())
+		}
+		return expr.MatchString(request.URL.Path)
+	}
+}
+
+func MatchURLRegexp(expr string) MockMatcher {
+	re := regexp.MustCompile(expr)
+	return func(request *http.Request, _ []byte) bool {
+		return re.MatchString(request.URL.String())
+	}
+}
+
+func MatchHeader(name, value string) MockMatcher {
+	return func(request *http.Request, _ []byte) bool {
+		for _, v := range request.Header.Values(name) {
+			if v == value {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func MatchQuery(name, value string) MockMatcher {
+	return func(request *http.Request, _ []byte) bool {
+		for _, v := range request.URL.Query()[name] {
+			if v == value {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func MatchBody(body string) MockMatcher {
+	return func(_ *http.Request, bs []byte) bool {
+		return string(bs) == body
+	}
+}
+
+func MatchBodyContains(s string) MockMatcher {
+	return func(_ *http.Request, bs []byte) bool {
+		return bytes.Contains(bs, []byte(s))
+	}
+}
+
+func MatchJSON(v any) MockMatcher {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	var want any
+	_ = json.Unmarshal(bs, &want)
+	return func(_ *http.Request, body []byte) bool {
+		var got any
+		return json.Unmarshal(body, &got) == nil && reflect.DeepEqual(got, want)
+	}
+}
+
+type MockResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	Delay      Duration
+	Err        error
+}
+
+func MockText(statusCode int, body string) MockResponse {
+	return MockResponse{
+		StatusCode: statusCode,
+		Header:     http.Header{header.ContentType: {"text/plain; charset=utf-8"}},
+		Body:       []byte(body),
+	}
+}
+
+func MockJSON(statusCode int, v any) MockResponse {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return MockResponse{
+		StatusCode: statusCode,
+		Header:     http.Header{header.ContentType: {"application/json"}},
+		Body:       bs,
+	}
+}
+
+func MockHTML(statusCode int, body string) MockResponse {
+	return MockResponse{
+		StatusCode: statusCode,
+		Header:     http.Header{header.ContentType: {"text/html; charset=utf-8"}},
+		Body:       []byte(body),
+	}
+}
+
+func MockError(err error) MockResponse {
+	return MockResponse{Err: err}
+}
+
+type _MockRoute struct {
+	Matchers  []MockMatcher
+	Responses []MockResponse
+	Expected  *int
+	//
+	lock  sync.Mutex
+	calls []*http.Request
+}
+
+type MockRoute = *_MockRoute
+
+func (r MockRoute) Respond(responses ...MockResponse) MockRoute {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.Responses = append(r.Responses, responses...)
+	return r
+}
+
+func (r MockRoute) Times(n int) MockRoute {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.Expected = &n
+	return r
+}
+
+func (r MockRoute) Calls() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return len(r.calls)
+}
+
+func (r MockRoute) Requests() []*http.Request {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]*http.Request{}, r.calls...)
+}
+
+func (r MockRoute) matches(request *http.Request, body []byte) bool {
+	for _, matcher := range r.Matchers {
+		if !matcher(request, body) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r MockRoute) next(request *http.Request) MockResponse {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.calls = append(r.calls, request)
+	if len(r.Responses) == 0 {
+		return MockResponse{StatusCode: http.StatusOK}
+	}
+	if i := len(r.calls) - 1; i < len(r.Responses) {
+		return r.Responses[i]
+	}
+	return r.Responses[len(r.Responses)-1]
+}
+
+type _MockTransport struct {
+	FailOnUnmatched bool
+	Fallback        http.RoundTripper
+	//
+	lock      sync.Mutex
+	routes    []MockRoute
+	unmatched []*http.Request
+}
+
+type MockTransport = *_MockTransport
+
+func NewMockTransport(init ...func(MockTransport)) MockTransport {
+	return util.New(&_MockTransport{}, init...)
+}
+
+func (m MockTransport) On(matchers ...MockMatcher) MockRoute {
+	route := &_MockRoute{Matchers: matchers}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.routes = append(m.routes, route)
+	return route
+}
+
+func (m MockTransport) Unmatched() []*http.Request {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return append([]*http.Request{}, m.unmatched...)
+}
+
+func (m MockTransport) Verify() error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	var problems []string
+	for i, route := range m.routes {
+		if route.Expected != nil {
+			if calls := route.Calls(); calls != *route.Expected {
+				problems = append(problems, fmt.Sprintf("route %d called %d times, expected %d", i, calls, *route.Expected))
+			}
+		}
+	}
+	for _, request := range m.unmatched {
+		problems = append(problems, fmt.Sprintf("unmatched %s %s", request.Method, request.URL))
+	}
+	if len(problems) > 0 {
+		return errors.New("mock: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func (m MockTransport) match(request *http.Request, body []byte) MockRoute {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for _, route := range m.routes {
+		if route.matches(request, body) {
+			return route
+		}
+	}
+	m.unmatched = append(m.unmatched, request)
+	return nil
+}
+
+func (m MockTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	var body []byte
+	if request.Body != nil {
+		var err error
+		body, err = io.ReadAll(request.Body)
+		_ = request.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	route := m.match(request, body)
+	if route == nil {
+		switch {
+		case m.FailOnUnmatched:
+			return nil, fmt.Errorf("%w: %s %s", ErrMockUnmatched, request.Method, request.URL)
+		case m.Fallback != nil:
+			fallback := request.Clone(request.Context())
+			fallback.Body = io.NopCloser(bytes.NewReader(body))
+			return m.Fallback.RoundTrip(fallback)
+		default:
+			return m.respond(request, MockText(http.StatusNotFound, "no mock route matched"))
+		}
+	}
+	return m.respond(request, route.next(request))
+}
+
+func (m MockTransport) respond(request *http.Request, response MockResponse) (*http.Response, error) {
+	if response.Delay > 0 {
+		timer := time.NewTimer(time.Duration(response.Delay))
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-request.Context().Done():
+			return nil, request.Context().Err()
+		}
+	}
+	if response.Err != nil {
+		return nil, response.Err
+	}
+	statusCode := response.StatusCode
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+	h := response.Header.Clone()
+	if h == nil {
+		h = http.Header{}
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", statusCode, http.StatusText(statusCode)),
+		StatusCode:    statusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        h,
+		Body:          io.NopCloser(bytes.NewReader(response.Body)),
+		ContentLength: int64(len(response.Body)),
+		Request:       request,
+	}, nil
+}
@@ -0,0 +1,259 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"golang.org/x/net/idna"
+	stdnet "net"
+	"net/url"
+	"strings"
+)
+
+var ErrInvalidHost = errors.New("invalid host name")
+
+type IDNAProfile string
+
+const (
+	IDNAPunycode         IDNAProfile = "punycode"
+	IDNA2008             IDNAProfile = "idna2008"
+	UTS46Transitional    IDNAProfile = "uts46-transitional"
+	UTS46NonTransitional IDNAProfile = "uts46-nontransitional"
+)
+
+var (
+	defaultIDNAProfile              = IDNAPunycode
+	defaultStrictIDNA               = false
+	defaultMapFullStops             = true
+	defaultLowercaseHost            = false
+	defaultNormalizePercentEncoding = false
+	defaultRemoveDotSegments        = false
+	defaultStripDefaultPort         = false
+	defaultRemoveFragment           = false
+)
+
+type URLNormalization struct {
+	IDNAProfile              *IDNAProfile
+	StrictIDNA               *bool
+	MapFullStops             *bool
+	LowercaseHost            *bool
+	NormalizePercentEncoding *bool
+	RemoveDotSegments        *bool
+	StripDefaultPort         *bool
+	RemoveFragment           *bool
+}
+
+func (n *URLNormalization) generateDefaults() URLNormalization {
+	policy := URLNormalization{}
+	if n != nil {
+		policy = *n
+	}
+	if policy.IDNAProfile == nil {
+		policy.IDNAProfile = &defaultIDNAProfile
+	}
+	if policy.StrictIDNA == nil {
+		policy.StrictIDNA = &defaultStrictIDNA
+	}
+	if policy.MapFullStops == nil {
+		policy.MapFullStops = &defaultMapFullStops
+	}
+	if policy.LowercaseHost == nil {
+		policy.LowercaseHost = &defaultLowercaseHost
+	}
+	if policy.NormalizePercentEncoding == nil {
+		policy.NormalizePercentEncoding = &defaultNormalizePercentEncoding
+	}
+	if policy.RemoveDotSegments == nil {
+		policy.RemoveDotSegments = &defaultRemoveDotSegments
+	}
+	if policy.StripDefaultPort == nil {
+		policy.StripDefaultPort = &defaultStripDefaultPort
+	}
+	if policy.RemoveFragment == nil {
+		policy.RemoveFragment = &defaultRemoveFragment
+	}
+	return policy
+}
+
+func (n URLNormalization) idna() *idna.Profile {
+	var options []idna.Option
+	switch *n.IDNAProfile {
+	case IDNA2008:
+		options = append(options, idna.ValidateForRegistration())
+	case UTS46Transitional:
+		options = append(options, idna.MapForLookup(), idna.Transitional(true), idna.BidiRule())
+	case UTS46NonTransitional:
+		options = append(options, idna.MapForLookup(), idna.Transitional(false), idna.BidiRule())
+	}
+	strict := *n.StrictIDNA
+	options = append(options, idna.StrictDomainName(strict), idna.VerifyDNSLength(strict), idna.CheckHyphens(strict), idna.CheckJoiners(strict))
+	return idna.New(options...)
+}
+
+func (n URLNormalization) host(u *url.URL) error {
+	host := u.Hostname()
+	if host == "" {
+		return nil
+	}
+	port := u.Port()
+	if *n.StripDefaultPort && port == defaultPort(u.Scheme) {
+		port = ""
+	}
+	if ip := stdnet.ParseIP(host); ip != nil {
+		if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+	} else {
+		if *n.MapFullStops {
+			host = strings.ReplaceAll(host, "\u3002", ".")
+			host = strings.ReplaceAll(host, "\uff0e", ".")
+			host = strings.ReplaceAll(host, "\uff61", ".")
+		}
+		if *n.LowercaseHost {
+			host = strings.ToLower(host)
+		}
+		ascii, err := n.idna().ToASCII(host)
+		if err != nil {
+			return err
+		}
+		if *n.StrictIDNA && !isLDHHost(ascii) {
+			return fmt.Errorf("%w: %q", ErrInvalidHost, ascii)
+		}
+		host = ascii
+	}
+	if port != "" {
+		host += ":" + port
+	}
+	u.Host = host
+	return nil
+}
+
+func isLDHHost(host string) bool {
+	for _, label := range strings.Split(strings.TrimSuffix(host, "."), ".") {
+		if label == "" {
+			return false
+		}
+		for i := 0; i < len(label); i++ {
+			if c := label[i]; !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func normalizePercentEncoding(s string) string {
+	sb := strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]) {
+			c := unhex(s[i+1])<<4 | unhex(s[i+2])
+			if isUnreserved(c) {
+				sb.WriteByte(c)
+			} else {
+				sb.WriteString(strings.ToUpper(s[i : i+3]))
+			}
+			i += 2
+			continue
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	}
+	return c - 'A' + 10
+}
+
+func removeDotSegments(path string) string {
+	if path == "" {
+		return path
+	}
+	absolute := strings.HasPrefix(path, "/")
+	var output []string
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		last := i == len(segments)-1
+		switch segment {
+		case ".":
+			if last {
+				output = append(output, "")
+			}
+		case "..":
+			if len(output) > 1 || len(output) == 1 && !absolute {
+				output = output[:len(output)-1]
+			}
+			if last {
+				output = append(output, "")
+			}
+		default:
+			output = append(output, segment)
+		}
+	}
+	normalized := strings.Join(output, "/")
+	if absolute && !strings.HasPrefix(normalized, "/") {
+		normalized = "/" + normalized
+	}
+	return normalized
+}
+
+func (n URLNormalization) path(u *url.URL) error {
+	escaped := u.EscapedPath()
+	if *n.NormalizePercentEncoding {
+		escaped = normalizePercentEncoding(escaped)
+	}
+	if *n.RemoveDotSegments {
+		escaped = removeDotSegments(escaped)
+	}
+	if escaped == u.EscapedPath() {
+		return nil
+	}
+	path, err := url.PathUnescape(escaped)
+	if err != nil {
+		return err
+	}
+	u.Path, u.RawPath = path, escaped
+	return nil
+}
+
+func normalizeURL(rawURL string, normalization *URLNormalization, preserveRawQuery bool, extraQuery string) (string, error) {
+	n := normalization.generateDefaults()
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	if err := n.host(u); err != nil {
+		return "", err
+	}
+	if err := n.path(u); err != nil {
+		return "", err
+	}
+	if !preserveRawQuery {
+		u.RawQuery = strings.ReplaceAll(u.RawQuery, "+", "%2b")
+		u.RawQuery = strings.ReplaceAll(u.Query().Encode(), "+", "%20")
+	}
+	if extraQuery != "" {
+		if u.RawQuery != "" {
+			u.RawQuery += "&"
+		}
+		u.RawQuery += extraQuery
+	}
+	if *n.NormalizePercentEncoding {
+		u.RawQuery = normalizePercentEncoding(u.RawQuery)
+	}
+	if *n.RemoveFragment {
+		u.Fragment, u.RawFragment = "", ""
+	}
+	if preserveRawQuery {
+		return u.String(), nil
+	}
+	return strings.ReplaceAll(u.String(), "+", "%2b"), nil
+}
+
+func NormalizeURL(rawURL string, normalization *URLNormalization) (string, error) {
+	return normalizeURL(rawURL, normalization, false, "")
+}
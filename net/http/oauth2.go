@@ -0,0 +1,239 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/TelephoneTan/GoHTTPRequest/net/http/header"
+	"github.com/TelephoneTan/GoHTTPRequest/net/http/method"
+	"github.com/TelephoneTan/GoHTTPRequest/util"
+	"github.com/TelephoneTan/GoPromise/async/promise"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+type OAuth2Grant string
+
+const (
+	ClientCredentialsGrant OAuth2Grant = "client_credentials"
+	RefreshTokenGrant      OAuth2Grant = "refresh_token"
+	PasswordGrant          OAuth2Grant = "password"
+)
+
+var defaultOAuth2ExpiryDelta = Duration(30 * time.Second)
+
+type OAuth2Token struct {
+	AccessToken  string    `json:"access_token"`
+	TokenType    string    `json:"token_type,omitempty"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	ExpiresIn    int64     `json:"expires_in,omitempty"`
+	Scope        string    `json:"scope,omitempty"`
+	Expiry       time.Time `json:"-"`
+}
+
+func (t *OAuth2Token) authorization() string {
+	tokenType := t.TokenType
+	if tokenType == "" || strings.EqualFold(tokenType, "bearer") {
+		tokenType = "Bearer"
+	}
+	return tokenType + " " + t.AccessToken
+}
+
+type OAuth2Error struct {
+	StatusCode  int
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+	URI         string `json:"error_uri"`
+}
+
+func (e *OAuth2Error) Error() string {
+	if e.Description != "" {
+		return fmt.Sprintf("oauth2: %s (%d): %s", e.Code, e.StatusCode, e.Description)
+	}
+	return fmt.Sprintf("oauth2: %s (%d)", e.Code, e.StatusCode)
+}
+
+type _OAuth2 struct {
+	TokenURL           string
+	Grant              OAuth2Grant
+	ClientID           string
+	ClientSecret       string
+	ClientSecretInBody bool
+	Username           string
+	Password           string
+	RefreshToken       string
+	Scopes             []string
+	ExtraParams        [][]string
+	ExpiryDelta        *Duration
+	Configure          func(Request)
+	//
+	lock     sync.Mutex
+	token    *OAuth2Token
+	inflight *promise.Promise[*OAuth2Token]
+}
+
+type OAuth2 = *_OAuth2
+
+func NewOAuth2(tokenURL string, grant OAuth2Grant, init ...func(OAuth2)) OAuth2 {
+	return util.New(&_OAuth2{TokenURL: tokenURL, Grant: grant}, init...)
+}
+
+func (o OAuth2) generateExpiryDelta() time.Duration {
+	if o.ExpiryDelta == nil {
+		o.ExpiryDelta = &defaultOAuth2ExpiryDelta
+	}
+	return time.Duration(*o.ExpiryDelta)
+}
+
+func (o OAuth2) valid(token *OAuth2Token) bool {
+	return token != nil && token.AccessToken != "" && (token.Expiry.IsZero() || time.Now().Add(o.generateExpiryDelta()).Before(token.Expiry))
+}
+
+func (o OAuth2) SetToken(token *OAuth2Token) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.token = token
+}
+
+func (o OAuth2) requestToken(grant OAuth2Grant, refreshToken string) (*OAuth2Token, error) {
+	form := [][]string{{"grant_type", string(grant)}}
+	switch grant {
+	case RefreshTokenGrant:
+		form = append(form, []string{"refresh_token", refreshToken})
+	case PasswordGrant:
+		form = append(form, []string{"username", o.Username}, []string{"password", o.Password})
+	}
+	if len(o.Scopes) > 0 && grant != RefreshTokenGrant {
+		form = append(form, []string{"scope", strings.Join(o.Scopes, " ")})
+	}
+	if o.ClientSecretInBody || o.ClientSecret == "" {
+		form = append(form, []string{"client_id", o.ClientID})
+		if o.ClientSecret != "" {
+			form = append(form, []string{"client_secret", o.ClientSecret})
+		}
+	}
+	form = append(form, o.ExtraParams...)
+	request := NewRequest(func(request Request) {
+		request.Method = method.POST
+		request.URL = o.TokenURL
+		request.RequestForm = form
+		request.CustomizedHeaderList = [][]string{{"Accept", "application/json"}}
+		if !o.ClientSecretInBody && o.ClientSecret != "" {
+			request.Authenticator = &BasicAuth{Username: url.QueryEscape(o.ClientID), Password: url.QueryEscape(o.ClientSecret)}
+		}
+		if o.Configure != nil {
+			o.Configure(request)
+		}
+	})
+	res, err := await(request.ByteSlice())
+	if err != nil {
+		return nil, err
+	}
+	if request.StatusCode < 200 || request.StatusCode > 299 {
+		e := &OAuth2Error{StatusCode: request.StatusCode}
+		if json.Unmarshal(res.Result, e) != nil || e.Code == "" {
+			e.Code = "invalid_response"
+			e.Description = strings.TrimSpace(string(res.Result))
+		}
+		return nil, e
+	}
+	token := &OAuth2Token{}
+	if err := json.Unmarshal(res.Result, token); err != nil {
+		return nil, err
+	}
+	if token.AccessToken == "" {
+		return nil, &OAuth2Error{StatusCode: request.StatusCode, Code: "invalid_response", Description: "missing access_token"}
+	}
+	if token.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+	if token.RefreshToken == "" {
+		token.RefreshToken = refreshToken
+	}
+	return token, nil
+}
+
+func (o OAuth2) fetch(previous *OAuth2Token) (*OAuth2Token, error) {
+	refreshToken := o.RefreshToken
+	if previous != nil && previous.RefreshToken != "" {
+		refreshToken = previous.RefreshToken
+	}
+	if refreshToken != "" {
+		token, err := o.requestToken(RefreshTokenGrant, refreshToken)
+		var oauth2Err *OAuth2Error
+		if err == nil || o.Grant == RefreshTokenGrant || !errors.As(err, &oauth2Err) {
+			return token, err
+		}
+	}
+	if o.Grant == RefreshTokenGrant {
+		return nil, errors.New("oauth2: no refresh token available")
+	}
+	return o.requestToken(o.Grant, "")
+}
+
+func (o OAuth2) Token() (*OAuth2Token, error) {
+	o.lock.Lock()
+	if o.valid(o.token) {
+		token := o.token
+		o.lock.Unlock()
+		return token, nil
+	}
+	if o.inflight == nil {
+		previous := o.token
+		p := promise.NewPromise(promise.Job[*OAuth2Token]{
+			Do: func(rs promise.Resolver[*OAuth2Token], re promise.Rejector) {
+				token, err := o.fetch(previous)
+				o.lock.Lock()
+				o.inflight = nil
+				if err == nil {
+					o.token = token
+				}
+				o.lock.Unlock()
+				if err != nil {
+					panic(err)
+				}
+				rs.ResolveValue(token)
+			},
+		})
+		o.inflight = &p
+	}
+	p := *o.inflight
+	o.lock.Unlock()
+	return await(p)
+}
+
+func (o OAuth2) expire() {
+	if o.token != nil {
+		expired := *o.token
+		expired.AccessToken = ""
+		o.token = &expired
+	}
+}
+
+func (o OAuth2) Invalidate() {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.expire()
+}
+
+func (o OAuth2) Authorize(request *http.Request) error {
+	token, err := o.Token()
+	if err != nil {
+		return err
+	}
+	request.Header.Set(header.Authorization, token.authorization())
+	return nil
+}
+
+func (o OAuth2) Challenge(response *http.Response) bool {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	if o.token == nil || response.Request == nil || response.Request.Header.Get(header.Authorization) != o.token.authorization() {
+		return o.token == nil || o.token.AccessToken != ""
+	}
+	o.expire()
+	return true
+}
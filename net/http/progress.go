@@ -0,0 +1,190 @@
+package http
+
+import (
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var defaultProgressInterval = Duration(200 * time.Millisecond)
+
+type Progress struct {
+	Transferred int64
+	Total       int64
+	Rate        float64
+	AverageRate float64
+	Elapsed     time.Duration
+	ETA         time.Duration
+	Done        bool
+}
+
+type progressTracker struct {
+	callback        func(Progress)
+	interval        time.Duration
+	total           int64
+	initial         int64
+	start           time.Time
+	lock            sync.Mutex
+	transferred     int64
+	lastTime        time.Time
+	lastTransferred int64
+	done            bool
+}
+
+func newProgressTracker(callback func(Progress), interval Duration, total int64, initial int64) *progressTracker {
+	now := time.Now()
+	return &progressTracker{
+		callback:        callback,
+		interval:        time.Duration(interval),
+		total:           total,
+		initial:         initial,
+		start:           now,
+		transferred:     initial,
+		lastTime:        now,
+		lastTransferred: initial,
+	}
+}
+
+func (t *progressTracker) setTotal(total int64) {
+	if t == nil {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.total = total
+}
+
+func (t *progressTracker) snapshot(now time.Time) Progress {
+	p := Progress{
+		Transferred: t.transferred,
+		Total:       t.total,
+		Elapsed:     now.Sub(t.start),
+		ETA:         -1,
+		Done:        t.done,
+	}
+	if window := now.Sub(t.lastTime).Seconds(); window > 0 {
+		p.Rate = float64(t.transferred-t.lastTransferred) / window
+	}
+	if elapsed := p.Elapsed.Seconds(); elapsed > 0 {
+		p.AverageRate = float64(t.transferred-t.initial) / elapsed
+	}
+	if t.done {
+		p.ETA = 0
+	} else if t.total >= 0 && p.AverageRate > 0 {
+		p.ETA = time.Duration(float64(t.total-t.transferred) / p.AverageRate * float64(time.Second))
+	}
+	return p
+}
+
+func (t *progressTracker) emit(force bool) {
+	now := time.Now()
+	if !force && now.Sub(t.lastTime) < t.interval {
+		return
+	}
+	p := t.snapshot(now)
+	t.lastTime = now
+	t.lastTransferred = t.transferred
+	t.callback(p)
+}
+
+func (t *progressTracker) add(n int64) {
+	if t == nil || n <= 0 {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.done {
+		return
+	}
+	t.transferred += n
+	t.emit(false)
+}
+
+func (t *progressTracker) finish() {
+	if t == nil {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.done {
+		return
+	}
+	t.done = true
+	t.emit(true)
+}
+
+type progressReader struct {
+	reader  io.Reader
+	tracker *progressTracker
+}
+
+func (p *progressReader) Read(bs []byte) (int, error) {
+	n, err := p.reader.Read(bs)
+	p.tracker.add(int64(n))
+	if err == io.EOF {
+		p.tracker.finish()
+	}
+	return n, err
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+func wrapRequestBody(request *http.Request, wrap func(io.Reader) io.Reader) {
+	if request.Body == nil || request.Body == http.NoBody {
+		return
+	}
+	request.Body = readCloser{Reader: wrap(request.Body), Closer: request.Body}
+	if getBody := request.GetBody; getBody != nil {
+		request.GetBody = func() (io.ReadCloser, error) {
+			body, err := getBody()
+			if err != nil {
+				return nil, err
+			}
+			return readCloser{Reader: wrap(body), Closer: body}, nil
+		}
+	}
+}
+
+func (r Request) generateProgressInterval() Duration {
+	if r.ProgressInterval == nil {
+		r.ProgressInterval = &defaultProgressInterval
+	}
+	return *r.ProgressInterval
+}
+
+func (r Request) trackUploadProgress(request *http.Request) {
+	if r.OnUploadProgress == nil {
+		return
+	}
+	total := request.ContentLength
+	if total <= 0 {
+		total = r.contentLength
+	}
+	if total <= 0 {
+		total = -1
+	}
+	interval := r.generateProgressInterval()
+	wrapRequestBody(request, func(body io.Reader) io.Reader {
+		return &progressReader{
+			reader:  body,
+			tracker: newProgressTracker(r.OnUploadProgress, interval, total, 0),
+		}
+	})
+}
+
+func (r Request) trackDownloadProgress(reader io.Reader, total int64) io.Reader {
+	if r.OnDownloadProgress == nil {
+		return reader
+	}
+	if total < 0 {
+		total = -1
+	}
+	return &progressReader{
+		reader:  reader,
+		tracker: newProgressTracker(r.OnDownloadProgress, r.generateProgressInterval(), total, 0),
+	}
+}
@@ -0,0 +1,75 @@
+package http
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// QueryArrayStyle controls how QueryList entries with several values are
+// encoded. A key ending in "[]" marks the entry as an array even when it
+// carries a single value; the marker is replaced by the style's own suffix.
+type QueryArrayStyle string
+
+const (
+	QueryArrayRepeat   QueryArrayStyle = "repeat"
+	QueryArrayBrackets QueryArrayStyle = "brackets"
+	QueryArrayIndex    QueryArrayStyle = "index"
+	QueryArrayComma    QueryArrayStyle = "comma"
+)
+
+var defaultQueryArrayStyle = QueryArrayRepeat
+
+func queryEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func (r Request) generateQueryArrayStyle() QueryArrayStyle {
+	if r.QueryArrayStyle == nil {
+		r.QueryArrayStyle = &defaultQueryArrayStyle
+	}
+	return *r.QueryArrayStyle
+}
+
+func (r Request) encodeQueryList() string {
+	style := r.generateQueryArrayStyle()
+	var parts []string
+	for _, kv := range r.QueryList {
+		if len(kv) == 0 {
+			continue
+		}
+		key, values := queryEscape(kv[0]), kv[1:]
+		if len(values) == 0 {
+			parts = append(parts, key)
+			continue
+		}
+		array := strings.HasSuffix(kv[0], "[]") && len(kv[0]) > 2
+		if array {
+			key = queryEscape(strings.TrimSuffix(kv[0], "[]"))
+		}
+		if len(values) == 1 && !array {
+			parts = append(parts, key+"="+queryEscape(values[0]))
+			continue
+		}
+		switch style {
+		case QueryArrayComma:
+			escaped := make([]string, len(values))
+			for i, v := range values {
+				escaped[i] = queryEscape(v)
+			}
+			parts = append(parts, key+"="+strings.Join(escaped, ","))
+		default:
+			for i, v := range values {
+				name := key
+				switch style {
+				case QueryArrayBrackets:
+					name += "[]"
+				case QueryArrayIndex:
+					name += "[" + strconv.Itoa(i) + "]"
+				}
+				parts = append(parts, name+"="+queryEscape(v))
+			}
+		}
+	}
+	return strings.Join(parts, "&")
+}
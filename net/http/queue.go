@@ -0,0 +1,507 @@
+package http
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/TelephoneTan/GoHTTPRequest/util"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type QueueStatus string
+
+const (
+	QueuePending   QueueStatus = "pending"
+	QueueInFlight  QueueStatus = "in_flight"
+	QueueDelivered QueueStatus = "delivered"
+	QueueDead      QueueStatus = "dead"
+)
+
+var (
+	defaultQueueMaxAttempts       = 8
+	defaultQueueMinBackoff        = Duration(time.Second)
+	defaultQueueMaxBackoff        = Duration(10 * time.Minute)
+	defaultQueueIdempotencyHeader = "Idempotency-Key"
+	defaultQueuePollInterval      = Duration(30 * time.Second)
+)
+
+var (
+	ErrQueueItemNotFound = errors.New("queue: item not found")
+	ErrQueueExpired      = errors.New("queue: item expired")
+	ErrQueueUndelivered  = errors.New("queue: delivery rejected")
+)
+
+const queueFileSuffix = ".json"
+
+type _QueueItem struct {
+	ID             string
+	IdempotencyKey string
+	Priority       int
+	TTL            Duration
+	Request        string
+	Status         QueueStatus
+	Attempts       int
+	EnqueuedAt     time.Time
+	ExpiresAt      *time.Time
+	NextAttemptAt  time.Time
+	DeliveredAt    *time.Time
+	LastStatusCode int
+	LastError      string
+}
+
+type QueueItem = *_QueueItem
+
+func (i QueueItem) expired(now time.Time) bool {
+	return i.ExpiresAt != nil && now.After(*i.ExpiresAt)
+}
+
+type _Queue struct {
+	Dir               string
+	MaxAttempts       *int
+	MinBackoff        *Duration
+	MaxBackoff        *Duration
+	TTL               *Duration
+	IdempotencyHeader *string
+	PollInterval      *Duration
+	Online            func() bool
+	Prepare           func(Request)
+	Retryable         func(statusCode int, err error) bool
+	OnStatus          func(QueueItem)
+	//
+	lock     sync.Mutex
+	flushing sync.Mutex
+	loaded   bool
+	items    map[string]QueueItem
+	wake     chan struct{}
+	stop     chan struct{}
+}
+
+type Queue = *_Queue
+
+func NewQueue(dir string, init ...func(Queue)) Queue {
+	return util.New(&_Queue{Dir: dir}, init...)
+}
+
+func (q Queue) generateDefaults() {
+	if q.MaxAttempts == nil || *q.MaxAttempts < 1 {
+		q.MaxAttempts = &defaultQueueMaxAttempts
+	}
+	if q.MinBackoff == nil {
+		q.MinBackoff = &defaultQueueMinBackoff
+	}
+	if q.MaxBackoff == nil {
+		q.MaxBackoff = &defaultQueueMaxBackoff
+	}
+	if q.IdempotencyHeader == nil {
+		q.IdempotencyHeader = &defaultQueueIdempotencyHeader
+	}
+	if q.PollInterval == nil || *q.PollInterval <= 0 {
+		q.PollInterval = &defaultQueuePollInterval
+	}
+}
+
+func (q Queue) itemPath(id string) string {
+	return filepath.Join(q.Dir, id+queueFileSuffix)
+}
+
+func (q Queue) load() error {
+	if q.loaded {
+		return nil
+	}
+	q.generateDefaults()
+	if err := os.MkdirAll(q.Dir, 0o755); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(q.Dir)
+	if err != nil {
+		return err
+	}
+	q.items = map[string]QueueItem{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), queueFileSuffix) {
+			continue
+		}
+		bs, err := os.ReadFile(filepath.Join(q.Dir, entry.Name()))
+		if err != nil {
+			return err
+		}
+		item := &_QueueItem{}
+		if err := json.Unmarshal(bs, item); err != nil {
+			return fmt.Errorf("queue: %s: %w", entry.Name(), err)
+		}
+		if item.Status == QueueInFlight {
+			item.Status = QueuePending
+		}
+		q.items[item.ID] = item
+	}
+	q.loaded = true
+	return nil
+}
+
+func (q Queue) save(item QueueItem) error {
+	if item.Status == QueueDelivered {
+		delete(q.items, item.ID)
+		err := os.Remove(q.itemPath(item.ID))
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	bs, err := json.MarshalIndent(item, "", "  ")
+	if err != nil {
+		return err
+	}
+	temp := q.itemPath(item.ID) + ".tmp"
+	if err := os.WriteFile(temp, bs, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(temp, q.itemPath(item.ID))
+}
+
+func (q Queue) update(item QueueItem, change func(QueueItem)) error {
+	q.lock.Lock()
+	change(item)
+	err := q.save(item)
+	snapshot := util.Copy(*item)
+	q.lock.Unlock()
+	if q.OnStatus != nil {
+		q.OnStatus(snapshot)
+	}
+	return err
+}
+
+func newQueueID() string {
+	bs := make([]byte, 16)
+	_, _ = rand.Read(bs)
+	return hex.EncodeToString(bs)
+}
+
+func (q Queue) Enqueue(request Request, init ...func(QueueItem)) (QueueItem, error) {
+	q.lock.Lock()
+	if err := q.load(); err != nil {
+		q.lock.Unlock()
+		return nil, err
+	}
+	q.lock.Unlock()
+	now := time.Now()
+	item := util.New(&_QueueItem{ID: newQueueID(), Status: QueuePending, EnqueuedAt: now, NextAttemptAt: now}, init...)
+	if item.IdempotencyKey == "" {
+		item.IdempotencyKey = item.ID
+	}
+	if item.TTL <= 0 && q.TTL != nil {
+		item.TTL = *q.TTL
+	}
+	if item.TTL > 0 && item.ExpiresAt == nil {
+		expiresAt := now.Add(time.Duration(item.TTL))
+		item.ExpiresAt = &expiresAt
+	}
+	request = request.Clone()
+	if name := *q.IdempotencyHeader; name != "" {
+		has := false
+		for _, kv := range request.CustomizedHeaderList {
+			if len(kv) > 0 && strings.EqualFold(kv[0], name) {
+				has = true
+			}
+		}
+		if !has {
+			request.CustomizedHeaderList = append(request.CustomizedHeaderList, []string{name, item.IdempotencyKey})
+		}
+	}
+	serialized, err := request.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	item.Request = serialized
+	q.lock.Lock()
+	if err := q.save(item); err != nil {
+		q.lock.Unlock()
+		return nil, err
+	}
+	q.items[item.ID] = item
+	snapshot := util.Copy(*item)
+	q.lock.Unlock()
+	if q.OnStatus != nil {
+		q.OnStatus(snapshot)
+	}
+	q.Notify()
+	return snapshot, nil
+}
+
+func (q Queue) Item(id string) (QueueItem, error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if err := q.load(); err != nil {
+		return nil, err
+	}
+	item, has := q.items[id]
+	if !has {
+		return nil, fmt.Errorf("%w: %s", ErrQueueItemNotFound, id)
+	}
+	return util.Copy(*item), nil
+}
+
+func (q Queue) list(filter func(QueueItem) bool) []QueueItem {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if q.load() != nil {
+		return nil
+	}
+	var items []QueueItem
+	for _, item := range q.items {
+		if filter(item) {
+			items = append(items, util.Copy(*item))
+		}
+	}
+	sortQueueItems(items)
+	return items
+}
+
+func (q Queue) Items() []QueueItem {
+	return q.list(func(QueueItem) bool {
+		return true
+	})
+}
+
+func (q Queue) DeadLetters() []QueueItem {
+	return q.list(func(item QueueItem) bool {
+		return item.Status == QueueDead
+	})
+}
+
+func (q Queue) Len() int {
+	return len(q.list(func(item QueueItem) bool {
+		return item.Status == QueuePending || item.Status == QueueInFlight
+	}))
+}
+
+func (q Queue) Requeue(id string) error {
+	q.lock.Lock()
+	if err := q.load(); err != nil {
+		q.lock.Unlock()
+		return err
+	}
+	item, has := q.items[id]
+	dead := has && item.Status == QueueDead
+	q.lock.Unlock()
+	if !dead {
+		return fmt.Errorf("%w: %s", ErrQueueItemNotFound, id)
+	}
+	err := q.update(item, func(item QueueItem) {
+		now := time.Now()
+		item.Status = QueuePending
+		item.Attempts = 0
+		item.NextAttemptAt = now
+		if item.TTL > 0 {
+			expiresAt := now.Add(time.Duration(item.TTL))
+			item.ExpiresAt = &expiresAt
+		}
+	})
+	q.Notify()
+	return err
+}
+
+func (q Queue) Remove(id string) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if err := q.load(); err != nil {
+		return err
+	}
+	item, has := q.items[id]
+	if !has || item.Status == QueueInFlight {
+		return fmt.Errorf("%w: %s", ErrQueueItemNotFound, id)
+	}
+	delete(q.items, id)
+	err := os.Remove(q.itemPath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func sortQueueItems(items []QueueItem) {
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Priority != items[j].Priority {
+			return items[i].Priority > items[j].Priority
+		}
+		if !items[i].EnqueuedAt.Equal(items[j].EnqueuedAt) {
+			return items[i].EnqueuedAt.Before(items[j].EnqueuedAt)
+		}
+		return items[i].ID < items[j].ID
+	})
+}
+
+func (q Queue) due(now time.Time) []QueueItem {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	var items []QueueItem
+	for _, item := range q.items {
+		if item.Status == QueuePending && !now.Before(item.NextAttemptAt) {
+			items = append(items, item)
+		}
+	}
+	sortQueueItems(items)
+	return items
+}
+
+func (q Queue) retryable(statusCode int, err error) bool {
+	if q.Retryable != nil {
+		return q.Retryable(statusCode, err)
+	}
+	if statusCode == 0 {
+		return err != nil
+	}
+	switch statusCode {
+	case http.StatusRequestTimeout, http.StatusTooEarly, http.StatusTooManyRequests:
+		return true
+	}
+	return statusCode >= 500
+}
+
+func (q Queue) backoff(attempts int, request Request) time.Duration {
+	if request != nil {
+		if retryAfter := request.GetFirstResponseHeader("Retry-After"); retryAfter != nil {
+			if wait, ok := parseRetryAfter(*retryAfter, time.Now()); ok && wait > 0 {
+				return wait
+			}
+		}
+	}
+	wait := time.Duration(*q.MinBackoff)
+	for i := 1; i < attempts && wait < time.Duration(*q.MaxBackoff); i++ {
+		wait *= 2
+	}
+	if wait > time.Duration(*q.MaxBackoff) {
+		wait = time.Duration(*q.MaxBackoff)
+	}
+	return wait
+}
+
+func (q Queue) deliver(item QueueItem) (status QueueStatus, err error) {
+	if item.expired(time.Now()) {
+		return QueueDead, q.update(item, func(item QueueItem) {
+			item.Status = QueueDead
+			item.LastError = ErrQueueExpired.Error()
+		})
+	}
+	if err := q.update(item, func(item QueueItem) {
+		item.Status = QueueInFlight
+		item.Attempts++
+	}); err != nil {
+		return QueuePending, err
+	}
+	request, err := NewRequest().Deserialize(item.Request)
+	permanent := err != nil
+	if err == nil {
+		if q.Prepare != nil {
+			q.Prepare(request)
+		}
+		_, err = await(request.Send())
+	}
+	statusCode := request.StatusCode
+	if err == nil && statusCode >= 400 {
+		err = fmt.Errorf("%w: %s", ErrQueueUndelivered, request.StatusMessage)
+	}
+	return status, q.update(item, func(item QueueItem) {
+		defer func() {
+			status = item.Status
+		}()
+		item.LastStatusCode = statusCode
+		now := time.Now()
+		switch {
+		case err == nil:
+			item.Status = QueueDelivered
+			item.DeliveredAt = &now
+			item.LastError = ""
+		case permanent || !q.retryable(statusCode, err) || item.Attempts >= *q.MaxAttempts:
+			item.Status = QueueDead
+			item.LastError = err.Error()
+		default:
+			item.Status = QueuePending
+			item.LastError = err.Error()
+			item.NextAttemptAt = now.Add(q.backoff(item.Attempts, request))
+			if item.ExpiresAt != nil && item.NextAttemptAt.After(*item.ExpiresAt) {
+				item.Status = QueueDead
+				item.LastError = ErrQueueExpired.Error()
+			}
+		}
+	})
+}
+
+func (q Queue) Flush() (delivered int, err error) {
+	q.flushing.Lock()
+	defer q.flushing.Unlock()
+	q.lock.Lock()
+	err = q.load()
+	q.lock.Unlock()
+	if err != nil {
+		return 0, err
+	}
+	for _, item := range q.due(time.Now()) {
+		if q.Online != nil && !q.Online() {
+			break
+		}
+		status, err := q.deliver(item)
+		if err != nil {
+			return delivered, err
+		}
+		if status == QueueDelivered {
+			delivered++
+		}
+	}
+	return delivered, nil
+}
+
+func (q Queue) Notify() {
+	q.lock.Lock()
+	wake := q.wake
+	q.lock.Unlock()
+	if wake != nil {
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (q Queue) Start() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if q.stop != nil {
+		return
+	}
+	q.generateDefaults()
+	wake := make(chan struct{}, 1)
+	stop := make(chan struct{})
+	q.wake, q.stop = wake, stop
+	go func() {
+		ticker := time.NewTicker(time.Duration(*q.PollInterval))
+		defer ticker.Stop()
+		for {
+			if q.Online == nil || q.Online() {
+				_, _ = q.Flush()
+			}
+			select {
+			case <-stop:
+				return
+			case <-wake:
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (q Queue) Stop() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if q.stop != nil {
+		close(q.stop)
+		q.stop, q.wake = nil, nil
+	}
+}
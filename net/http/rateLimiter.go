@@ -0,0 +1,264 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/TelephoneTan/GoHTTPRequest/util"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	defaultRateLimitBackoff    = Duration(time.Second)
+	defaultRateLimitMaxBackoff = Duration(time.Minute)
+)
+
+var ErrRateLimited = errors.New("rate limited")
+
+type rateBucket struct {
+	tokens       float64
+	last         time.Time
+	blockedUntil time.Time
+	paceUntil    time.Time
+	pace         time.Duration
+	next         time.Time
+	backoff      time.Duration
+}
+
+type _RateLimiter struct {
+	RequestsPerSecond float64
+	Burst             int
+	Key               func(u *url.URL) string
+	FailFast          bool
+	Backoff           *Duration
+	MaxBackoff        *Duration
+	//
+	lock    sync.Mutex
+	buckets map[string]*rateBucket
+}
+
+type RateLimiter = *_RateLimiter
+
+func NewRateLimiter(requestsPerSecond float64, init ...func(RateLimiter)) RateLimiter {
+	return util.New(&_RateLimiter{RequestsPerSecond: requestsPerSecond, buckets: map[string]*rateBucket{}}, init...)
+}
+
+func (l RateLimiter) generateDefaults() {
+	if l.Backoff == nil {
+		l.Backoff = &defaultRateLimitBackoff
+	}
+	if l.MaxBackoff == nil {
+		l.MaxBackoff = &defaultRateLimitMaxBackoff
+	}
+	if l.buckets == nil {
+		l.buckets = map[string]*rateBucket{}
+	}
+}
+
+func (l RateLimiter) burst() float64 {
+	if l.Burst > 0 {
+		return float64(l.Burst)
+	}
+	return 1
+}
+
+func (l RateLimiter) KeyOf(u *url.URL) string {
+	if l.Key != nil {
+		return l.Key(u)
+	}
+	return strings.ToLower(u.Host)
+}
+
+func (l RateLimiter) bucket(key string, now time.Time) *rateBucket {
+	b, has := l.buckets[key]
+	if !has {
+		b = &rateBucket{tokens: l.burst(), last: now}
+		l.buckets[key] = b
+	}
+	return b
+}
+
+func (l RateLimiter) reserve(key string, failFast bool) (time.Duration, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.generateDefaults()
+	now := time.Now()
+	b := l.bucket(key, now)
+	if l.RequestsPerSecond > 0 {
+		b.tokens = math.Min(l.burst(), b.tokens+now.Sub(b.last).Seconds()*l.RequestsPerSecond)
+	}
+	b.last = now
+	start := now
+	if b.blockedUntil.After(start) {
+		start = b.blockedUntil
+	}
+	if b.pace > 0 && b.paceUntil.After(now) && b.next.After(start) {
+		start = b.next
+	}
+	tokens := b.tokens
+	if l.RequestsPerSecond > 0 {
+		tokens--
+		if tokens < 0 {
+			if tokenStart := now.Add(time.Duration(-tokens / l.RequestsPerSecond * float64(time.Second))); tokenStart.After(start) {
+				start = tokenStart
+			}
+		}
+	}
+	wait := start.Sub(now)
+	if wait > 0 && failFast {
+		return wait, fmt.Errorf("%w: %s for %v", ErrRateLimited, key, wait)
+	}
+	if l.RequestsPerSecond > 0 {
+		b.tokens = tokens
+	}
+	if b.pace > 0 && b.paceUntil.After(now) {
+		b.next = start.Add(b.pace)
+	}
+	return wait, nil
+}
+
+func (l RateLimiter) Wait(ctx context.Context, key string) error {
+	if l == nil {
+		return nil
+	}
+	wait, err := l.reserve(key, l.FailFast)
+	if err != nil || wait <= 0 {
+		return err
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Duration(seconds * float64(time.Second)), true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return t.Sub(now), true
+	}
+	return 0, false
+}
+
+func parseRateLimitReset(value string, now time.Time) (time.Duration, bool) {
+	seconds, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return 0, false
+	}
+	if seconds > 1e9 {
+		return time.Unix(int64(seconds), 0).Sub(now), true
+	}
+	return time.Duration(seconds * float64(time.Second)), true
+}
+
+func firstHeader(header http.Header, names ...string) string {
+	for _, name := range names {
+		if v := header.Get(name); v != "" {
+			return strings.TrimSpace(strings.Split(v, ",")[0])
+		}
+	}
+	return ""
+}
+
+func parseRateLimitHeaders(header http.Header, now time.Time) (remaining int64, reset time.Duration, ok bool) {
+	remaining = -1
+	reset = -1
+	if v := header.Get("RateLimit"); v != "" {
+		for _, param := range strings.Split(v, ";") {
+			if k, pv, has := strings.Cut(strings.TrimSpace(param), "="); has {
+				switch strings.TrimSpace(k) {
+				case "r":
+					remaining, _ = strconv.ParseInt(strings.TrimSpace(pv), 10, 64)
+				case "t":
+					reset, _ = parseRateLimitReset(pv, now)
+				}
+			}
+		}
+	}
+	if v := firstHeader(header, "RateLimit-Remaining", "X-RateLimit-Remaining", "X-Rate-Limit-Remaining"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			remaining = n
+		}
+	}
+	if v := firstHeader(header, "RateLimit-Reset", "X-RateLimit-Reset", "X-Rate-Limit-Reset"); v != "" {
+		if d, has := parseRateLimitReset(v, now); has {
+			reset = d
+		}
+	}
+	return remaining, reset, remaining >= 0 && reset >= 0
+}
+
+func (l RateLimiter) Observe(key string, statusCode int, header http.Header) {
+	if l == nil {
+		return
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.generateDefaults()
+	now := time.Now()
+	b := l.bucket(key, now)
+	block := func(d time.Duration) {
+		if until := now.Add(d); until.After(b.blockedUntil) {
+			b.blockedUntil = until
+		}
+	}
+	retryAfter, hasRetryAfter := parseRetryAfter(header.Get("Retry-After"), now)
+	if hasRetryAfter && retryAfter > 0 {
+		block(retryAfter)
+	}
+	if remaining, reset, has := parseRateLimitHeaders(header, now); has && reset > 0 {
+		if remaining <= 0 {
+			block(reset)
+		} else {
+			b.pace = reset / time.Duration(remaining)
+			b.paceUntil = now.Add(reset)
+		}
+	}
+	if statusCode == http.StatusTooManyRequests || statusCode == http.StatusServiceUnavailable {
+		if b.backoff == 0 {
+			b.backoff = time.Duration(*l.Backoff)
+		} else if b.backoff *= 2; b.backoff > time.Duration(*l.MaxBackoff) {
+			b.backoff = time.Duration(*l.MaxBackoff)
+		}
+		if !hasRetryAfter {
+			block(b.backoff)
+		}
+	} else if statusCode < 400 {
+		b.backoff = 0
+	}
+}
+
+func (r Request) rateLimitKey(u *url.URL) string {
+	if r.RateLimitKey != nil {
+		return *r.RateLimitKey
+	}
+	return r.RateLimiter.KeyOf(u)
+}
+
+func (r Request) waitRateLimit(request *http.Request) {
+	if r.RateLimiter == nil {
+		return
+	}
+	if err := r.RateLimiter.Wait(request.Context(), r.rateLimitKey(request.URL)); err != nil {
+		panic(err)
+	}
+}
+
+func (r Request) observeRateLimit(request *http.Request, response *http.Response) {
+	if r.RateLimiter == nil {
+		return
+	}
+	r.RateLimiter.Observe(r.rateLimitKey(request.URL), response.StatusCode, response.Header)
+}
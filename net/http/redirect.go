@@ -0,0 +1,172 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"github.com/TelephoneTan/GoHTTPRequest/net/http/header"
+	"github.com/TelephoneTan/GoHTTPRequest/net/http/method"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+var (
+	defaultMaxRedirects       = 10
+	defaultStripAuthorization = true
+	defaultStripCookies       = true
+)
+
+var (
+	ErrTooManyRedirects = errors.New("too many redirects")
+	ErrRedirectBlocked  = errors.New("redirect blocked by policy")
+)
+
+type RedirectPolicy struct {
+	MaxRedirects       *int
+	SameHost           *bool
+	SameScheme         *bool
+	BlockDowngrade     *bool
+	StripAuthorization *bool
+	StripCookies       *bool
+	// MethodRewrite maps a redirect status code to the method of the next hop;
+	// an empty method keeps the previous one. Status codes that are not listed,
+	// including every code when the map is nil, follow net/http: 301, 302 and
+	// 303 switch anything but GET and HEAD to GET and drop the body, while 307
+	// and 308 resend the original method and body.
+	MethodRewrite map[int]method.Method
+}
+
+func (r Request) generateRedirectPolicy() RedirectPolicy {
+	policy := RedirectPolicy{}
+	if r.Redirect != nil {
+		policy = *r.Redirect
+	}
+	if policy.MaxRedirects == nil {
+		policy.MaxRedirects = &defaultMaxRedirects
+	}
+	if policy.SameHost == nil {
+		policy.SameHost = new(bool)
+	}
+	if policy.SameScheme == nil {
+		policy.SameScheme = new(bool)
+	}
+	if policy.BlockDowngrade == nil {
+		policy.BlockDowngrade = new(bool)
+	}
+	if policy.StripAuthorization == nil {
+		policy.StripAuthorization = &defaultStripAuthorization
+	}
+	if policy.StripCookies == nil {
+		policy.StripCookies = &defaultStripCookies
+	}
+	return policy
+}
+
+type RedirectHop struct {
+	URL               string
+	Method            string
+	RequestHeaderList [][]string
+	StatusCode        int
+	StatusMessage     string
+	HeaderList        [][]string
+}
+
+func defaultPort(scheme string) string {
+	switch strings.ToLower(scheme) {
+	case "http", "ws":
+		return "80"
+	case "https", "wss":
+		return "443"
+	}
+	return ""
+}
+
+func sameOrigin(a, b *url.URL) bool {
+	portOf := func(u *url.URL) string {
+		if port := u.Port(); port != "" {
+			return port
+		}
+		return defaultPort(u.Scheme)
+	}
+	return strings.EqualFold(a.Scheme, b.Scheme) && strings.EqualFold(a.Hostname(), b.Hostname()) && portOf(a) == portOf(b)
+}
+
+func (r Request) recordRedirect(previous *http.Request, response *http.Response) {
+	sent := previous
+	if response != nil && response.Request != nil {
+		sent = response.Request
+	}
+	requestHeader := HeaderMap(sent.Header)
+	hop := RedirectHop{URL: previous.URL.String(), Method: previous.Method, RequestHeaderList: requestHeader.list()}
+	if response != nil {
+		hop.StatusCode = response.StatusCode
+		hop.StatusMessage = response.Status
+		responseHeader := HeaderMap(response.Header)
+		hop.HeaderList = responseHeader.list()
+	}
+	r.RedirectHistory = append(r.RedirectHistory, hop)
+}
+
+func rewriteRedirectMethod(policy RedirectPolicy, request *http.Request, via []*http.Request) error {
+	if request.Response == nil {
+		return nil
+	}
+	m, has := policy.MethodRewrite[request.Response.StatusCode]
+	if !has {
+		return nil
+	}
+	original := via[0]
+	if m == "" {
+		m = method.Method(via[len(via)-1].Method)
+	}
+	request.Method = string(m)
+	if m == *method.GET || m == *method.HEAD {
+		request.Body, request.GetBody, request.ContentLength = http.NoBody, nil, 0
+		request.Header.Del(header.ContentType)
+		return nil
+	}
+	if original.GetBody == nil || original.Body == nil || original.Body == http.NoBody {
+		return nil
+	}
+	body, err := original.GetBody()
+	if err != nil {
+		return err
+	}
+	request.Body, request.GetBody, request.ContentLength = body, original.GetBody, original.ContentLength
+	if ct := original.Header.Get(header.ContentType); ct != "" {
+		request.Header.Set(header.ContentType, ct)
+	}
+	return nil
+}
+
+func (r Request) checkRedirect(request *http.Request, via []*http.Request) error {
+	previous := via[len(via)-1]
+	r.recordRedirect(previous, request.Response)
+	policy := r.generateRedirectPolicy()
+	if len(via) > *policy.MaxRedirects {
+		return fmt.Errorf("%w: stopped after %d redirects", ErrTooManyRedirects, *policy.MaxRedirects)
+	}
+	original := via[0]
+	if *policy.SameHost && !strings.EqualFold(request.URL.Hostname(), original.URL.Hostname()) {
+		return fmt.Errorf("%w: host changed to %s", ErrRedirectBlocked, request.URL.Hostname())
+	}
+	if *policy.SameScheme && !strings.EqualFold(request.URL.Scheme, original.URL.Scheme) {
+		return fmt.Errorf("%w: scheme changed to %s", ErrRedirectBlocked, request.URL.Scheme)
+	}
+	if *policy.BlockDowngrade && strings.EqualFold(previous.URL.Scheme, "https") && strings.EqualFold(request.URL.Scheme, "http") {
+		return fmt.Errorf("%w: downgrade to %s", ErrRedirectBlocked, withoutUserinfo(request.URL))
+	}
+	crossOrigin := !sameOrigin(request.URL, original.URL)
+	for name, strip := range map[string]bool{
+		header.Authorization: *policy.StripAuthorization,
+		header.Cookie:        *policy.StripCookies,
+	} {
+		switch {
+		case crossOrigin && strip:
+			request.Header.Del(name)
+		case !strip && len(original.Header.Values(name)) > 0:
+			request.Header[name] = append([]string{}, original.Header.Values(name)...)
+		}
+	}
+	return rewriteRedirectMethod(policy, request, via)
+}
@@ -3,6 +3,7 @@ package http
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/base64"
 	"encoding/json"
 	"github.com/TelephoneTan/GoHTTPRequest/net"
@@ -13,12 +14,11 @@ import (
 	"github.com/TelephoneTan/GoPromise/async/promise"
 	"github.com/TelephoneTan/GoPromise/async/task"
 	"golang.org/x/net/html"
-	"golang.org/x/net/html/charset"
-	"golang.org/x/net/idna"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -43,6 +43,7 @@ type Stream struct {
 type Result[T any] struct {
 	Request Request
 	Result  T
+	Charset *DetectedCharset
 }
 
 type ctxPack struct {
@@ -114,8 +115,8 @@ func (r *ResponseBinary) MarshalJSON() ([]byte, error) {
 type HeaderMap map[string][]string
 
 func (h *HeaderMap) UnmarshalJSON(bs []byte) error {
-	var x *[][]any
-	err := json.Unmarshal(bs, x)
+	var x *[][]json.RawMessage
+	err := json.Unmarshal(bs, &x)
 	if err != nil {
 		return err
 	}
@@ -128,9 +129,13 @@ func (h *HeaderMap) UnmarshalJSON(bs []byte) error {
 			var k string
 			var v []string
 			if len(kv) > 0 {
-				k, _ = kv[0].(string)
+				if err := json.Unmarshal(kv[0], &k); err != nil {
+					return err
+				}
 				if len(kv) > 1 {
-					v, _ = kv[1].([]string)
+					if err := json.Unmarshal(kv[1], &v); err != nil {
+						return err
+					}
 				}
 			}
 			if v == nil {
@@ -142,26 +147,55 @@ func (h *HeaderMap) UnmarshalJSON(bs []byte) error {
 	}
 }
 
+func (h *HeaderMap) keys() []string {
+	keys := make([]string, 0, len(*h))
+	for k := range *h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (h *HeaderMap) list() [][]string {
+	var list [][]string
+	for _, k := range h.keys() {
+		for _, v := range (*h)[k] {
+			list = append(list, []string{k, v})
+		}
+	}
+	return list
+}
+
 func (h *HeaderMap) MarshalJSON() ([]byte, error) {
-	var x [][]any
-	for k, v := range *h {
-		x = append(x, []any{k, v})
+	if *h == nil {
+		return json.Marshal(nil)
+	}
+	x := [][]any{}
+	for _, k := range h.keys() {
+		x = append(x, []any{k, (*h)[k]})
 	}
 	return json.Marshal(x)
 }
 
 type _Request struct {
+	Context                  context.Context   `json:"-"`
 	RequestSemaphore         promise.Semaphore `json:"-"`
 	Method                   *method.Method
-	URL                      string `json:"-"`
-	EncodedURL               string `json:"URL"`
-	URI                      string `json:"-"`
+	URL                      string            `json:"-"`
+	EncodedURL               string            `json:"URL"`
+	URI                      string            `json:"-"`
+	URLVariables             map[string]any    `json:"-"`
+	QueryList                [][]string        `json:"-"`
+	QueryArrayStyle          *QueryArrayStyle  `json:"-"`
+	PreserveRawQuery         *bool             `json:"-"`
+	URLNormalization         *URLNormalization `json:"-"`
 	CustomizedHeaderList     [][]string
 	RequestBinary            Binary
 	RequestForm              [][]string
 	RequestString            string
 	RequestFile              *os.File  `json:"-"`
 	RequestBody              io.Reader `json:"-"`
+	BodyCodec                BodyCodec `json:"-"`
 	RequestContentType       *mime.Type
 	RequestContentTypeHeader string
 	Timeout                  *Duration
@@ -170,6 +204,8 @@ type _Request struct {
 	WriteTimeout             *Duration
 	IsQuickTest              *bool
 	FollowRedirect           *bool
+	Redirect                 *RedirectPolicy
+	InsecureSkipVerify       *bool
 	CookieJar                FlexibleCookieJar `json:"-"`
 	CookieJarTag             *string
 	AutoSendCookies          *bool
@@ -177,22 +213,48 @@ type _Request struct {
 	ClearCookieJar           *bool
 	SetCookies               [][]string
 	Proxy                    *net.Proxy
+	OnUploadProgress         func(Progress) `json:"-"`
+	OnDownloadProgress       func(Progress) `json:"-"`
+	ProgressInterval         *Duration
+	UploadRateLimit          *int64
+	DownloadRateLimit        *int64
+	UploadBandwidth          Bandwidth   `json:"-"`
+	DownloadBandwidth        Bandwidth   `json:"-"`
+	RateLimiter              RateLimiter `json:"-"`
+	RateLimitKey             *string
+	Authenticator            Authenticator     `json:"-"`
+	Signer                   Signer            `json:"-"`
+	Logger                   RequestLogger     `json:"-"`
+	Metrics                  MetricsObserver   `json:"-"`
+	Tracer                   Tracer            `json:"-"`
+	CharsetDetector          CharsetDetector   `json:"-"`
+	Transport                http.RoundTripper `json:"-"`
 	//
 	StatusCode         int
 	StatusMessage      string
 	ResponseHeaderList [][]string
 	ResponseHeaderMap  HeaderMap
+	RedirectHistory    []RedirectHop
+	FinalURL           string
+	FinalMethod        string
+	RequestHeaderList  [][]string
+	Timing             *Timing
 	//
 	ResponseBinary ResponseBinary `json:"responseBinary"`
 	//
 	contentLength int64
 	getBody       func() (io.ReadCloser, error)
+	rawGetBody    func() (io.ReadCloser, error)
 	//
 	transport *http.Transport
 	client    *http.Client
 	//
 	context *atomic.Pointer[ctxPack]
 	//
+	logAttempt *atomic.Int32
+	metrics    *metricsRecorder
+	span       Span
+	//
 	stream       task.Once[Result[Stream]]
 	send         task.Once[Result[any]]
 	byteSlice    task.Once[Result[[]byte]]
@@ -204,6 +266,7 @@ type _Request struct {
 type Request = *_Request
 
 var transportPool = sync.Pool{New: func() any { return &http.Transport{} }}
+var insecureTransportPool = sync.Pool{New: func() any { return &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}} }}
 var clientPool = sync.Pool{New: func() any { return &http.Client{} }}
 
 func (r Request) calContentType() string {
@@ -213,55 +276,50 @@ func (r Request) calContentType() string {
 	return r.RequestContentTypeHeader
 }
 
-func (r Request) encodedURL() string {
-	var urlStr string
+func (r Request) encodedURL() (string, error) {
 	if r.URI != "" {
-		urlStr = r.URI
-	} else {
-		u, err := url.Parse(r.URL)
+		return strings.ReplaceAll(r.URI, "+", "%2b"), nil
+	}
+	rawURL := r.URL
+	if r.URLVariables != nil {
+		expanded, err := ExpandURITemplate(rawURL, r.URLVariables)
 		if err != nil {
-			panic(err)
+			return "", err
 		}
-		{
-			host := u.Hostname()
-			host = strings.ReplaceAll(host, "\u002e", ".")
-			host = strings.ReplaceAll(host, "\u3002", ".")
-			host = strings.ReplaceAll(host, "\uff0e", ".")
-			host = strings.ReplaceAll(host, "\uff61", ".")
-			host, err := idna.ToASCII(host)
-			if err != nil {
-				panic(err)
+		rawURL = expanded
+	}
+	return normalizeURL(rawURL, r.URLNormalization, r.PreserveRawQuery != nil && *r.PreserveRawQuery, r.encodeQueryList())
+}
+
+func (r Request) parsedURL() (*url.URL, error) {
+	encodedURL, err := r.encodedURL()
+	if err != nil {
+		return nil, err
+	}
+	return url.Parse(encodedURL)
+}
+
+func encodeForm(form [][]string) string {
+	sb := strings.Builder{}
+	for i, kv := range form {
+		if len(kv) > 0 {
+			if i > 0 {
+				sb.WriteString("&")
 			}
-			port := u.Port()
-			if port != "" {
-				host += ":" + port
+			sb.WriteString(url.QueryEscape(kv[0]))
+			if len(kv) > 1 {
+				sb.WriteString("=")
+				sb.WriteString(url.QueryEscape(kv[1]))
 			}
-			u.Host = host
 		}
-		u.RawQuery = strings.ReplaceAll(u.RawQuery, "+", "%2b")
-		u.RawQuery = strings.ReplaceAll(u.Query().Encode(), "+", "%20")
-		urlStr = u.String()
 	}
-	return strings.ReplaceAll(urlStr, "+", "%2b")
+	return sb.String()
 }
 
 func (r Request) generateRequestBody() io.Reader {
 	if len(r.RequestForm) > 0 {
-		sb := strings.Builder{}
-		for i, kv := range r.RequestForm {
-			if len(kv) > 0 {
-				if i > 0 {
-					sb.WriteString("&")
-				}
-				sb.WriteString(url.QueryEscape(kv[0]))
-				if len(kv) > 1 {
-					sb.WriteString("=")
-					sb.WriteString(url.QueryEscape(kv[1]))
-				}
-			}
-		}
-		if sb.Len() > 0 {
-			r.RequestString = sb.String()
+		if form := encodeForm(r.RequestForm); form != "" {
+			r.RequestString = form
 			r.RequestContentType = &mime.XWWWFormURLEncoded
 		}
 	}
@@ -385,9 +443,16 @@ func (r Request) generateCookieJar() FlexibleCookieJar {
 	return r.CookieJar
 }
 
+func (r Request) transportPool() *sync.Pool {
+	if r.InsecureSkipVerify != nil && *r.InsecureSkipVerify {
+		return &insecureTransportPool
+	}
+	return &transportPool
+}
+
 func (r Request) generateTransport(request *http.Request) *http.Transport {
 	r.generateTimeout()
-	r.transport = transportPool.Get().(*http.Transport)
+	r.transport = r.transportPool().Get().(*http.Transport)
 	r.transport.TLSHandshakeTimeout = time.Duration(*r.ConnectTimeout)
 	var u *url.URL
 	if r.Proxy != nil {
@@ -406,12 +471,24 @@ func (r Request) generateTransport(request *http.Request) *http.Transport {
 	return r.transport
 }
 
+func (r Request) generateRoundTripper(request *http.Request) http.RoundTripper {
+	var base http.RoundTripper = r.generateTransport(request)
+	if r.Transport != nil {
+		base = r.Transport
+	}
+	roundTripper := r.generateTracingTransport(r.generateLoggingTransport(base, request))
+	if r.Authenticator != nil {
+		roundTripper = &authTransport{base: roundTripper, authenticator: r.Authenticator, host: request.URL.Host, getBody: r.rawGetBody}
+	}
+	return roundTripper
+}
+
 func (r Request) generateClient(request *http.Request) *http.Client {
-	r.generateTransport(request)
+	roundTripper := r.generateRoundTripper(request)
 	r.generateFollowRedirect()
 	r.generateCookieJar()
 	r.client = clientPool.Get().(*http.Client)
-	r.client.Transport = r.transport
+	r.client.Transport = roundTripper
 	r.client.Timeout = time.Duration(*r.Timeout)
 	r.client.Jar = r.CookieJar
 	if !*r.FollowRedirect {
@@ -419,19 +496,30 @@ func (r Request) generateClient(request *http.Request) *http.Client {
 			return http.ErrUseLastResponse
 		}
 	} else {
-		r.client.CheckRedirect = nil
+		r.client.CheckRedirect = r.checkRedirect
 	}
 	return r.client
 }
 
+func (r Request) parentContext() context.Context {
+	if r.Context != nil {
+		return r.Context
+	}
+	return context.Background()
+}
+
 func (r Request) Cancel() bool {
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(r.parentContext())
 	cancel()
-	return r.context.CompareAndSwap(nil, &ctxPack{ctx: ctx, cancel: cancel})
+	if r.context.CompareAndSwap(nil, &ctxPack{ctx: ctx, cancel: cancel}) {
+		return true
+	}
+	r.context.Load().cancel()
+	return false
 }
 
 func (r Request) getContext() *ctxPack {
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(r.parentContext())
 	newCTX := &ctxPack{ctx: ctx, cancel: cancel}
 	if r.context.CompareAndSwap(nil, newCTX) {
 		return newCTX
@@ -468,21 +556,15 @@ func (r Request) stringTask(defaultCharset string) task.Once[Result[string]] {
 					if headers := bsRes.Request.GetResponseHeader(header.ContentType); len(headers) > 0 {
 						ct = headers[0]
 					}
-					if ct == "" {
-						ct = "text/plain;charset=" + defaultCharset
-					}
-					reader, err := charset.NewReader(bytes.NewReader(bsRes.Result), ct)
-					if err != nil {
-						panic(err)
-					}
-					bb := bytes.Buffer{}
-					_, err = bb.ReadFrom(reader)
+					detected := SniffCharset(bsRes.Result, ct, defaultCharset, r.CharsetDetector)
+					decoded, err := decodeCharset(bsRes.Result, detected)
 					if err != nil {
 						panic(err)
 					}
 					return Result[string]{
 						Request: r,
-						Result:  bb.String(),
+						Result:  string(decoded),
+						Charset: &detected,
 					}
 				},
 			}))
@@ -508,6 +590,7 @@ func (r Request) htmlTask(defaultCharset string) task.Once[Result[*html.Node]] {
 					return Result[*html.Node]{
 						Request: r,
 						Result:  node,
+						Charset: strRes.Charset,
 					}
 				},
 			}))
@@ -517,10 +600,23 @@ func (r Request) htmlTask(defaultCharset string) task.Once[Result[*html.Node]] {
 
 func (r Request) init() Request {
 	r.context = &atomic.Pointer[ctxPack]{}
+	r.logAttempt = &atomic.Int32{}
+	r.metrics = nil
+	r.span = nil
 	r.stream = task.NewOnceTask(promise.Job[Result[Stream]]{
 		Do: func(rs promise.Resolver[Result[Stream]], re promise.Rejector) {
 			ok := false
 			ctx := r.getContext()
+			defer func() {
+				if !ok {
+					if reason := recover(); reason != nil {
+						r.logFailure(ctx.ctx, reason)
+						r.endMetrics(reason, 0)
+						r.endSpan(reason)
+						panic(reason)
+					}
+				}
+			}()
 			cancelContext := func() {
 				ctx.cancel()
 			}
@@ -530,22 +626,38 @@ func (r Request) init() Request {
 				}
 			}()
 			//
-			request, err := http.NewRequestWithContext(ctx.ctx, r.generateRequestMethod(), r.encodedURL(), r.generateRequestBody())
+			traceCTX, tracer := r.traceContext(r.startSpan(ctx.ctx))
+			encodedURL, err := r.encodedURL()
+			if err != nil {
+				panic(err)
+			}
+			request, err := http.NewRequestWithContext(traceCTX, r.generateRequestMethod(), encodedURL, r.generateRequestBody())
 			if err != nil {
 				panic(err)
 			}
 			if r.getBody != nil {
 				request.GetBody = r.getBody
 			}
+			r.rawGetBody = request.GetBody
 			//
 			r.applyRequestHeaders(request)
+			r.signRequest(request)
+			uploadBandwidth, downloadBandwidth := r.generateBandwidth()
+			r.throttleUpload(request, uploadBandwidth)
+			r.trackUploadProgress(request)
+			r.startMetrics(request)
 			//
+			r.waitRateLimit(request)
+			r.RedirectHistory = nil
+			r.FinalURL = ""
+			r.FinalMethod = ""
+			r.RequestHeaderList = nil
 			response, err := r.generateClient(request).Do(request)
 			recycleClient := func() {
 				clientPool.Put(r.client)
 			}
 			recycleTransport := func() {
-				transportPool.Put(r.transport)
+				r.transportPool().Put(r.transport)
 			}
 			defer func() {
 				if !ok {
@@ -570,30 +682,45 @@ func (r Request) init() Request {
 				}()
 			}
 			//
+			r.observeRateLimit(request, response)
+			//
 			r.StatusCode = response.StatusCode
 			r.StatusMessage = response.Status
+			sent := request
+			if response.Request != nil {
+				sent = response.Request
+			}
+			r.FinalURL = sent.URL.String()
+			r.FinalMethod = sent.Method
+			sentHeader := HeaderMap(sent.Header)
+			r.RequestHeaderList = sentHeader.list()
 			//
 			r.ResponseHeaderMap = map[string][]string{}
 			if response.Header != nil {
 				r.ResponseHeaderMap = HeaderMap(response.Header)
 			}
 			//
-			for k, vs := range r.ResponseHeaderMap {
-				for _, v := range vs {
+			for _, k := range r.ResponseHeaderMap.keys() {
+				for _, v := range r.ResponseHeaderMap[k] {
 					r.ResponseHeaderList = append(r.ResponseHeaderList, []string{k, v})
 				}
 			}
 			//
+			responseBody, loggingReader := r.logResponseBody(&timingReader{reader: r.countResponseBody(response.Body), tracer: tracer})
 			rs.ResolveValue(Result[Stream]{
 				Request: r,
 				Result: Stream{
-					Reader: response.Body,
+					Reader: r.trackDownloadProgress(r.throttleDownload(responseBody, downloadBandwidth), response.ContentLength),
 					Done: func() {
 						defer recycleClient()
 						defer recycleTransport()
 						defer cancelContext()
 						defer closeBody()
-						_, _ = io.Copy(io.Discard, response.Body)
+						drained, _ := io.Copy(io.Discard, response.Body)
+						tracer.endTransfer()
+						r.logFinish(ctx.ctx, request, loggingReader, drained)
+						r.endMetrics(nil, drained)
+						r.endSpan(nil)
 					},
 				},
 			})
@@ -621,6 +748,7 @@ func (r Request) init() Request {
 					var err error
 					r.ResponseBinary.bin, err = io.ReadAll(streamRes.Result.Reader)
 					if err != nil {
+						r.failBody(err)
 						panic(err)
 					}
 					if r.ResponseBinary.bin == nil {
@@ -671,6 +799,14 @@ func (r Request) Clone() Request {
 				}
 			}
 		}
+		if clone.QueryList != nil {
+			clone.QueryList = append([][]string{}, clone.QueryList...)
+			for i, kv := range clone.QueryList {
+				if kv != nil {
+					clone.QueryList[i] = append([]string{}, kv...)
+				}
+			}
+		}
 		if clone.RequestForm != nil {
 			clone.RequestForm = append([][]string{}, clone.RequestForm...)
 			for i, kv := range clone.RequestForm {
@@ -693,18 +829,6 @@ func (r Request) Clone() Request {
 	})
 }
 
-func (r Request) Serialize() string {
-	r.EncodedURL = r.encodedURL()
-	bs, _ := json.Marshal(r)
-	return string(bs)
-}
-
-func (r Request) Deserialize(s string) Request {
-	_ = json.Unmarshal([]byte(s), r)
-	r.URI = r.EncodedURL
-	return r
-}
-
 func (r Request) Stream() promise.Promise[Result[Stream]] {
 	return r.stream.Do()
 }
@@ -0,0 +1,153 @@
+package http
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+type robotsRule struct {
+	allow   bool
+	pattern string
+}
+
+type robotsGroup struct {
+	agents     []string
+	rules      []robotsRule
+	crawlDelay *time.Duration
+}
+
+type _Robots struct {
+	Sitemaps []string
+	//
+	groups []*robotsGroup
+}
+
+type Robots = *_Robots
+
+func ParseRobots(content string) Robots {
+	r := &_Robots{}
+	var group *robotsGroup
+	inAgents := false
+	for _, line := range strings.Split(content, "\n") {
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		switch key {
+		case "user-agent":
+			if !inAgents {
+				group = &robotsGroup{}
+				r.groups = append(r.groups, group)
+			}
+			group.agents = append(group.agents, strings.ToLower(value))
+			inAgents = true
+		case "allow", "disallow":
+			inAgents = false
+			if group != nil && value != "" {
+				group.rules = append(group.rules, robotsRule{allow: key == "allow", pattern: normalizePercentEncoding(value)})
+			}
+		case "crawl-delay":
+			inAgents = false
+			if seconds, err := strconv.ParseFloat(value, 64); group != nil && err == nil && seconds >= 0 {
+				delay := time.Duration(seconds * float64(time.Second))
+				group.crawlDelay = &delay
+			}
+		case "sitemap":
+			if value != "" {
+				r.Sitemaps = append(r.Sitemaps, value)
+			}
+		}
+	}
+	return r
+}
+
+func robotsProductToken(userAgent string) string {
+	token, _, _ := strings.Cut(strings.TrimSpace(userAgent), "/")
+	token, _, _ = strings.Cut(token, " ")
+	return strings.ToLower(token)
+}
+
+func (r Robots) match(userAgent string) []*robotsGroup {
+	token := robotsProductToken(userAgent)
+	var specific, wildcard []*robotsGroup
+	for _, group := range r.groups {
+		for _, agent := range group.agents {
+			if agent == token && token != "" {
+				specific = append(specific, group)
+				break
+			}
+			if agent == "*" {
+				wildcard = append(wildcard, group)
+				break
+			}
+		}
+	}
+	if len(specific) > 0 {
+		return specific
+	}
+	return wildcard
+}
+
+func robotsMatch(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	if anchored {
+		pattern = pattern[:len(pattern)-1]
+	}
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	position := len(parts[0])
+	if len(parts) == 1 {
+		return !anchored || position == len(path)
+	}
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(path[position:], part)
+		if i < 0 {
+			return false
+		}
+		position += i + len(part)
+	}
+	last := parts[len(parts)-1]
+	if anchored {
+		return len(path)-position >= len(last) && strings.HasSuffix(path, last)
+	}
+	return strings.Contains(path[position:], last)
+}
+
+func (r Robots) Allowed(userAgent string, path string) bool {
+	if path == "" {
+		path = "/"
+	}
+	if path == "/robots.txt" {
+		return true
+	}
+	path = normalizePercentEncoding(path)
+	allowed, longest := true, -1
+	for _, group := range r.match(userAgent) {
+		for _, rule := range group.rules {
+			if !robotsMatch(rule.pattern, path) {
+				continue
+			}
+			if length := len(rule.pattern); length > longest || length == longest && rule.allow {
+				allowed, longest = rule.allow, length
+			}
+		}
+	}
+	return allowed
+}
+
+func (r Robots) CrawlDelay(userAgent string) (time.Duration, bool) {
+	for _, group := range r.match(userAgent) {
+		if group.crawlDelay != nil {
+			return *group.crawlDelay, true
+		}
+	}
+	return 0, false
+}
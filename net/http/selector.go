@@ -0,0 +1,528 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"golang.org/x/net/html"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var ErrSelectorSyntax = errors.New("invalid CSS selector")
+
+type nodeMatcher func(n *html.Node) bool
+
+type compoundSelector []nodeMatcher
+
+func (c compoundSelector) match(n *html.Node) bool {
+	if n.Type != html.ElementNode {
+		return false
+	}
+	for _, m := range c {
+		if !m(n) {
+			return false
+		}
+	}
+	return true
+}
+
+type complexSelector struct {
+	compounds   []compoundSelector
+	combinators []byte
+}
+
+func (c complexSelector) matchAt(i int, n *html.Node) bool {
+	if !c.compounds[i].match(n) {
+		return false
+	}
+	if i == 0 {
+		return true
+	}
+	switch c.combinators[i-1] {
+	case ' ':
+		for p := n.Parent; p != nil; p = p.Parent {
+			if c.matchAt(i-1, p) {
+				return true
+			}
+		}
+	case '>':
+		return n.Parent != nil && c.matchAt(i-1, n.Parent)
+	case '+':
+		return previousElement(n) != nil && c.matchAt(i-1, previousElement(n))
+	case '~':
+		for s := previousElement(n); s != nil; s = previousElement(s) {
+			if c.matchAt(i-1, s) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type Selector struct {
+	source    string
+	selectors []complexSelector
+}
+
+func (s Selector) String() string {
+	return s.source
+}
+
+func (s Selector) Match(n *html.Node) bool {
+	for _, c := range s.selectors {
+		if c.matchAt(len(c.compounds)-1, n) {
+			return true
+		}
+	}
+	return false
+}
+
+func CompileSelector(source string) (Selector, error) {
+	p := &selectorParser{s: source}
+	selectors, err := p.selectorList()
+	if err != nil {
+		return Selector{}, err
+	}
+	if p.skipSpace(); p.i < len(p.s) {
+		return Selector{}, p.errorf("unexpected %q", p.s[p.i])
+	}
+	return Selector{source: source, selectors: selectors}, nil
+}
+
+func MustCompileSelector(source string) Selector {
+	s, err := CompileSelector(source)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+func previousElement(n *html.Node) *html.Node {
+	for s := n.PrevSibling; s != nil; s = s.PrevSibling {
+		if s.Type == html.ElementNode {
+			return s
+		}
+	}
+	return nil
+}
+
+func nextElement(n *html.Node) *html.Node {
+	for s := n.NextSibling; s != nil; s = s.NextSibling {
+		if s.Type == html.ElementNode {
+			return s
+		}
+	}
+	return nil
+}
+
+func nodeAttr(n *html.Node, name string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Namespace == "" && strings.EqualFold(a.Key, name) {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+type selectorParser struct {
+	s string
+	i int
+}
+
+func (p *selectorParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("%w: %s at offset %d in %q", ErrSelectorSyntax, fmt.Sprintf(format, args...), p.i, p.s)
+}
+
+func (p *selectorParser) skipSpace() bool {
+	start := p.i
+	for p.i < len(p.s) && strings.IndexByte(" \t\n\r\f", p.s[p.i]) >= 0 {
+		p.i++
+	}
+	return p.i > start
+}
+
+func isIdentByte(c byte, first bool) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '_' || c == '-' || c >= utf8.RuneSelf || !first && '0' <= c && c <= '9'
+}
+
+func (p *selectorParser) ident() (string, error) {
+	sb := strings.Builder{}
+	for p.i < len(p.s) {
+		c := p.s[p.i]
+		switch {
+		case c == '\\' && p.i+1 < len(p.s):
+			r, size := utf8.DecodeRuneInString(p.s[p.i+1:])
+			sb.WriteRune(r)
+			p.i += 1 + size
+		case isIdentByte(c, sb.Len() == 0):
+			sb.WriteByte(c)
+			p.i++
+		default:
+			if sb.Len() == 0 {
+				return "", p.errorf("expected identifier")
+			}
+			return sb.String(), nil
+		}
+	}
+	if sb.Len() == 0 {
+		return "", p.errorf("expected identifier")
+	}
+	return sb.String(), nil
+}
+
+func (p *selectorParser) quoted() (string, error) {
+	quote := p.s[p.i]
+	p.i++
+	sb := strings.Builder{}
+	for p.i < len(p.s) {
+		c := p.s[p.i]
+		switch {
+		case c == quote:
+			p.i++
+			return sb.String(), nil
+		case c == '\\' && p.i+1 < len(p.s):
+			sb.WriteByte(p.s[p.i+1])
+			p.i += 2
+		default:
+			sb.WriteByte(c)
+			p.i++
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+func (p *selectorParser) selectorList() ([]complexSelector, error) {
+	var list []complexSelector
+	for {
+		p.skipSpace()
+		c, err := p.complex()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, c)
+		p.skipSpace()
+		if p.i >= len(p.s) || p.s[p.i] != ',' {
+			return list, nil
+		}
+		p.i++
+	}
+}
+
+func (p *selectorParser) complex() (complexSelector, error) {
+	c := complexSelector{}
+	for {
+		compound, err := p.compound()
+		if err != nil {
+			return c, err
+		}
+		c.compounds = append(c.compounds, compound)
+		spaced := p.skipSpace()
+		if p.i >= len(p.s) || p.s[p.i] == ',' || p.s[p.i] == ')' {
+			return c, nil
+		}
+		combinator := byte(' ')
+		if strings.IndexByte(">+~", p.s[p.i]) >= 0 {
+			combinator = p.s[p.i]
+			p.i++
+			p.skipSpace()
+		} else if !spaced {
+			return c, p.errorf("unexpected %q", p.s[p.i])
+		}
+		c.combinators = append(c.combinators, combinator)
+	}
+}
+
+func (p *selectorParser) compound() (compoundSelector, error) {
+	var compound compoundSelector
+	if p.i < len(p.s) && p.s[p.i] == '*' {
+		p.i++
+		compound = append(compound, func(*html.Node) bool { return true })
+	} else if p.i < len(p.s) && isIdentByte(p.s[p.i], true) {
+		name, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+		name = strings.ToLower(name)
+		compound = append(compound, func(n *html.Node) bool { return n.Data == name })
+	}
+	for p.i < len(p.s) {
+		var m nodeMatcher
+		var err error
+		switch p.s[p.i] {
+		case '#':
+			p.i++
+			var id string
+			if id, err = p.ident(); err == nil {
+				m = func(n *html.Node) bool {
+					v, has := nodeAttr(n, "id")
+					return has && v == id
+				}
+			}
+		case '.':
+			p.i++
+			var class string
+			if class, err = p.ident(); err == nil {
+				m = func(n *html.Node) bool {
+					v, _ := nodeAttr(n, "class")
+					for _, c := range strings.Fields(v) {
+						if c == class {
+							return true
+						}
+					}
+					return false
+				}
+			}
+		case '[':
+			m, err = p.attribute()
+		case ':':
+			m, err = p.pseudo()
+		default:
+			if len(compound) == 0 {
+				return nil, p.errorf("expected selector")
+			}
+			return compound, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		compound = append(compound, m)
+	}
+	if len(compound) == 0 {
+		return nil, p.errorf("expected selector")
+	}
+	return compound, nil
+}
+
+func (p *selectorParser) attribute() (nodeMatcher, error) {
+	p.i++
+	p.skipSpace()
+	name, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.i < len(p.s) && p.s[p.i] == ']' {
+		p.i++
+		return func(n *html.Node) bool {
+			_, has := nodeAttr(n, name)
+			return has
+		}, nil
+	}
+	operator := ""
+	if p.i < len(p.s) && strings.IndexByte("~|^$*", p.s[p.i]) >= 0 {
+		operator = p.s[p.i : p.i+1]
+		p.i++
+	}
+	if p.i >= len(p.s) || p.s[p.i] != '=' {
+		return nil, p.errorf("expected '=' in attribute selector")
+	}
+	p.i++
+	p.skipSpace()
+	var value string
+	if p.i < len(p.s) && (p.s[p.i] == '"' || p.s[p.i] == '\'') {
+		value, err = p.quoted()
+	} else {
+		value, err = p.ident()
+	}
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	fold := false
+	if p.i < len(p.s) && (p.s[p.i] == 'i' || p.s[p.i] == 'I') {
+		fold = true
+		p.i++
+		p.skipSpace()
+	} else if p.i < len(p.s) && (p.s[p.i] == 's' || p.s[p.i] == 'S') {
+		p.i++
+		p.skipSpace()
+	}
+	if p.i >= len(p.s) || p.s[p.i] != ']' {
+		return nil, p.errorf("expected ']'")
+	}
+	p.i++
+	if fold {
+		value = strings.ToLower(value)
+	}
+	return func(n *html.Node) bool {
+		v, has := nodeAttr(n, name)
+		if !has {
+			return false
+		}
+		if fold {
+			v = strings.ToLower(v)
+		}
+		switch operator {
+		case "~":
+			for _, word := range strings.Fields(v) {
+				if word == value {
+					return true
+				}
+			}
+			return false
+		case "|":
+			return v == value || strings.HasPrefix(v, value+"-")
+		case "^":
+			return value != "" && strings.HasPrefix(v, value)
+		case "$":
+			return value != "" && strings.HasSuffix(v, value)
+		case "*":
+			return value != "" && strings.Contains(v, value)
+		}
+		return v == value
+	}, nil
+}
+
+func (p *selectorParser) argument() (string, error) {
+	if p.i >= len(p.s) || p.s[p.i] != '(' {
+		return "", p.errorf("expected '('")
+	}
+	end := strings.IndexByte(p.s[p.i:], ')')
+	if end < 0 {
+		return "", p.errorf("expected ')'")
+	}
+	argument := strings.TrimSpace(p.s[p.i+1 : p.i+end])
+	p.i += end + 1
+	return argument, nil
+}
+
+func parseNth(s string) (a, b int, err error) {
+	s = strings.ToLower(strings.ReplaceAll(s, " ", ""))
+	switch s {
+	case "odd":
+		return 2, 1, nil
+	case "even":
+		return 2, 0, nil
+	}
+	coefficient, offset, hasN := strings.Cut(s, "n")
+	if !hasN {
+		b, err = strconv.Atoi(s)
+		return 0, b, err
+	}
+	switch coefficient {
+	case "", "+":
+		a = 1
+	case "-":
+		a = -1
+	default:
+		if a, err = strconv.Atoi(coefficient); err != nil {
+			return 0, 0, err
+		}
+	}
+	if offset != "" {
+		if b, err = strconv.Atoi(offset); err != nil {
+			return 0, 0, err
+		}
+	}
+	return a, b, nil
+}
+
+func nthMatches(a, b, position int) bool {
+	if a == 0 {
+		return position == b
+	}
+	return (position-b)%a == 0 && (position-b)/a >= 0
+}
+
+func elementPosition(n *html.Node, fromEnd, ofType bool) int {
+	position := 1
+	step := previousElement
+	if fromEnd {
+		step = nextElement
+	}
+	for s := step(n); s != nil; s = step(s) {
+		if !ofType || s.Data == n.Data {
+			position++
+		}
+	}
+	return position
+}
+
+func (p *selectorParser) pseudo() (nodeMatcher, error) {
+	p.i++
+	name, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	name = strings.ToLower(name)
+	switch name {
+	case "first-child":
+		return func(n *html.Node) bool { return previousElement(n) == nil }, nil
+	case "last-child":
+		return func(n *html.Node) bool { return nextElement(n) == nil }, nil
+	case "only-child":
+		return func(n *html.Node) bool { return previousElement(n) == nil && nextElement(n) == nil }, nil
+	case "first-of-type":
+		return func(n *html.Node) bool { return elementPosition(n, false, true) == 1 }, nil
+	case "last-of-type":
+		return func(n *html.Node) bool { return elementPosition(n, true, true) == 1 }, nil
+	case "root":
+		return func(n *html.Node) bool { return n.Parent != nil && n.Parent.Type == html.DocumentNode }, nil
+	case "empty":
+		return func(n *html.Node) bool {
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				if c.Type == html.ElementNode || c.Type == html.TextNode && c.Data != "" {
+					return false
+				}
+			}
+			return true
+		}, nil
+	case "checked":
+		return func(n *html.Node) bool {
+			_, checked := nodeAttr(n, "checked")
+			_, selected := nodeAttr(n, "selected")
+			return checked && n.Data == "input" || selected && n.Data == "option"
+		}, nil
+	case "nth-child", "nth-last-child", "nth-of-type", "nth-last-of-type":
+		argument, err := p.argument()
+		if err != nil {
+			return nil, err
+		}
+		a, b, err := parseNth(argument)
+		if err != nil {
+			return nil, p.errorf("bad :%s argument %q", name, argument)
+		}
+		fromEnd, ofType := strings.Contains(name, "last"), strings.HasSuffix(name, "of-type")
+		return func(n *html.Node) bool { return nthMatches(a, b, elementPosition(n, fromEnd, ofType)) }, nil
+	case "not", "has":
+		if p.i >= len(p.s) || p.s[p.i] != '(' {
+			return nil, p.errorf("expected '('")
+		}
+		p.i++
+		inner, err := p.selectorList()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if p.i >= len(p.s) || p.s[p.i] != ')' {
+			return nil, p.errorf("expected ')'")
+		}
+		p.i++
+		selector := Selector{selectors: inner}
+		if name == "not" {
+			return func(n *html.Node) bool { return !selector.Match(n) }, nil
+		}
+		return func(n *html.Node) bool {
+			found := false
+			walkElements(n, func(d *html.Node) bool {
+				found = d != n && selector.Match(d)
+				return !found
+			})
+			return found
+		}, nil
+	}
+	return nil, p.errorf("unsupported pseudo-class :%s", name)
+}
+
+func walkElements(n *html.Node, visit func(*html.Node) bool) bool {
+	if n.Type == html.ElementNode && !visit(n) {
+		return false
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if !walkElements(c, visit) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,243 @@
+package http
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/TelephoneTan/GoHTTPRequest/util"
+	"github.com/TelephoneTan/GoPromise/async/promise"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const serializeVersion = 2
+
+var (
+	ErrUnsupportedVersion = errors.New("unsupported serialization version")
+	ErrUnserializable     = errors.New("field cannot be serialized")
+)
+
+type BodyCodec interface {
+	EncodeBody(body io.Reader) (string, error)
+	DecodeBody(encoded string) (io.Reader, error)
+}
+
+type InlineBodyCodec struct{}
+
+func (InlineBodyCodec) EncodeBody(body io.Reader) (string, error) {
+	bs, err := io.ReadAll(body)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(bs), nil
+}
+
+func (InlineBodyCodec) DecodeBody(encoded string) (io.Reader, error) {
+	bs, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(bs), nil
+}
+
+type FileBodyCodec struct {
+	Dir string
+}
+
+func (c FileBodyCodec) EncodeBody(body io.Reader) (string, error) {
+	file, err := os.CreateTemp(c.Dir, "body-*")
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	if _, err = io.Copy(file, body); err != nil {
+		return "", err
+	}
+	return filepath.Abs(file.Name())
+}
+
+func (c FileBodyCodec) DecodeBody(encoded string) (io.Reader, error) {
+	return os.Open(encoded)
+}
+
+var semaphoreRegistry = map[string]promise.Semaphore{}
+var semaphoreRegistryLock = sync.Mutex{}
+
+func RegisterSemaphore(name string, semaphore promise.Semaphore) {
+	semaphoreRegistryLock.Lock()
+	defer semaphoreRegistryLock.Unlock()
+	if semaphore == nil {
+		delete(semaphoreRegistry, name)
+	} else {
+		semaphoreRegistry[name] = semaphore
+	}
+}
+
+func semaphoreName(semaphore promise.Semaphore) (string, bool) {
+	semaphoreRegistryLock.Lock()
+	defer semaphoreRegistryLock.Unlock()
+	for name, s := range semaphoreRegistry {
+		if s == semaphore {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+func lookupSemaphore(name string) (promise.Semaphore, bool) {
+	semaphoreRegistryLock.Lock()
+	defer semaphoreRegistryLock.Unlock()
+	s, has := semaphoreRegistry[name]
+	return s, has
+}
+
+type serializedCookieJar struct {
+	Tag      string
+	Readable bool
+	Writable bool
+	Cookies  [][]string
+}
+
+type serializedRequest struct {
+	Version int
+	*_Request
+	RequestFilePath    *string
+	RequestBodyEncoded *string
+	CookieJarSnapshot  *serializedCookieJar
+	RequestSemaphoreID *string
+}
+
+func (r Request) snapshot() (s serializedRequest, err error) {
+	defer func() {
+		if reason := recover(); reason != nil {
+			err = fmt.Errorf("%v", reason)
+		}
+	}()
+	encodedURL, err := r.encodedURL()
+	if err != nil {
+		return s, err
+	}
+	r = util.Copy(*r, func(r Request) {
+		r.EncodedURL = encodedURL
+	})
+	s = serializedRequest{Version: serializeVersion, _Request: r}
+	if r.RequestFile != nil {
+		path, err := filepath.Abs(r.RequestFile.Name())
+		if err != nil {
+			return s, err
+		}
+		s.RequestFilePath = &path
+	}
+	if r.RequestBody != nil && r.RequestFile == nil && len(r.RequestBinary) == 0 && r.RequestString == "" && len(r.RequestForm) == 0 {
+		seeker, seekable := r.RequestBody.(io.Seeker)
+		if !seekable {
+			return s, fmt.Errorf("%w: RequestBody of type %T cannot be rewound", ErrUnserializable, r.RequestBody)
+		}
+		offset, err := seeker.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return s, err
+		}
+		codec := r.BodyCodec
+		if codec == nil {
+			codec = InlineBodyCodec{}
+		}
+		encoded, err := codec.EncodeBody(r.RequestBody)
+		if _, seekErr := seeker.Seek(offset, io.SeekStart); err == nil {
+			err = seekErr
+		}
+		if err != nil {
+			return s, err
+		}
+		s.RequestBodyEncoded = &encoded
+	}
+	if r.CookieJar != nil {
+		jar, isCookieJar := r.CookieJar.(CookieJar)
+		if !isCookieJar {
+			return s, fmt.Errorf("%w: CookieJar of type %T", ErrUnserializable, r.CookieJar)
+		}
+		s.CookieJarSnapshot = &serializedCookieJar{Tag: jar.Tag, Readable: jar.Readable, Writable: jar.Writable}
+		if u, err := url.Parse(r.EncodedURL); err == nil && jar.Jar != nil {
+			if recording, isTimeJar := jar.Jar.(timeJar); isTimeJar {
+				s.CookieJarSnapshot.Cookies = recording.snapshot(u)
+			} else {
+				for _, c := range jar.Jar.Cookies(u) {
+					s.CookieJarSnapshot.Cookies = append(s.CookieJarSnapshot.Cookies, []string{r.EncodedURL, c.String()})
+				}
+			}
+		}
+	}
+	if r.RequestSemaphore != nil {
+		name, registered := semaphoreName(r.RequestSemaphore)
+		if !registered {
+			return s, fmt.Errorf("%w: RequestSemaphore is not registered", ErrUnserializable)
+		}
+		s.RequestSemaphoreID = &name
+	}
+	return s, nil
+}
+
+func (r Request) Serialize() (string, error) {
+	s, err := r.snapshot()
+	if err != nil {
+		return "", err
+	}
+	bs, err := json.Marshal(s)
+	if err != nil {
+		return "", err
+	}
+	return string(bs), nil
+}
+
+func (r Request) Deserialize(data string) (Request, error) {
+	s := serializedRequest{_Request: r}
+	if err := json.Unmarshal([]byte(data), &s); err != nil {
+		return r, err
+	}
+	if s.Version > serializeVersion {
+		return r, fmt.Errorf("%w: %d", ErrUnsupportedVersion, s.Version)
+	}
+	r.URI = r.EncodedURL
+	if s.RequestFilePath != nil {
+		file, err := os.Open(*s.RequestFilePath)
+		if err != nil {
+			return r, err
+		}
+		r.RequestFile = file
+	}
+	if s.RequestBodyEncoded != nil {
+		codec := r.BodyCodec
+		if codec == nil {
+			codec = InlineBodyCodec{}
+		}
+		body, err := codec.DecodeBody(*s.RequestBodyEncoded)
+		if err != nil {
+			return r, err
+		}
+		r.RequestBody = body
+	}
+	if jar := s.CookieJarSnapshot; jar != nil {
+		cookieJar := NewCookieJar(jar.Tag, func(c CookieJar) {
+			c.Readable = jar.Readable
+			c.Writable = jar.Writable
+		})
+		writable := *cookieJar
+		writable.Writable = true
+		writable.SetCookiesManually(jar.Cookies)
+		r.CookieJar = cookieJar
+	}
+	if s.RequestSemaphoreID != nil {
+		semaphore, registered := lookupSemaphore(*s.RequestSemaphoreID)
+		if !registered {
+			return r, fmt.Errorf("%w: RequestSemaphore %q is not registered", ErrUnserializable, *s.RequestSemaphoreID)
+		}
+		r.RequestSemaphore = semaphore
+	}
+	return r, nil
+}
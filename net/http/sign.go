@@ -0,0 +1,566 @@
+package http
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/TelephoneTan/GoHTTPRequest/util"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Signer interface {
+	Sign(request *http.Request) error
+}
+
+func (r Request) signRequest(request *http.Request) {
+	if r.Signer == nil {
+		return
+	}
+	if err := r.Signer.Sign(request); err != nil {
+		panic(err)
+	}
+}
+
+func rewindableBody(request *http.Request) (func() (io.ReadCloser, error), error) {
+	if request.Body == nil || request.Body == http.NoBody {
+		return nil, nil
+	}
+	if request.GetBody == nil {
+		bs, err := io.ReadAll(request.Body)
+		_ = request.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		request.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(bs)), nil
+		}
+		request.Body, _ = request.GetBody()
+		request.ContentLength = int64(len(bs))
+	}
+	return request.GetBody, nil
+}
+
+func hashBody(request *http.Request, newHash func() hash.Hash) ([]byte, error) {
+	h := newHash()
+	getBody, err := rewindableBody(request)
+	if err != nil {
+		return nil, err
+	}
+	if getBody != nil {
+		body, err := getBody()
+		if err != nil {
+			return nil, err
+		}
+		_, err = io.Copy(h, body)
+		_ = body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return h.Sum(nil), nil
+}
+
+func hmacSum(newHash func() hash.Hash, key []byte, data string) []byte {
+	mac := hmac.New(newHash, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func awsURIEncode(s string, keepSlash bool) string {
+	sb := strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' || c == '/' && keepSlash {
+			sb.WriteByte(c)
+		} else {
+			sb.WriteString(fmt.Sprintf("%%%02X", c))
+		}
+	}
+	return sb.String()
+}
+
+type PayloadSigning string
+
+const (
+	SignedPayload    PayloadSigning = ""
+	UnsignedPayload  PayloadSigning = "UNSIGNED-PAYLOAD"
+	StreamingPayload PayloadSigning = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+)
+
+const sigV4Algorithm = "AWS4-HMAC-SHA256"
+
+var (
+	defaultSigV4Expires   = Duration(15 * time.Minute)
+	defaultSigV4ChunkSize = 64 * 1024
+	sigV4IgnoredHeaders   = map[string]bool{
+		"authorization":     true,
+		"user-agent":        true,
+		"content-length":    true,
+		"connection":        true,
+		"expect":            true,
+		"transfer-encoding": true,
+		"x-amzn-trace-id":   true,
+	}
+)
+
+type _SigV4 struct {
+	AccessKeyID            string
+	SecretAccessKey        string
+	SessionToken           string
+	Region                 string
+	Service                string
+	Presign                bool
+	Expires                *Duration
+	Payload                PayloadSigning
+	ChunkSize              int
+	DisableURIPathEscaping bool
+	Now                    func() time.Time
+}
+
+type SigV4 = *_SigV4
+
+func NewSigV4(accessKeyID, secretAccessKey, region, service string, init ...func(SigV4)) SigV4 {
+	return util.New(&_SigV4{
+		AccessKeyID:            accessKeyID,
+		SecretAccessKey:        secretAccessKey,
+		Region:                 region,
+		Service:                service,
+		DisableURIPathEscaping: service == "s3",
+	}, init...)
+}
+
+func (s SigV4) now() time.Time {
+	if s.Now != nil {
+		return s.Now().UTC()
+	}
+	return time.Now().UTC()
+}
+
+func (s SigV4) signingKey(date string) []byte {
+	key := hmacSum(sha256.New, []byte("AWS4"+s.SecretAccessKey), date)
+	key = hmacSum(sha256.New, key, s.Region)
+	key = hmacSum(sha256.New, key, s.Service)
+	return hmacSum(sha256.New, key, "aws4_request")
+}
+
+func (s SigV4) canonicalURI(u *url.URL) string {
+	if s.DisableURIPathEscaping {
+		path := u.Path
+		if path == "" {
+			path = "/"
+		}
+		return awsURIEncode(path, true)
+	}
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	return awsURIEncode(path, true)
+}
+
+func canonicalQuery(query url.Values) string {
+	var pairs []string
+	for k, vs := range query {
+		for _, v := range vs {
+			pairs = append(pairs, awsURIEncode(k, false)+"="+awsURIEncode(v, false))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+func canonicalHeaders(request *http.Request, ignored map[string]bool) (canonical string, signed string) {
+	values := map[string][]string{}
+	host := request.Host
+	if host == "" {
+		host = request.URL.Host
+	}
+	values["host"] = []string{host}
+	for k, vs := range request.Header {
+		name := strings.ToLower(k)
+		if ignored[name] || name == "host" {
+			continue
+		}
+		for _, v := range vs {
+			values[name] = append(values[name], strings.Join(strings.Fields(v), " "))
+		}
+	}
+	var names []string
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	sb := strings.Builder{}
+	for _, name := range names {
+		sb.WriteString(name + ":" + strings.Join(values[name], ",") + "\n")
+	}
+	return sb.String(), strings.Join(names, ";")
+}
+
+func (s SigV4) payloadHash(request *http.Request) (string, error) {
+	if s.Payload != SignedPayload {
+		return string(s.Payload), nil
+	}
+	if s.Presign && s.Service == "s3" {
+		return string(UnsignedPayload), nil
+	}
+	sum, err := hashBody(request, sha256.New)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(sum), nil
+}
+
+func (s SigV4) decodedContentLength(request *http.Request) int64 {
+	if request.ContentLength > 0 {
+		return request.ContentLength
+	}
+	if cl, err := strconv.ParseInt(request.Header.Get("Content-Length"), 10, 64); err == nil {
+		return cl
+	}
+	return -1
+}
+
+func (s SigV4) Sign(request *http.Request) error {
+	now := s.now()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	scope := date + "/" + s.Region + "/" + s.Service + "/aws4_request"
+	payloadHash, err := s.payloadHash(request)
+	if err != nil {
+		return err
+	}
+	if request.ContentLength == 0 && request.Body != nil && request.Body != http.NoBody {
+		if cl := s.decodedContentLength(request); cl > 0 {
+			request.ContentLength = cl
+		}
+	}
+	ignored := sigV4IgnoredHeaders
+	streaming := s.Payload == StreamingPayload && !s.Presign
+	chunkSize := s.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = defaultSigV4ChunkSize
+	}
+	if streaming {
+		decodedLength := s.decodedContentLength(request)
+		if decodedLength < 0 {
+			return errors.New("sigv4: streaming payload requires a known content length")
+		}
+		request.Header.Set("Content-Encoding", "aws-chunked")
+		request.Header.Set("X-Amz-Decoded-Content-Length", strconv.FormatInt(decodedLength, 10))
+		request.ContentLength = awsChunkedLength(decodedLength, int64(chunkSize))
+		request.Header.Set("Content-Length", strconv.FormatInt(request.ContentLength, 10))
+		ignored = map[string]bool{}
+		for k := range sigV4IgnoredHeaders {
+			ignored[k] = k != "content-length"
+		}
+	}
+	query := request.URL.Query()
+	if s.Presign {
+		expires := s.Expires
+		if expires == nil {
+			expires = &defaultSigV4Expires
+		}
+		query.Set("X-Amz-Algorithm", sigV4Algorithm)
+		query.Set("X-Amz-Credential", s.AccessKeyID+"/"+scope)
+		query.Set("X-Amz-Date", amzDate)
+		query.Set("X-Amz-Expires", strconv.FormatInt(int64(time.Duration(*expires)/time.Second), 10))
+		if s.SessionToken != "" {
+			query.Set("X-Amz-Security-Token", s.SessionToken)
+		}
+	} else {
+		request.Header.Set("X-Amz-Date", amzDate)
+		if s.SessionToken != "" {
+			request.Header.Set("X-Amz-Security-Token", s.SessionToken)
+		}
+		if s.Service == "s3" || s.Payload != SignedPayload {
+			request.Header.Set("X-Amz-Content-Sha256", payloadHash)
+		}
+	}
+	if s.Presign {
+		ignored = map[string]bool{}
+		for k := range sigV4IgnoredHeaders {
+			ignored[k] = true
+		}
+		for k := range request.Header {
+			if name := strings.ToLower(k); !strings.HasPrefix(name, "x-amz-") {
+				ignored[name] = true
+			}
+		}
+	}
+	headers, signedHeaders := canonicalHeaders(request, ignored)
+	if s.Presign {
+		query.Set("X-Amz-SignedHeaders", signedHeaders)
+	}
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		s.canonicalURI(request.URL),
+		canonicalQuery(query),
+		headers,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	stringToSign := strings.Join([]string{sigV4Algorithm, amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+	key := s.signingKey(date)
+	signature := hex.EncodeToString(hmacSum(sha256.New, key, stringToSign))
+	if s.Presign {
+		request.URL.RawQuery = canonicalQuery(query) + "&X-Amz-Signature=" + signature
+		return nil
+	}
+	request.Header.Set("Authorization", sigV4Algorithm+" Credential="+s.AccessKeyID+"/"+scope+", SignedHeaders="+signedHeaders+", Signature="+signature)
+	if streaming {
+		newChunked := func(body io.ReadCloser) io.ReadCloser {
+			return &awsChunkedReader{
+				body:      body,
+				chunk:     make([]byte, chunkSize),
+				key:       key,
+				amzDate:   amzDate,
+				scope:     scope,
+				signature: signature,
+			}
+		}
+		if request.Body != nil && request.Body != http.NoBody {
+			request.Body = newChunked(request.Body)
+		} else {
+			request.Body = newChunked(http.NoBody)
+		}
+		if getBody := request.GetBody; getBody != nil {
+			request.GetBody = func() (io.ReadCloser, error) {
+				body, err := getBody()
+				if err != nil {
+					return nil, err
+				}
+				return newChunked(body), nil
+			}
+		}
+	}
+	return nil
+}
+
+func (s SigV4) PresignURL(r Request) (string, error) {
+	encodedURL, err := r.encodedURL()
+	if err != nil {
+		return "", err
+	}
+	request, err := http.NewRequest(r.generateRequestMethod(), encodedURL, nil)
+	if err != nil {
+		return "", err
+	}
+	r.applyRequestHeaders(request)
+	presign := util.Copy(*s, func(s SigV4) {
+		s.Presign = true
+	})
+	if err := presign.Sign(request); err != nil {
+		return "", err
+	}
+	return request.URL.String(), nil
+}
+
+func awsChunkHeaderLength(size int64) int64 {
+	return int64(len(strconv.FormatInt(size, 16))) + int64(len(";chunk-signature=")) + 64 + 2
+}
+
+func awsChunkedLength(decoded int64, chunkSize int64) int64 {
+	full := decoded / chunkSize
+	length := full * (awsChunkHeaderLength(chunkSize) + chunkSize + 2)
+	if rest := decoded % chunkSize; rest > 0 {
+		length += awsChunkHeaderLength(rest) + rest + 2
+	}
+	return length + awsChunkHeaderLength(0) + 2
+}
+
+type awsChunkedReader struct {
+	body      io.ReadCloser
+	chunk     []byte
+	key       []byte
+	amzDate   string
+	scope     string
+	signature string
+	pending   bytes.Buffer
+	finished  bool
+}
+
+func (a *awsChunkedReader) writeChunk(data []byte) {
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256-PAYLOAD",
+		a.amzDate,
+		a.scope,
+		a.signature,
+		sha256Hex(nil),
+		sha256Hex(data),
+	}, "\n")
+	a.signature = hex.EncodeToString(hmacSum(sha256.New, a.key, stringToSign))
+	a.pending.WriteString(strconv.FormatInt(int64(len(data)), 16) + ";chunk-signature=" + a.signature + "\r\n")
+	a.pending.Write(data)
+	a.pending.WriteString("\r\n")
+}
+
+func (a *awsChunkedReader) Read(p []byte) (int, error) {
+	for a.pending.Len() == 0 {
+		if a.finished {
+			return 0, io.EOF
+		}
+		n, err := io.ReadFull(a.body, a.chunk)
+		if n > 0 {
+			a.writeChunk(a.chunk[:n])
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			a.writeChunk(nil)
+			a.finished = true
+		} else if err != nil {
+			return 0, err
+		}
+	}
+	return a.pending.Read(p)
+}
+
+func (a *awsChunkedReader) Close() error {
+	return a.body.Close()
+}
+
+type HMACCanonical struct {
+	Method    string
+	Path      string
+	Query     string
+	Headers   [][]string
+	Timestamp string
+	Nonce     string
+	BodyHash  string
+}
+
+func (c HMACCanonical) String() string {
+	lines := []string{c.Method, c.Path, c.Query}
+	for _, kv := range c.Headers {
+		lines = append(lines, kv[0]+":"+kv[1])
+	}
+	return strings.Join(append(lines, c.Timestamp, c.Nonce, c.BodyHash), "\n")
+}
+
+type _HMACSigner struct {
+	KeyID           string
+	Key             []byte
+	Hash            func() hash.Hash
+	Headers         []string
+	SignBody        bool
+	HexEncoding     bool
+	TimestampHeader string
+	TimestampFormat string
+	NonceHeader     string
+	SignatureHeader string
+	Canonical       func(c HMACCanonical) string
+	Format          func(keyID, signedHeaders, signature string) string
+	Now             func() time.Time
+	Nonce           func() string
+}
+
+type HMACSigner = *_HMACSigner
+
+func NewHMACSigner(keyID string, key []byte, init ...func(HMACSigner)) HMACSigner {
+	return util.New(&_HMACSigner{
+		KeyID:           keyID,
+		Key:             key,
+		Hash:            sha256.New,
+		SignBody:        true,
+		TimestampHeader: "X-Timestamp",
+		SignatureHeader: "Authorization",
+	}, init...)
+}
+
+func (h HMACSigner) timestamp() string {
+	now := time.Now()
+	if h.Now != nil {
+		now = h.Now()
+	}
+	if h.TimestampFormat == "" {
+		return strconv.FormatInt(now.Unix(), 10)
+	}
+	return now.UTC().Format(h.TimestampFormat)
+}
+
+func (h HMACSigner) nonce() (string, error) {
+	if h.Nonce != nil {
+		return h.Nonce(), nil
+	}
+	bs := make([]byte, 16)
+	if _, err := rand.Read(bs); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bs), nil
+}
+
+func (h HMACSigner) encode(bs []byte) string {
+	if h.HexEncoding {
+		return hex.EncodeToString(bs)
+	}
+	return base64.StdEncoding.EncodeToString(bs)
+}
+
+func (h HMACSigner) Sign(request *http.Request) error {
+	newHash := h.Hash
+	if newHash == nil {
+		newHash = sha256.New
+	}
+	c := HMACCanonical{
+		Method: request.Method,
+		Path:   request.URL.EscapedPath(),
+		Query:  request.URL.RawQuery,
+	}
+	if h.TimestampHeader != "" {
+		c.Timestamp = h.timestamp()
+		request.Header.Set(h.TimestampHeader, c.Timestamp)
+	}
+	if h.NonceHeader != "" {
+		nonce, err := h.nonce()
+		if err != nil {
+			return err
+		}
+		c.Nonce = nonce
+		request.Header.Set(h.NonceHeader, c.Nonce)
+	}
+	if h.SignBody {
+		sum, err := hashBody(request, newHash)
+		if err != nil {
+			return err
+		}
+		c.BodyHash = h.encode(sum)
+	}
+	var signedHeaders []string
+	for _, name := range h.Headers {
+		value := request.Header.Get(name)
+		if strings.EqualFold(name, "host") {
+			if value = request.Host; value == "" {
+				value = request.URL.Host
+			}
+		}
+		c.Headers = append(c.Headers, []string{strings.ToLower(name), strings.TrimSpace(value)})
+		signedHeaders = append(signedHeaders, strings.ToLower(name))
+	}
+	canonical := c.String()
+	if h.Canonical != nil {
+		canonical = h.Canonical(c)
+	}
+	signature := h.encode(hmacSum(newHash, h.Key, canonical))
+	value := "HMAC " + h.KeyID + ":" + signature
+	if h.Format != nil {
+		value = h.Format(h.KeyID, strings.Join(signedHeaders, ";"), signature)
+	}
+	request.Header.Set(h.SignatureHeader, value)
+	return nil
+}
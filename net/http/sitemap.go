@@ -0,0 +1,75 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var ErrSitemap = errors.New("invalid sitemap")
+
+const maxSitemapSize = 50 << 20
+
+type Sitemap struct {
+	URLs     []string
+	Sitemaps []string
+}
+
+type sitemapLocation struct {
+	Loc string `xml:"loc"`
+}
+
+type sitemapDocument struct {
+	XMLName  xml.Name
+	URLs     []sitemapLocation `xml:"url"`
+	Sitemaps []sitemapLocation `xml:"sitemap"`
+}
+
+func ParseSitemap(content []byte) (Sitemap, error) {
+	if bytes.HasPrefix(content, []byte{0x1f, 0x8b}) {
+		reader, err := gzip.NewReader(bytes.NewReader(content))
+		if err != nil {
+			return Sitemap{}, fmt.Errorf("%w: %v", ErrSitemap, err)
+		}
+		content, err = io.ReadAll(io.LimitReader(reader, maxSitemapSize))
+		if err != nil {
+			return Sitemap{}, fmt.Errorf("%w: %v", ErrSitemap, err)
+		}
+	}
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(content, []byte{0xEF, 0xBB, 0xBF}))
+	sitemap := Sitemap{}
+	if !bytes.HasPrefix(trimmed, []byte("<")) {
+		scanner := bufio.NewScanner(bytes.NewReader(trimmed))
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				sitemap.URLs = append(sitemap.URLs, line)
+			}
+		}
+		return sitemap, scanner.Err()
+	}
+	document := sitemapDocument{}
+	if err := xml.Unmarshal(trimmed, &document); err != nil {
+		return Sitemap{}, fmt.Errorf("%w: %v", ErrSitemap, err)
+	}
+	switch document.XMLName.Local {
+	case "urlset", "sitemapindex":
+	default:
+		return Sitemap{}, fmt.Errorf("%w: unexpected root element %q", ErrSitemap, document.XMLName.Local)
+	}
+	for _, location := range document.URLs {
+		if loc := strings.TrimSpace(location.Loc); loc != "" {
+			sitemap.URLs = append(sitemap.URLs, loc)
+		}
+	}
+	for _, location := range document.Sitemaps {
+		if loc := strings.TrimSpace(location.Loc); loc != "" {
+			sitemap.Sitemaps = append(sitemap.Sitemaps, loc)
+		}
+	}
+	return sitemap, nil
+}
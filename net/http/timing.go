@@ -0,0 +1,155 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+type PreciseDuration time.Duration
+
+func (d *PreciseDuration) UnmarshalJSON(bs []byte) error {
+	var ms *float64
+	if err := json.Unmarshal(bs, &ms); err != nil {
+		return err
+	}
+	if ms != nil {
+		*d = PreciseDuration(math.Round(*ms * float64(time.Millisecond)))
+	}
+	return nil
+}
+
+func (d *PreciseDuration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(float64(time.Duration(*d).Microseconds()) / 1000)
+}
+
+type Timing struct {
+	Start            time.Time
+	DNS              PreciseDuration
+	Connect          PreciseDuration
+	TLSHandshake     PreciseDuration
+	ServerProcessing PreciseDuration
+	TimeToFirstByte  PreciseDuration
+	Transfer         PreciseDuration
+	Total            PreciseDuration
+	ConnectionReused bool
+	RemoteAddress    string
+}
+
+type timingTracer struct {
+	lock          sync.Mutex
+	timing        *Timing
+	start         time.Time
+	dnsStart      time.Time
+	connectStart  time.Time
+	tlsStart      time.Time
+	wroteRequest  time.Time
+	firstByte     time.Time
+	transferEnded bool
+}
+
+func since(t time.Time, now time.Time) PreciseDuration {
+	if t.IsZero() {
+		return 0
+	}
+	return PreciseDuration(now.Sub(t))
+}
+
+func (t *timingTracer) update(f func(now time.Time)) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	f(time.Now())
+}
+
+func (t *timingTracer) trace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.update(func(now time.Time) {
+				t.dnsStart = now
+			})
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.update(func(now time.Time) {
+				t.timing.DNS = since(t.dnsStart, now)
+			})
+		},
+		ConnectStart: func(string, string) {
+			t.update(func(now time.Time) {
+				if t.connectStart.IsZero() {
+					t.connectStart = now
+				}
+			})
+		},
+		ConnectDone: func(string, string, error) {
+			t.update(func(now time.Time) {
+				t.timing.Connect = since(t.connectStart, now)
+			})
+		},
+		TLSHandshakeStart: func() {
+			t.update(func(now time.Time) {
+				t.tlsStart = now
+			})
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.update(func(now time.Time) {
+				t.timing.TLSHandshake = since(t.tlsStart, now)
+			})
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.update(func(now time.Time) {
+				t.timing.ConnectionReused = info.Reused
+				if info.Conn != nil {
+					t.timing.RemoteAddress = info.Conn.RemoteAddr().String()
+				}
+				t.connectStart = time.Time{}
+			})
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			t.update(func(now time.Time) {
+				t.wroteRequest = now
+			})
+		},
+		GotFirstResponseByte: func() {
+			t.update(func(now time.Time) {
+				t.firstByte = now
+				t.timing.ServerProcessing = since(t.wroteRequest, now)
+				t.timing.TimeToFirstByte = since(t.start, now)
+			})
+		},
+	}
+}
+
+func (t *timingTracer) endTransfer() {
+	t.update(func(now time.Time) {
+		if t.transferEnded {
+			return
+		}
+		t.transferEnded = true
+		t.timing.Transfer = since(t.firstByte, now)
+		t.timing.Total = since(t.start, now)
+	})
+}
+
+type timingReader struct {
+	reader io.Reader
+	tracer *timingTracer
+}
+
+func (t *timingReader) Read(p []byte) (int, error) {
+	n, err := t.reader.Read(p)
+	if err != nil {
+		t.tracer.endTransfer()
+	}
+	return n, err
+}
+
+func (r Request) traceContext(ctx context.Context) (context.Context, *timingTracer) {
+	r.Timing = &Timing{Start: time.Now()}
+	tracer := &timingTracer{timing: r.Timing, start: r.Timing.Start}
+	return httptrace.WithClientTrace(ctx, tracer.trace()), tracer
+}
@@ -0,0 +1,298 @@
+package http
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	TraceParentHeader = "traceparent"
+	TraceStateHeader  = "tracestate"
+)
+
+var ErrInvalidTraceParent = errors.New("invalid traceparent")
+
+type SpanContext struct {
+	TraceID    [16]byte
+	SpanID     [8]byte
+	Flags      byte
+	TraceState string
+}
+
+func (s SpanContext) IsValid() bool {
+	return s.TraceID != [16]byte{} && s.SpanID != [8]byte{}
+}
+
+func (s SpanContext) TraceParent() string {
+	return "00-" + hex.EncodeToString(s.TraceID[:]) + "-" + hex.EncodeToString(s.SpanID[:]) + "-" + hex.EncodeToString([]byte{s.Flags})
+}
+
+func ParseTraceParent(traceParent, traceState string) (SpanContext, error) {
+	sc := SpanContext{TraceState: strings.TrimSpace(traceState)}
+	parts := strings.Split(strings.TrimSpace(traceParent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || parts[0] == "00" && len(parts) != 4 {
+		return SpanContext{}, ErrInvalidTraceParent
+	}
+	if _, err := hex.DecodeString(parts[0]); err != nil {
+		return SpanContext{}, ErrInvalidTraceParent
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil || len(parts[1]) != 32 {
+		return SpanContext{}, ErrInvalidTraceParent
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil || len(parts[2]) != 16 {
+		return SpanContext{}, ErrInvalidTraceParent
+	}
+	flags, err := strconv.ParseUint(parts[3], 16, 8)
+	if err != nil || len(parts[3]) != 2 || strings.ToLower(strings.Join(parts[:4], "")) != strings.Join(parts[:4], "") {
+		return SpanContext{}, ErrInvalidTraceParent
+	}
+	sc.Flags = byte(flags)
+	if !sc.IsValid() {
+		return SpanContext{}, ErrInvalidTraceParent
+	}
+	return sc, nil
+}
+
+type spanContextKey struct{}
+
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, sc)
+}
+
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	sc, _ := ctx.Value(spanContextKey{}).(SpanContext)
+	return sc
+}
+
+type Span interface {
+	SpanContext() SpanContext
+	SetAttribute(key string, value any)
+	RecordError(err error)
+	End()
+}
+
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+type noopSpan struct {
+	sc SpanContext
+}
+
+func (s noopSpan) SpanContext() SpanContext {
+	return s.sc
+}
+
+func (s noopSpan) SetAttribute(string, any) {}
+
+func (s noopSpan) RecordError(error) {}
+
+func (s noopSpan) End() {}
+
+type NoopTracer struct{}
+
+func (NoopTracer) Start(ctx context.Context, _ string) (context.Context, Span) {
+	return ctx, noopSpan{sc: SpanContextFromContext(ctx)}
+}
+
+type RecordedSpan struct {
+	Name         string
+	TraceID      string
+	SpanID       string
+	ParentSpanID string
+	Attributes   map[string]any
+	Errors       []error
+	Start        time.Time
+	End          time.Time
+	Ended        bool
+}
+
+type memorySpan struct {
+	tracer *InMemoryTracer
+	index  int
+	sc     SpanContext
+}
+
+func (s *memorySpan) SpanContext() SpanContext {
+	return s.sc
+}
+
+func (s *memorySpan) update(f func(span *RecordedSpan)) {
+	s.tracer.lock.Lock()
+	defer s.tracer.lock.Unlock()
+	if s.index < len(s.tracer.spans) {
+		if span := &s.tracer.spans[s.index]; !span.Ended {
+			f(span)
+		}
+	}
+}
+
+func (s *memorySpan) SetAttribute(key string, value any) {
+	s.update(func(span *RecordedSpan) {
+		span.Attributes[key] = value
+	})
+}
+
+func (s *memorySpan) RecordError(err error) {
+	s.update(func(span *RecordedSpan) {
+		span.Errors = append(span.Errors, err)
+	})
+}
+
+func (s *memorySpan) End() {
+	s.update(func(span *RecordedSpan) {
+		span.Ended = true
+		span.End = time.Now()
+	})
+}
+
+type InMemoryTracer struct {
+	lock  sync.Mutex
+	spans []RecordedSpan
+}
+
+func (t *InMemoryTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	parent := SpanContextFromContext(ctx)
+	sc := SpanContext{TraceID: parent.TraceID, Flags: 1, TraceState: parent.TraceState}
+	if parent.IsValid() {
+		sc.Flags = parent.Flags
+	} else {
+		_, _ = rand.Read(sc.TraceID[:])
+	}
+	_, _ = rand.Read(sc.SpanID[:])
+	recorded := RecordedSpan{
+		Name:       name,
+		TraceID:    hex.EncodeToString(sc.TraceID[:]),
+		SpanID:     hex.EncodeToString(sc.SpanID[:]),
+		Attributes: map[string]any{},
+		Start:      time.Now(),
+	}
+	if parent.IsValid() {
+		recorded.ParentSpanID = hex.EncodeToString(parent.SpanID[:])
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.spans = append(t.spans, recorded)
+	return ContextWithSpanContext(ctx, sc), &memorySpan{tracer: t, index: len(t.spans) - 1, sc: sc}
+}
+
+func (t *InMemoryTracer) Spans() []RecordedSpan {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	spans := make([]RecordedSpan, len(t.spans))
+	for i, span := range t.spans {
+		spans[i] = span
+		spans[i].Attributes = map[string]any{}
+		for k, v := range span.Attributes {
+			spans[i].Attributes[k] = v
+		}
+		spans[i].Errors = append([]error{}, span.Errors...)
+	}
+	return spans
+}
+
+func (t *InMemoryTracer) Reset() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.spans = nil
+}
+
+func recordSpanError(span Span, reason any) {
+	err, isErr := reason.(error)
+	if !isErr {
+		err = fmt.Errorf("%v", reason)
+	}
+	span.RecordError(err)
+}
+
+func recordSpanStatus(span Span, statusCode int, status string) {
+	span.SetAttribute("http.response.status_code", statusCode)
+	if statusCode >= 400 {
+		span.RecordError(errors.New(status))
+	}
+}
+
+type tracingTransport struct {
+	base    http.RoundTripper
+	tracer  Tracer
+	attempt int
+	lock    sync.Mutex
+}
+
+func (t *tracingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	t.lock.Lock()
+	t.attempt++
+	attempt := t.attempt
+	t.lock.Unlock()
+	ctx, span := t.tracer.Start(request.Context(), "HTTP "+request.Method)
+	defer span.End()
+	span.SetAttribute("http.request.method", request.Method)
+	span.SetAttribute("url.full", withoutUserinfo(request.URL))
+	span.SetAttribute("server.address", request.URL.Host)
+	if attempt > 1 {
+		span.SetAttribute("http.request.resend_count", attempt-1)
+	}
+	traced := request.WithContext(ctx)
+	if sc := span.SpanContext(); sc.IsValid() {
+		traced = traced.Clone(ctx)
+		traced.Header.Set(TraceParentHeader, sc.TraceParent())
+		if sc.TraceState != "" {
+			traced.Header.Set(TraceStateHeader, sc.TraceState)
+		} else {
+			traced.Header.Del(TraceStateHeader)
+		}
+	}
+	response, err := t.base.RoundTrip(traced)
+	if err != nil {
+		span.RecordError(err)
+		return response, err
+	}
+	recordSpanStatus(span, response.StatusCode, response.Status)
+	return response, nil
+}
+
+func withoutUserinfo(u *url.URL) string {
+	clone := *u
+	clone.User = nil
+	return clone.String()
+}
+
+func (r Request) generateTracingTransport(base http.RoundTripper) http.RoundTripper {
+	if r.Tracer == nil {
+		return base
+	}
+	return &tracingTransport{base: base, tracer: r.Tracer}
+}
+
+func (r Request) startSpan(ctx context.Context) context.Context {
+	if r.Tracer == nil {
+		return ctx
+	}
+	ctx, r.span = r.Tracer.Start(ctx, "HTTP "+r.generateRequestMethod())
+	r.span.SetAttribute("http.request.method", r.generateRequestMethod())
+	if u, err := r.parsedURL(); err == nil {
+		r.span.SetAttribute("url.full", withoutUserinfo(u))
+	}
+	return ctx
+}
+
+func (r Request) endSpan(reason any) {
+	if r.span == nil {
+		return
+	}
+	if reason != nil {
+		recordSpanError(r.span, reason)
+	} else {
+		recordSpanStatus(r.span, r.StatusCode, r.StatusMessage)
+	}
+	r.span.End()
+}
@@ -0,0 +1,262 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var ErrURITemplate = errors.New("invalid URI template")
+
+type uriOperator struct {
+	first         string
+	separator     string
+	named         bool
+	ifEmpty       string
+	allowReserved bool
+}
+
+var uriOperators = map[byte]uriOperator{
+	0:   {first: "", separator: ","},
+	'+': {first: "", separator: ",", allowReserved: true},
+	'#': {first: "#", separator: ",", allowReserved: true},
+	'.': {first: ".", separator: "."},
+	'/': {first: "/", separator: "/"},
+	';': {first: ";", separator: ";", named: true},
+	'?': {first: "?", separator: "&", named: true, ifEmpty: "="},
+	'&': {first: "&", separator: "&", named: true, ifEmpty: "="},
+}
+
+const uriReserved = ":/?#[]@!$&'()*+,;="
+
+func isUnreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func uriEscape(s string, allowReserved bool) string {
+	sb := strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case isUnreserved(c):
+			sb.WriteByte(c)
+		case allowReserved && strings.IndexByte(uriReserved, c) >= 0:
+			sb.WriteByte(c)
+		case allowReserved && c == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]):
+			sb.WriteString(s[i : i+3])
+			i += 2
+		default:
+			sb.WriteString(fmt.Sprintf("%%%02X", c))
+		}
+	}
+	return sb.String()
+}
+
+type uriVariable struct {
+	name    string
+	prefix  int
+	explode bool
+}
+
+func parseURIVariable(spec string) (uriVariable, error) {
+	v := uriVariable{name: spec}
+	if strings.HasSuffix(spec, "*") {
+		v.name, v.explode = strings.TrimSuffix(spec, "*"), true
+	} else if name, prefix, found := strings.Cut(spec, ":"); found {
+		n, err := strconv.Atoi(prefix)
+		if err != nil || n <= 0 || n >= 10000 {
+			return v, fmt.Errorf("%w: bad prefix in %q", ErrURITemplate, spec)
+		}
+		v.name, v.prefix = name, n
+	}
+	if v.name == "" {
+		return v, fmt.Errorf("%w: empty variable name", ErrURITemplate)
+	}
+	for i := 0; i < len(v.name); i++ {
+		if c := v.name[i]; !(isUnreserved(c) && c != '-' && c != '~' || c == '%') {
+			return v, fmt.Errorf("%w: bad variable name %q", ErrURITemplate, v.name)
+		}
+	}
+	return v, nil
+}
+
+func uriScalar(value any) (string, bool) {
+	switch v := value.(type) {
+	case nil:
+		return "", false
+	case string:
+		return v, true
+	case fmt.Stringer:
+		return v.String(), true
+	case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return fmt.Sprint(v), true
+	}
+	return "", false
+}
+
+func uriList(value any) ([]string, bool) {
+	var list []string
+	switch v := value.(type) {
+	case []string:
+		list = v
+	case []any:
+		for _, item := range v {
+			if s, ok := uriScalar(item); ok {
+				list = append(list, s)
+			}
+		}
+	default:
+		return nil, false
+	}
+	return list, true
+}
+
+func uriPairs(value any) ([][2]string, bool) {
+	var pairs [][2]string
+	switch v := value.(type) {
+	case [][]string:
+		for _, kv := range v {
+			if len(kv) > 1 {
+				pairs = append(pairs, [2]string{kv[0], kv[1]})
+			}
+		}
+	case map[string]string:
+		for k, item := range v {
+			pairs = append(pairs, [2]string{k, item})
+		}
+		sort.Slice(pairs, func(i, j int) bool { return pairs[i][0] < pairs[j][0] })
+	case map[string]any:
+		for k, item := range v {
+			if s, ok := uriScalar(item); ok {
+				pairs = append(pairs, [2]string{k, s})
+			}
+		}
+		sort.Slice(pairs, func(i, j int) bool { return pairs[i][0] < pairs[j][0] })
+	default:
+		return nil, false
+	}
+	return pairs, true
+}
+
+func expandURIVariable(op uriOperator, v uriVariable, value any) (string, bool, error) {
+	named := func(name, s string) string {
+		if !op.named {
+			return s
+		}
+		if s == "" {
+			return name + op.ifEmpty
+		}
+		return name + "=" + s
+	}
+	if s, ok := uriScalar(value); ok {
+		if v.prefix > 0 && utf8.RuneCountInString(s) > v.prefix {
+			s = string([]rune(s)[:v.prefix])
+		}
+		return named(v.name, uriEscape(s, op.allowReserved)), true, nil
+	}
+	if value == nil {
+		return "", false, nil
+	}
+	list, isList := uriList(value)
+	pairs, isPairs := uriPairs(value)
+	if !isList && !isPairs {
+		return "", false, fmt.Errorf("%w: unsupported value for %q: %T", ErrURITemplate, v.name, value)
+	}
+	if v.prefix > 0 {
+		return "", false, fmt.Errorf("%w: prefix modifier on composite value %q", ErrURITemplate, v.name)
+	}
+	if len(list) == 0 && len(pairs) == 0 {
+		return "", false, nil
+	}
+	var parts []string
+	if isList {
+		for _, item := range list {
+			if v.explode {
+				parts = append(parts, named(v.name, uriEscape(item, op.allowReserved)))
+			} else {
+				parts = append(parts, uriEscape(item, op.allowReserved))
+			}
+		}
+	} else {
+		for _, kv := range pairs {
+			k, item := uriEscape(kv[0], op.allowReserved), uriEscape(kv[1], op.allowReserved)
+			if v.explode {
+				if op.named && item == "" {
+					parts = append(parts, k+op.ifEmpty)
+				} else {
+					parts = append(parts, k+"="+item)
+				}
+			} else {
+				parts = append(parts, k, item)
+			}
+		}
+	}
+	if v.explode {
+		return strings.Join(parts, op.separator), true, nil
+	}
+	joined := strings.Join(parts, ",")
+	if op.named {
+		return v.name + "=" + joined, true, nil
+	}
+	return joined, true, nil
+}
+
+func ExpandURITemplate(template string, variables map[string]any) (string, error) {
+	sb := strings.Builder{}
+	for {
+		open := strings.IndexByte(template, '{')
+		if open < 0 {
+			if strings.IndexByte(template, '}') >= 0 {
+				return "", fmt.Errorf("%w: unmatched '}'", ErrURITemplate)
+			}
+			sb.WriteString(template)
+			return sb.String(), nil
+		}
+		closing := strings.IndexByte(template[open:], '}')
+		if closing < 0 {
+			return "", fmt.Errorf("%w: unclosed expression", ErrURITemplate)
+		}
+		sb.WriteString(template[:open])
+		expression := template[open+1 : open+closing]
+		template = template[open+closing+1:]
+		if expression == "" {
+			return "", fmt.Errorf("%w: empty expression", ErrURITemplate)
+		}
+		op, has := uriOperators[expression[0]]
+		if has {
+			expression = expression[1:]
+		} else if strings.IndexByte("=,!@|", expression[0]) >= 0 {
+			return "", fmt.Errorf("%w: reserved operator %q", ErrURITemplate, expression[0])
+		} else {
+			op = uriOperators[0]
+		}
+		first := true
+		for _, spec := range strings.Split(expression, ",") {
+			v, err := parseURIVariable(spec)
+			if err != nil {
+				return "", err
+			}
+			expanded, defined, err := expandURIVariable(op, v, variables[v.name])
+			if err != nil {
+				return "", err
+			}
+			if !defined {
+				continue
+			}
+			if first {
+				sb.WriteString(op.first)
+				first = false
+			} else {
+				sb.WriteString(op.separator)
+			}
+			sb.WriteString(expanded)
+		}
+	}
+}
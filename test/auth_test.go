@@ -0,0 +1,86 @@
+package test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/TelephoneTan/GoHTTPRequest/net/http"
+	"github.com/TelephoneTan/GoHTTPRequest/net/http/method"
+	"io"
+	gohttp "net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+type bytesOutObserver struct {
+	bytesOut atomic.Int64
+}
+
+func (o *bytesOutObserver) ObserveStart(string, string) {}
+
+func (o *bytesOutObserver) ObserveEnd(metrics http.RequestMetrics) {
+	o.bytesOut.Store(metrics.BytesOut)
+}
+
+func TestDigestAuth(t *testing.T) {
+	const realm, nonce, username, password = "http-auth@example.org", "7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v", "Mufasa", "Circle of Life"
+	h := func(s string) string {
+		sum := sha256.Sum256([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+	var challenges atomic.Int32
+	server := httptest.NewServer(gohttp.HandlerFunc(func(w gohttp.ResponseWriter, r *gohttp.Request) {
+		body, _ := io.ReadAll(r.Body)
+		for _, c := range http.ParseAuthChallenges(r.Header.Values("Authorization")) {
+			p := c.Params
+			ha1 := h(username + ":" + realm + ":" + password)
+			ha2 := h(r.Method + ":" + p["uri"] + ":" + h(string(body)))
+			if c.Scheme == "Digest" && p["algorithm"] == "SHA-256" && p["qop"] == "auth-int" && p["uri"] == r.URL.RequestURI() &&
+				p["response"] == h(ha1+":"+nonce+":"+p["nc"]+":"+p["cnonce"]+":auth-int:"+ha2) {
+				_, _ = w.Write([]byte(p["nc"]))
+				return
+			}
+		}
+		challenges.Add(1)
+		w.Header().Add("WWW-Authenticate", `Digest realm="`+realm+`", qop="auth-int", algorithm=MD5, nonce="`+nonce+`"`)
+		w.Header().Add("WWW-Authenticate", `Digest realm="`+realm+`", qop="auth-int", algorithm=SHA-256, nonce="`+nonce+`", opaque="FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS"`)
+		w.WriteHeader(gohttp.StatusUnauthorized)
+	}))
+	defer server.Close()
+	auth := http.NewDigestAuth(username, password)
+	for i, want := range []string{"00000001", "00000002"} {
+		observer := &bytesOutObserver{}
+		res, err := await(http.NewRequest(func(request http.Request) {
+			request.Method = method.POST
+			request.URL = server.URL + "/dir/index.html?a=1"
+			request.RequestString = "payload"
+			request.Authenticator = auth
+			request.Metrics = observer
+		}).String())
+		if err != nil || res.Request.StatusCode != gohttp.StatusOK || res.Result != want {
+			t.Fatalf("request %d: unexpected response %q %v", i, res.Result, err)
+		}
+		if i == 1 && observer.bytesOut.Load() != int64(len("payload")) {
+			t.Errorf("body hashing counted as upload: %d bytes out", observer.bytesOut.Load())
+		}
+	}
+	if challenges.Load() != 1 {
+		t.Fatalf("expected the cached challenge to be reused, got %d challenges", challenges.Load())
+	}
+}
+
+func TestBasicAuth(t *testing.T) {
+	server := httptest.NewServer(gohttp.HandlerFunc(func(w gohttp.ResponseWriter, r *gohttp.Request) {
+		if u, p, ok := r.BasicAuth(); !ok || u != "user" || p != "pass" {
+			w.WriteHeader(gohttp.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+	request := http.NewRequest(func(request http.Request) {
+		request.URL = server.URL
+		request.Authenticator = &http.BasicAuth{Username: "user", Password: "pass"}
+	})
+	if _, err := await(request.Send()); err != nil || request.StatusCode != gohttp.StatusOK {
+		t.Fatalf("unexpected basic auth result %d %v", request.StatusCode, err)
+	}
+}
@@ -0,0 +1,33 @@
+package test
+
+import (
+	"github.com/TelephoneTan/GoHTTPRequest/net/http"
+	"github.com/TelephoneTan/GoPromise/async/promise"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSharedDownloadBandwidth(t *testing.T) {
+	server := serve(strings.Repeat("x", 32*1024))
+	defer server.Close()
+	bandwidth := http.NewBandwidth(128*1024, func(b http.Bandwidth) {
+		b.Burst = 8 * 1024
+	})
+	start := time.Now()
+	var all []promise.Promise[http.Result[[]byte]]
+	for i := 0; i < 4; i++ {
+		all = append(all, http.NewRequest(func(request http.Request) {
+			request.URL = server.URL
+			request.DownloadBandwidth = bandwidth
+		}).ByteSlice())
+	}
+	for _, p := range all {
+		if res, err := await(p); err != nil || len(res.Result) != 32*1024 {
+			t.Fatalf("unexpected download %d %v", len(res.Result), err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 800*time.Millisecond || elapsed > 3*time.Second {
+		t.Fatalf("128KiB at 128KiB/s with an 8KiB burst took %v", elapsed)
+	}
+}
@@ -0,0 +1,94 @@
+package test
+
+import (
+	"bytes"
+	"errors"
+	"github.com/TelephoneTan/GoHTTPRequest/net/http"
+	"github.com/TelephoneTan/GoHTTPRequest/net/http/method"
+	"io"
+	gohttp "net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCassetteRecordReplay(t *testing.T) {
+	binary := bytes.Repeat([]byte{0, 1, 2, 0xff}, 4096)
+	hits := 0
+	server := httptest.NewServer(gohttp.HandlerFunc(func(w gohttp.ResponseWriter, r *gohttp.Request) {
+		hits++
+		if r.URL.Path == "/binary" {
+			_, _ = w.Write(binary)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"echo":` + string(body) + `,"token":"server-secret"}`))
+	}))
+	defer server.Close()
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	run := func(cassette http.Cassette) ([]byte, string, error) {
+		stream, err := await(http.NewRequest(func(request http.Request) {
+			request.URL = server.URL + "/binary"
+			request.Transport = cassette
+		}).Stream())
+		if err != nil {
+			return nil, "", err
+		}
+		bs, _ := io.ReadAll(stream.Result.Reader)
+		stream.Result.Done()
+		res, err := await(http.NewRequest(func(request http.Request) {
+			request.Method = method.POST
+			request.URL = server.URL + "/json"
+			request.RequestString = `{"password":"client-secret"}`
+			request.RequestContentTypeHeader = "application/json"
+			request.CustomizedHeaderList = [][]string{{"Authorization", "Bearer client-token"}}
+			request.Transport = cassette
+		}).String())
+		return bs, res.Result, err
+	}
+	recorder := http.NewCassette(path, http.CassetteRecord, func(cassette http.Cassette) {
+		cassette.MatchBody = true
+		cassette.RedactFields = []string{"password", "token"}
+	})
+	bs, s, err := run(recorder)
+	if err != nil || !bytes.Equal(bs, binary) || !strings.Contains(s, "server-secret") || hits != 2 {
+		t.Fatalf("unexpected recording %v %q %v", len(bs), s, err)
+	}
+	saved, _ := os.ReadFile(path)
+	for _, secret := range []string{"client-secret", "server-secret", "client-token"} {
+		if bytes.Contains(saved, []byte(secret)) {
+			t.Fatalf("%s leaked into cassette", secret)
+		}
+	}
+	replayer := http.NewCassette(path, http.CassetteReplay, func(cassette http.Cassette) {
+		cassette.MatchBody = true
+		cassette.RedactFields = []string{"password", "token"}
+	})
+	bs, s, err = run(replayer)
+	if err != nil || !bytes.Equal(bs, binary) || !strings.Contains(s, `"token":"[REDACTED]"`) || hits != 2 {
+		t.Fatalf("unexpected replay %v %q %v", len(bs), s, err)
+	}
+	_, err = await(http.NewRequest(func(request http.Request) {
+		request.URL = server.URL + "/unknown"
+		request.Transport = replayer
+	}).Send())
+	if !errors.Is(err, http.ErrCassetteMissing) {
+		t.Fatalf("expected missing interaction, got %v", err)
+	}
+	missing := http.NewCassette(path, http.CassetteRecordMissing)
+	if _, err = await(http.NewRequest(func(request http.Request) {
+		request.URL = server.URL + "/binary"
+		request.Transport = missing
+	}).ByteSlice()); err != nil || hits != 2 {
+		t.Fatalf("expected replay without hitting the server, got %v after %d hits", err, hits)
+	}
+	if _, err = await(http.NewRequest(func(request http.Request) {
+		request.URL = server.URL + "/unknown"
+		request.Transport = missing
+	}).ByteSlice()); err != nil || hits != 3 || len(missing.Interactions()) != 3 {
+		t.Fatalf("expected the missing interaction to be recorded, got %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+package test
+
+import (
+	"github.com/TelephoneTan/GoHTTPRequest/net/http"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"testing"
+)
+
+func mustEncode(t *testing.T, s string, encode func([]byte) ([]byte, error)) []byte {
+	b, err := encode([]byte(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestSniffCharset(t *testing.T) {
+	gbk := mustEncode(t, `<html><head><!-- <meta charset="big5"> --><meta charset="GBK"></head><body>中文网页</body></html>`, simplifiedchinese.GBK.NewEncoder().Bytes)
+	sjis := mustEncode(t, `<html><head><meta http-equiv="Content-Type" content="text/html; charset=Shift_JIS"></head><body>日本語のページ</body></html>`, japanese.ShiftJIS.NewEncoder().Bytes)
+	plain := mustEncode(t, "これはテキストです。ひらがなとカタカナ。", japanese.EUCJP.NewEncoder().Bytes)
+	cases := []struct {
+		name        string
+		content     []byte
+		contentType string
+		def         string
+		detector    http.CharsetDetector
+		want        http.DetectedCharset
+	}{
+		{"meta charset", gbk, "text/html", "utf-8", nil, http.DetectedCharset{Name: "gbk", Source: http.CharsetFromMeta}},
+		{"http-equiv", sjis, "", "", nil, http.DetectedCharset{Name: "shift_jis", Source: http.CharsetFromMeta}},
+		{"content before charset", []byte(`<meta http-equiv="content-type" content="text/html; charset=shift_jis" charset="gbk">`), "", "", nil, http.DetectedCharset{Name: "shift_jis", Source: http.CharsetFromMeta}},
+		{"header beats meta", gbk, "text/html; charset=utf-8", "", nil, http.DetectedCharset{Name: "utf-8", Source: http.CharsetFromHeader}},
+		{"bom beats header", append([]byte{0xEF, 0xBB, 0xBF}, gbk...), "text/html; charset=gbk", "", nil, http.DetectedCharset{Name: "utf-8", Source: http.CharsetFromBOM}},
+		{"meta ignored outside html", gbk, "text/plain", "", nil, http.DetectedCharset{Name: "windows-1252", Source: http.CharsetFromDefault}},
+		{"detector", plain, "text/plain", "utf-8", http.NewHeuristicCharsetDetector(), http.DetectedCharset{Name: "euc-jp", Source: http.CharsetFromDetector}},
+		{"default", []byte("ascii"), "text/plain", "latin1", nil, http.DetectedCharset{Name: "windows-1252", Source: http.CharsetFromDefault}},
+	}
+	for _, c := range cases {
+		if got := http.SniffCharset(c.content, c.contentType, c.def, c.detector); got != c.want {
+			t.Errorf("%s: got %+v, want %+v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestStringUsesSniffedCharset(t *testing.T) {
+	page := `<html><head><meta charset="shift_jis"><title>日本語</title></head><body>こんにちは</body></html>`
+	mock := http.NewMockTransport()
+	mock.On(http.MatchURL("/page")).Respond(http.MockResponse{
+		StatusCode: 200,
+		Header:     map[string][]string{"Content-Type": {"text/html"}},
+		Body:       mustEncode(t, page, japanese.ShiftJIS.NewEncoder().Bytes),
+	})
+	request := http.NewRequest(func(request http.Request) {
+		request.URL = "https://example.com/page"
+		request.Transport = mock
+	})
+	str, err := await(request.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if str.Result != page {
+		t.Errorf("mojibake: %q", str.Result)
+	}
+	if str.Charset == nil || *str.Charset != (http.DetectedCharset{Name: "shift_jis", Source: http.CharsetFromMeta}) {
+		t.Errorf("charset: %+v", str.Charset)
+	}
+	doc, err := await(request.HTMLSelection())
+	if err != nil {
+		t.Fatal(err)
+	}
+	title, _ := doc.Result.QuerySelector("title")
+	if title.Text() != "日本語" || doc.Result == nil || doc.Charset == nil || doc.Charset.Name != "shift_jis" {
+		t.Errorf("document: %q %+v", title.Text(), doc.Charset)
+	}
+}
@@ -0,0 +1,237 @@
+package test
+
+import (
+	"errors"
+	"github.com/TelephoneTan/GoHTTPRequest/net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRobots(t *testing.T) {
+	robots := http.ParseRobots(`# comment
+User-agent: *
+Disallow: /private
+Allow: /private/public
+Disallow: /*.pdf$
+Crawl-delay: 2.5
+
+User-agent: Special-Bot
+User-agent: other
+Disallow: /
+
+Sitemap: https://example.com/sitemap.xml
+`)
+	cases := []struct {
+		agent, path string
+		allowed     bool
+	}{
+		{"GoHTTPRequest-Crawler/1.0", "/", true},
+		{"GoHTTPRequest-Crawler/1.0", "/private/x", false},
+		{"GoHTTPRequest-Crawler/1.0", "/private/public/x", true},
+		{"GoHTTPRequest-Crawler/1.0", "/doc.pdf", false},
+		{"GoHTTPRequest-Crawler/1.0", "/doc.pdf?download=1", true},
+		{"GoHTTPRequest-Crawler/1.0", "/%70rivate", false},
+		{"special-bot/2.0", "/anything", false},
+		{"special-bot/2.0", "/robots.txt", true},
+	}
+	for _, c := range cases {
+		if got := robots.Allowed(c.agent, c.path); got != c.allowed {
+			t.Errorf("%s %s: got %v", c.agent, c.path, got)
+		}
+	}
+	if delay, ok := robots.CrawlDelay("GoHTTPRequest-Crawler"); !ok || delay != 2500*time.Millisecond {
+		t.Errorf("crawl delay: %v %v", delay, ok)
+	}
+	if !reflect.DeepEqual(robots.Sitemaps, []string{"https://example.com/sitemap.xml"}) {
+		t.Errorf("sitemaps: %v", robots.Sitemaps)
+	}
+}
+
+func crawlSite() http.MockTransport {
+	mock := http.NewMockTransport()
+	mock.On(http.MatchURL("https://example.com/robots.txt")).Respond(http.MockText(200, "User-agent: *\nDisallow: /private\nSitemap: https://example.com/sitemap.xml\n"))
+	mock.On(http.MatchURL("https://example.com/sitemap.xml")).Respond(http.MockResponse{StatusCode: 200, Body: []byte(`<?xml version="1.0"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9"><url><loc>https://example.com/listed</loc></url></urlset>`)})
+	mock.On(http.MatchURL("https://example.com/")).Respond(http.MockHTML(200, `<a href="/a">a</a> <a href="a#top">again</a> <a href="/private/x">private</a>
+<a href="/hidden" rel="nofollow">hidden</a> <a href="https://other.org/">other</a> <a href="mailto:x@example.com">mail</a>`))
+	mock.On(http.MatchURL("https://example.com/a")).Respond(http.MockHTML(200, `<a href="/deep">deep</a>`))
+	mock.On(http.MatchURL("https://example.com/listed")).Respond(http.MockText(200, "plain"))
+	mock.On(http.MatchURL("https://example.com/deep")).Respond(http.MockHTML(200, `<a href="/">home</a>`))
+	return mock
+}
+
+func newTestCrawler(mock http.MockTransport, visited *[]string, lock *sync.Mutex, init ...func(http.Crawler)) http.Crawler {
+	return http.NewCrawler(func(crawler http.Crawler) {
+		crawler.Delay = new(http.Duration)
+		crawler.Concurrency = &[]int{1}[0]
+		crawler.Prepare = func(request http.Request) {
+			request.Transport = mock
+		}
+		crawler.Handlers = []http.CrawlHandler{http.CrawlHandlerFunc(func(page http.CrawlPage) error {
+			lock.Lock()
+			defer lock.Unlock()
+			*visited = append(*visited, page.Task.URL)
+			return nil
+		})}
+		for _, f := range init {
+			f(crawler)
+		}
+	})
+}
+
+func TestCrawler(t *testing.T) {
+	mock := crawlSite()
+	var visited []string
+	var disallowed []string
+	lock := &sync.Mutex{}
+	crawler := newTestCrawler(mock, &visited, lock, func(crawler http.Crawler) {
+		crawler.MaxDepth = &[]int{1}[0]
+		crawler.OnError = func(task http.CrawlTask, err error) {
+			if errors.Is(err, http.ErrRobotsDisallowed) {
+				disallowed = append(disallowed, task.URL)
+			} else {
+				t.Errorf("%s: %v", task.URL, err)
+			}
+		}
+	})
+	if err := crawler.Add("https://EXAMPLE.com:443/./"); err != nil {
+		t.Fatal(err)
+	}
+	if err := crawler.Run(); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(visited)
+	if want := []string{"https://example.com/", "https://example.com/a", "https://example.com/listed"}; !reflect.DeepEqual(visited, want) {
+		t.Errorf("visited %v, want %v", visited, want)
+	}
+	if !reflect.DeepEqual(disallowed, []string{"https://example.com/private/x"}) {
+		t.Errorf("disallowed %v", disallowed)
+	}
+	for _, request := range mock.Unmatched() {
+		t.Errorf("unexpected request %s", request.URL)
+	}
+}
+
+func TestCrawlerPauseResume(t *testing.T) {
+	mock := crawlSite()
+	state := filepath.Join(t.TempDir(), "frontier.json")
+	var visited []string
+	lock := &sync.Mutex{}
+	var first http.Crawler
+	first = newTestCrawler(mock, &visited, lock, func(crawler http.Crawler) {
+		crawler.StateFile = state
+		crawler.Handlers = append(crawler.Handlers, http.CrawlHandlerFunc(func(page http.CrawlPage) error {
+			first.Pause()
+			return nil
+		}))
+	})
+	if err := first.Add("https://example.com/"); err != nil {
+		t.Fatal(err)
+	}
+	if err := first.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if len(visited) != 1 || first.Len() == 0 {
+		t.Fatalf("pause did not stop the crawl: %v, %d queued", visited, first.Len())
+	}
+	second := newTestCrawler(mock, &visited, lock, func(crawler http.Crawler) {
+		crawler.StateFile = state
+	})
+	if err := second.Run(); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(visited)
+	if want := []string{"https://example.com/", "https://example.com/a", "https://example.com/deep", "https://example.com/listed"}; !reflect.DeepEqual(visited, want) {
+		t.Errorf("visited %v, want %v", visited, want)
+	}
+	if second.Len() != 0 || second.Pages() != 4 {
+		t.Errorf("frontier %d, pages %d", second.Len(), second.Pages())
+	}
+}
+
+func TestCrawlerRobotsFailuresAndOptionalSitemap(t *testing.T) {
+	mock := http.NewMockTransport()
+	robots := mock.On(http.MatchURL("https://example.com/robots.txt")).Respond(
+		http.MockText(503, "busy"),
+		http.MockText(200, "User-agent: *\n#"+strings.Repeat("x", 600<<10)+"\nDisallow: /\n"),
+	)
+	mock.On(http.MatchURL("https://example.com/")).Respond(http.MockHTML(200, `<a href="/a">a</a>`))
+	mock.On(http.MatchURL("https://example.com/a")).Respond(http.MockHTML(200, `a`))
+	state := filepath.Join(t.TempDir(), "frontier.json")
+	var visited []string
+	lock := &sync.Mutex{}
+	crawler := newTestCrawler(mock, &visited, lock, func(crawler http.Crawler) {
+		crawler.StateFile = state
+		crawler.RobotsRetry = new(http.Duration)
+		crawler.SaveInterval = new(http.Duration)
+		crawler.OnError = func(task http.CrawlTask, err error) {
+			t.Errorf("%s: %v", task.URL, err)
+		}
+		crawler.Handlers = append(crawler.Handlers, http.CrawlHandlerFunc(func(page http.CrawlPage) error {
+			if page.Task.URL != "https://example.com/a" {
+				return nil
+			}
+			bs, err := os.ReadFile(state)
+			if err != nil {
+				return err
+			}
+			if !strings.Contains(string(bs), `"URL": "https://example.com/a"`) {
+				t.Errorf("state was not saved while running:\n%s", bs)
+			}
+			return nil
+		}))
+	})
+	if err := crawler.Add("https://example.com/"); err != nil {
+		t.Fatal(err)
+	}
+	if err := crawler.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"https://example.com/", "https://example.com/a"}; !reflect.DeepEqual(visited, want) {
+		t.Errorf("visited %v, want %v", visited, want)
+	}
+	if robots.Calls() != 2 {
+		t.Errorf("robots.txt fetched %d times, want a retry after the 503", robots.Calls())
+	}
+}
+
+func TestCrawlerOnErrorCanCallCrawler(t *testing.T) {
+	mock := http.NewMockTransport()
+	mock.On(http.MatchURL("https://example.com/robots.txt")).Respond(http.MockText(200, "Sitemap: https://example.com/sitemap.xml\n"))
+	mock.On(http.MatchURL("https://example.com/sitemap.xml")).Respond(http.MockResponse{StatusCode: 200, Body: []byte(`<?xml version="1.0"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9"><url><loc>https://example.com/%zz</loc></url></urlset>`)})
+	mock.On(http.MatchURL("https://example.com/")).Respond(http.MockHTML(200, `home`))
+	var visited []string
+	lock := &sync.Mutex{}
+	var crawler http.Crawler
+	var reported []int
+	crawler = newTestCrawler(mock, &visited, lock, func(c http.Crawler) {
+		c.OnError = func(task http.CrawlTask, err error) {
+			reported = append(reported, crawler.Len())
+		}
+	})
+	if err := crawler.Add("https://example.com/"); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- crawler.Run()
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnError deadlocked the crawler")
+	}
+	if len(reported) != 1 {
+		t.Errorf("reported %v", reported)
+	}
+}
@@ -0,0 +1,124 @@
+package test
+
+import (
+	"github.com/TelephoneTan/GoHTTPRequest/net"
+	"github.com/TelephoneTan/GoHTTPRequest/net/http"
+	"github.com/TelephoneTan/GoHTTPRequest/net/http/method"
+	"io"
+	gohttp "net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCurlRoundTrip(t *testing.T) {
+	var received []string
+	lock := &sync.Mutex{}
+	server := httptest.NewServer(gohttp.HandlerFunc(func(w gohttp.ResponseWriter, r *gohttp.Request) {
+		body, _ := io.ReadAll(r.Body)
+		user, pass, _ := r.BasicAuth()
+		lock.Lock()
+		defer lock.Unlock()
+		received = append(received, strings.Join([]string{r.Method, r.URL.RequestURI(), r.Header.Get("Content-Type"), r.Header.Get("X-Quote"), r.Header.Get("Cookie"), user + ":" + pass, string(body)}, "|"))
+	}))
+	defer server.Close()
+	timeout := http.Duration(5 * time.Second)
+	original := http.NewRequest(func(request http.Request) {
+		request.Method = method.PUT
+		request.URL = server.URL + "/items?q=a b"
+		request.RequestBinary = []byte{'i', 't', '\'', 's', 0, '\n', 0xff}
+		request.RequestContentTypeHeader = "application/x-custom"
+		request.CustomizedHeaderList = [][]string{{"X-Quote", `it's "quoted"`}, {"Cookie", "a=1"}}
+		request.Authenticator = &http.BasicAuth{Username: "user", Password: "p@ss"}
+		request.Timeout = &timeout
+	})
+	command, err := original.Curl()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(command, "-X PUT") || !strings.Contains(command, "--max-time 5") || !strings.Contains(command, "-L") || !strings.HasPrefix(command, `printf 'it\047s\000\012\377' | curl`) || !strings.Contains(command, "--data-binary @-") {
+		t.Fatalf("unexpected command %s", command)
+	}
+	parsed, err := http.ParseCurl(command)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, request := range []http.Request{original, parsed} {
+		if _, err := await(request.Send()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lock.Lock()
+	if len(received) != 2 || received[0] != received[1] {
+		t.Fatalf("parsed command differs:\n%q", received)
+	}
+	lock.Unlock()
+	if _, err := exec.LookPath("curl"); err != nil {
+		t.Skip("curl not installed")
+	}
+	shell := exec.Command("bash", "-c", command)
+	shell.Env = append(os.Environ(), "NO_PROXY=*")
+	if output, err := shell.CombinedOutput(); err != nil {
+		t.Fatalf("%v: %s", err, output)
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if len(received) != 3 || received[2] != received[0] {
+		t.Fatalf("shell command differs:\n%q", received)
+	}
+}
+
+func TestParseCurl(t *testing.T) {
+	request, err := http.ParseCurl(`curl 'https://example.com/api?x=1' \
+  -H 'accept: application/json' \
+  -H "X-Token: \"abc\"" \
+  -b 'session=1; theme=dark' \
+  --data-raw '{"a":1}' \
+  -x socks5://127.0.0.1:1080 -sSLk --compressed --connect-timeout 1.5`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *request.Method != *method.POST || request.URL != "https://example.com/api?x=1" || request.RequestString != `{"a":1}` {
+		t.Fatalf("unexpected request %+v", request)
+	}
+	if len(request.CustomizedHeaderList) != 3 || request.CustomizedHeaderList[1][1] != `"abc"` || request.CustomizedHeaderList[2][1] != "session=1; theme=dark" {
+		t.Fatalf("unexpected headers %q", request.CustomizedHeaderList)
+	}
+	if request.Proxy == nil || request.Proxy.Type != net.SOCKS || *request.Proxy.Port != 1080 || !*request.FollowRedirect || !*request.InsecureSkipVerify {
+		t.Fatalf("unexpected options %+v", request)
+	}
+	if time.Duration(*request.ConnectTimeout) != 1500*time.Millisecond || request.RequestContentTypeHeader != "application/x-www-form-urlencoded" {
+		t.Fatalf("unexpected timeout or content type %v %s", *request.ConnectTimeout, request.RequestContentTypeHeader)
+	}
+	request, err = http.ParseCurl(`curl -F name=go -F 'note=<-' -G -d q=1 example.com`)
+	if err == nil {
+		t.Fatal("expected error reading form file")
+	}
+	request, err = http.ParseCurl(`curl -G -d q=1 -d r=2 example.com/search`)
+	if err != nil || *request.Method != *method.GET || request.URL != "http://example.com/search?q=1&r=2" {
+		t.Fatalf("unexpected get request %+v %v", request, err)
+	}
+	if command, err := request.Curl(); err != nil || !strings.Contains(command, "'http://example.com/search?q=1&r=2'") {
+		t.Fatalf("unexpected get command %s %v", command, err)
+	}
+	request.RequestString = "body"
+	if command, err := request.Curl(); err != nil || !strings.Contains(command, "curl -X GET ") {
+		t.Fatalf("unexpected get command with body %s %v", command, err)
+	}
+	upload := filepath.Join(t.TempDir(), "upload.txt")
+	if err := os.WriteFile(upload, []byte("uploaded"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	request, err = http.ParseCurl("curl -T " + upload + " example.com/upload")
+	if err != nil || *request.Method != *method.PUT || string(request.RequestBinary) != "uploaded" || request.RequestFile != nil {
+		t.Fatalf("unexpected upload request %+v %v", request, err)
+	}
+	if _, err = http.ParseCurl(`curl 'unterminated`); err == nil {
+		t.Fatal("expected syntax error")
+	}
+}
@@ -0,0 +1,86 @@
+package test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/TelephoneTan/GoHTTPRequest/net/http"
+	gohttp "net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDownloadResumeAndSegments(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789abcdef"), 64*1024)
+	sum := sha256.Sum256(content)
+	var requests atomic.Int32
+	server := httptest.NewServer(gohttp.HandlerFunc(func(w gohttp.ResponseWriter, r *gohttp.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		if requests.Add(1) == 1 {
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			_, _ = w.Write(content[:len(content)/3])
+			w.(gohttp.Flusher).Flush()
+			panic(gohttp.ErrAbortHandler)
+		}
+		gohttp.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+	path := filepath.Join(t.TempDir(), "artifact.bin")
+	attempts := 1
+	_, err := await(http.NewDownload(http.NewRequest(func(request http.Request) {
+		request.URL = server.URL
+	}), path, func(download http.Download) {
+		download.MaxAttempts = &attempts
+	}).Start())
+	if err == nil {
+		t.Fatal("expected interrupted download to fail")
+	}
+	var last int64
+	download, err := await(http.NewDownload(http.NewRequest(func(request http.Request) {
+		request.URL = server.URL
+	}), path, func(download http.Download) {
+		download.SHA256 = hex.EncodeToString(sum[:])
+		download.OnProgress = func(p http.Progress) {
+			last = p.Transferred
+		}
+	}).Start())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !download.Resumed || last != int64(len(content)) {
+		t.Fatalf("expected resumed download, got resumed=%v progress=%d", download.Resumed, last)
+	}
+	if bs, _ := os.ReadFile(path); !bytes.Equal(bs, content) {
+		t.Fatal("downloaded content mismatch")
+	}
+	segments := 4
+	minSegmentSize := int64(64 * 1024)
+	segmented := filepath.Join(t.TempDir(), "segmented.bin")
+	_, err = await(http.NewDownload(http.NewRequest(func(request http.Request) {
+		request.URL = server.URL
+	}), segmented, func(download http.Download) {
+		download.Segments = &segments
+		download.MinSegmentSize = &minSegmentSize
+		download.SHA256 = hex.EncodeToString(sum[:])
+	}).Start())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bs, _ := os.ReadFile(segmented); !bytes.Equal(bs, content) {
+		t.Fatal("segmented content mismatch")
+	}
+	_, err = await(http.NewDownload(http.NewRequest(func(request http.Request) {
+		request.URL = server.URL
+	}), filepath.Join(t.TempDir(), "bad.bin"), func(download http.Download) {
+		download.MD5 = "00000000000000000000000000000000"
+	}).Start())
+	if !errors.Is(err, http.ErrDownloadChecksum) {
+		t.Fatalf("expected checksum failure, got %v", err)
+	}
+}
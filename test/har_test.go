@@ -0,0 +1,155 @@
+package test
+
+import (
+	"bytes"
+	"github.com/TelephoneTan/GoHTTPRequest/net/http"
+	"github.com/TelephoneTan/GoHTTPRequest/net/http/method"
+	"io"
+	gohttp "net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestHARExportImport(t *testing.T) {
+	var received [][]string
+	server := httptest.NewServer(gohttp.HandlerFunc(func(w gohttp.ResponseWriter, r *gohttp.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = append(received, []string{r.Method, r.URL.RawQuery, r.Header.Get("Cookie"), r.Header.Get("Content-Type"), string(body)})
+		gohttp.SetCookie(w, &gohttp.Cookie{Name: "session", Value: "abc", Path: "/", HttpOnly: true})
+		w.Header().Set("Content-Type", "application/octet-stream")
+		_, _ = w.Write([]byte{0xff, 0xfe, 0x00})
+	}))
+	defer server.Close()
+	request := http.NewRequest(func(request http.Request) {
+		request.Method = method.POST
+		request.URL = server.URL + "/submit?a=1&b=x y"
+		request.RequestForm = [][]string{{"name", "go"}, {"lang", "中文"}}
+		request.CustomizedHeaderList = [][]string{{"Cookie", "theme=dark"}}
+	})
+	if _, err := await(request.ByteSlice()); err != nil {
+		t.Fatal(err)
+	}
+	exported, err := http.ExportHAR(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buffer := &bytes.Buffer{}
+	if err := exported.Write(buffer); err != nil {
+		t.Fatal(err)
+	}
+	har, err := http.ParseHAR(buffer)
+	if err != nil || har.Log.Version != "1.2" || len(har.Log.Entries) != 1 {
+		t.Fatalf("unexpected har %+v %v", har, err)
+	}
+	entry := har.Log.Entries[0]
+	if entry.Request.Method != "POST" || len(entry.Request.QueryString) != 2 || entry.Request.QueryString[1].Value != "x y" {
+		t.Fatalf("unexpected request %+v", entry.Request)
+	}
+	if entry.Request.PostData == nil || len(entry.Request.PostData.Params) != 2 || entry.Request.PostData.Params[1].Value != "中文" {
+		t.Fatalf("unexpected post data %+v", entry.Request.PostData)
+	}
+	if len(entry.Request.Cookies) != 1 || entry.Request.Cookies[0].Name != "theme" {
+		t.Fatalf("unexpected request cookies %+v", entry.Request.Cookies)
+	}
+	if entry.Response.Status != 200 || entry.Response.StatusText != "OK" || entry.Response.Content.Encoding != "base64" || entry.Response.Content.Text != "//4A" {
+		t.Fatalf("unexpected response %+v", entry.Response)
+	}
+	if len(entry.Response.Cookies) != 1 || !entry.Response.Cookies[0].HTTPOnly || entry.StartedDateTime == "" || entry.Time <= 0 || entry.ServerIPAddress != "127.0.0.1" {
+		t.Fatalf("unexpected entry %+v", entry)
+	}
+	replayed := har.Requests()[0]
+	if _, err = await(replayed.Send()); err != nil {
+		t.Fatal(err)
+	}
+	if len(received) != 2 || received[1][0] != received[0][0] || received[1][1] != received[0][1] || received[1][2] != "theme=dark" || received[1][3] != received[0][3] || received[1][4] != received[0][4] {
+		t.Fatalf("replayed request differs: %q", received)
+	}
+}
+
+func TestHARRedirectsAndSentHeaders(t *testing.T) {
+	var queries []string
+	lock := &sync.Mutex{}
+	server := httptest.NewServer(gohttp.HandlerFunc(func(w gohttp.ResponseWriter, r *gohttp.Request) {
+		lock.Lock()
+		queries = append(queries, r.URL.RawQuery)
+		lock.Unlock()
+		if r.URL.Path == "/start" {
+			gohttp.Redirect(w, r, "/next?q=a+b", gohttp.StatusFound)
+			return
+		}
+		_, _ = w.Write([]byte("done"))
+	}))
+	defer server.Close()
+	request := http.NewRequest(func(request http.Request) {
+		request.Method = method.POST
+		request.URL = server.URL + "/start"
+		request.RequestString = "payload"
+		request.CustomizedHeaderList = [][]string{{"User-Agent", "har-test/1"}}
+		request.Authenticator = &http.BearerAuth{Token: "tok"}
+		request.CookieJar = http.NewCookieJar("har-redirect")
+		request.SetCookies = [][]string{{server.URL + "/", "sid=1; Path=/"}}
+	})
+	if _, err := await(request.String()); err != nil {
+		t.Fatal(err)
+	}
+	exported, err := http.ExportHAR(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := exported.Log.Entries
+	if len(entries) != 2 {
+		t.Fatalf("expected one entry per hop, got %d", len(entries))
+	}
+	first, last := entries[0], entries[1]
+	if first.Request.Method != "POST" || first.Request.PostData == nil || first.Response.Status != 302 || first.Response.RedirectURL != "/next?q=a+b" {
+		t.Fatalf("unexpected redirect entry %+v", first)
+	}
+	if last.Request.Method != "GET" || last.Request.PostData != nil || last.Request.URL != server.URL+"/next?q=a+b" || last.Response.Content.Text != "done" {
+		t.Fatalf("unexpected final entry %+v", last)
+	}
+	for _, entry := range entries {
+		sent := map[string]string{}
+		for _, h := range entry.Request.Headers {
+			sent[h.Name] = h.Value
+		}
+		if sent["Authorization"] != "Bearer tok" || sent["Cookie"] != "sid=1" || sent["User-Agent"] != "har-test/1" {
+			t.Errorf("%s: sent headers not recorded: %v", entry.Request.URL, sent)
+		}
+	}
+	imported := last.ToRequest()
+	if got := harURL(t, imported); got != last.Request.URL {
+		t.Fatalf("imported URL %s, want %s", got, last.Request.URL)
+	}
+	if _, err := await(imported.Send()); err != nil {
+		t.Fatal(err)
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if want := []string{"", "q=a+b", "q=a+b"}; !reflect.DeepEqual(queries, want) {
+		t.Fatalf("server saw queries %q, want %q", queries, want)
+	}
+}
+
+func TestHARBinaryPostData(t *testing.T) {
+	body := []byte{0xff, 0xfe, 0}
+	entry, err := http.NewRequest(func(request http.Request) {
+		request.Method = method.POST
+		request.URL = "https://example.com/upload"
+		request.RequestBinary = body
+	}).HAREntry()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if postData := entry.Request.PostData; postData == nil || postData.Encoding != "base64" || postData.Text != "//4A" || postData.Comment != "" {
+		t.Fatalf("unexpected post data %+v", entry.Request.PostData)
+	}
+	if imported := entry.ToRequest(); !bytes.Equal(imported.RequestBinary, body) {
+		t.Fatalf("unexpected imported body %v", imported.RequestBinary)
+	}
+	entry.Request.PostData.Encoding, entry.Request.PostData.Comment = "", "base64"
+	if imported := entry.ToRequest(); !bytes.Equal(imported.RequestBinary, body) {
+		t.Fatalf("comment fallback not imported %v", imported.RequestBinary)
+	}
+}
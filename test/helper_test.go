@@ -0,0 +1,52 @@
+package test
+
+import (
+	"errors"
+	"github.com/TelephoneTan/GoHTTPRequest/net/http"
+	"github.com/TelephoneTan/GoPromise/async/promise"
+	gohttp "net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func await[T any](p promise.Promise[T]) (value T, err error) {
+	promise.Catch[any](promise.Then(p, promise.FulfilledListener[T, any]{
+		OnFulfilled: func(v T) any {
+			value = v
+			return nil
+		},
+	}), promise.RejectedListener[any]{
+		OnRejected: func(reason error) any {
+			err = reason
+			if err == nil {
+				err = errors.New("rejected")
+			}
+			return nil
+		},
+	}).Await()
+	return value, err
+}
+
+func serve(body string) *httptest.Server {
+	return httptest.NewServer(gohttp.HandlerFunc(func(w gohttp.ResponseWriter, r *gohttp.Request) {
+		_, _ = w.Write([]byte(body))
+	}))
+}
+
+func mustParse(s string) *url.URL {
+	u, err := url.Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
+
+func harURL(t *testing.T, request http.Request) string {
+	t.Helper()
+	entry, err := request.HAREntry()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entry.Request.URL
+}
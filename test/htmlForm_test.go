@@ -0,0 +1,182 @@
+package test
+
+import (
+	"errors"
+	"github.com/TelephoneTan/GoHTTPRequest/net/http"
+	"io"
+	stdhttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const loginPage = `<html><body>
+<form id="search" action="/search?old=1"><input name="q" value="go"><input type="submit" value="Go"></form>
+<form id="login" action="session" method="post">
+  <input type="hidden" name="csrf" value="token-123">
+  <input name="username">
+  <input type="password" name="password">
+  <input type="checkbox" name="remember" checked>
+  <input type="checkbox" name="newsletter" value="yes">
+  <input type="radio" name="plan" value="free" checked><input type="radio" name="plan" value="pro">
+  <select name="lang"><option>en</option><option value="fr" selected>French</option></select>
+  <select name="tags" multiple><option selected>a</option><option>b</option><option selected>c</option></select>
+  <textarea name="note">hello
+world</textarea>
+  <input name="ignored" disabled value="x">
+  <fieldset disabled><input name="locked" value="y"></fieldset>
+  <input type="file" name="avatar">
+  <button name="action" value="login">Log in</button>
+  <button name="action" value="signup" formaction="/signup">Sign up</button>
+</form>
+<input form="login" name="outside" value="z">
+</body></html>`
+
+func TestHTMLFormDiscovery(t *testing.T) {
+	doc, err := http.ParseHTMLSelection(loginPage, "https://example.com/account/login#top")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if forms := doc.Forms(); len(forms) != 2 || forms[0].ID != "search" {
+		t.Fatalf("unexpected forms %+v", forms)
+	}
+	form, err := doc.Form("#login")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if form.Method != "POST" || form.Action != "https://example.com/account/session" || form.Enctype != http.FormURLEncoded {
+		t.Fatalf("unexpected form %+v", form)
+	}
+	var values []string
+	for _, kv := range form.Values() {
+		values = append(values, kv[0]+"="+kv[1])
+	}
+	if got := strings.Join(values, "&"); got != "csrf=token-123&username=&password=&remember=on&plan=free&lang=fr&tags=a&tags=c&note=hello\nworld&outside=z" {
+		t.Fatalf("unexpected values %q", got)
+	}
+	form.Set("username", "alice").Set("password", "p&ss").Set("plan", "pro").Del("tags")
+	if _, err = form.Click("action"); err != nil {
+		t.Fatal(err)
+	}
+	request, err := form.Request()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if request.URL != "https://example.com/account/session" || string(*request.Method) != "POST" {
+		t.Fatalf("unexpected request %s %s", *request.Method, request.URL)
+	}
+	var form2 []string
+	for _, kv := range request.RequestForm {
+		form2 = append(form2, kv[0]+"="+kv[1])
+	}
+	if got := strings.Join(form2, "&"); !strings.HasSuffix(got, "&outside=z&action=login") || !strings.Contains(got, "password=p&ss&remember=on&plan=pro") {
+		t.Fatalf("unexpected form body %q", got)
+	}
+	if referer := request.CustomizedHeaderList[0]; referer[0] != "Referer" || referer[1] != "https://example.com/account/login" {
+		t.Fatalf("unexpected referer %v", referer)
+	}
+	signup, _ := doc.Form("#login")
+	if _, err = signup.Click("action"); err != nil || signup.Action != "https://example.com/account/session" {
+		t.Fatalf("first submitter should be picked: %v %s", err, signup.Action)
+	}
+	if _, err = signup.Click("missing"); !errors.Is(err, http.ErrFormSubmitter) {
+		t.Fatalf("expected submitter error, got %v", err)
+	}
+	search, _ := doc.Form("form#search")
+	request, _ = search.Set("q", "a b").Request()
+	if got := harURL(t, request); got != "https://example.com/search?q=a%20b" {
+		t.Fatalf("unexpected search URL %s", got)
+	}
+	upload, _ := doc.Form("#login")
+	request, _ = upload.SetFile("avatar", "me.png", []byte("PNG")).Request()
+	if !strings.HasPrefix(request.RequestContentTypeHeader, "multipart/form-data; boundary=") || !strings.Contains(string(request.RequestBinary), `filename="me.png"`) {
+		t.Fatalf("unexpected multipart body %s", request.RequestBinary)
+	}
+	if _, err = doc.Form("#nope"); !errors.Is(err, http.ErrFormNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
+
+func TestHTMLFormSubmissionKeepsSession(t *testing.T) {
+	server := httptest.NewServer(stdhttp.HandlerFunc(func(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+		switch r.URL.Path {
+		case "/login":
+			stdhttp.SetCookie(w, &stdhttp.Cookie{Name: "session", Value: "s1", Path: "/"})
+			_, _ = io.WriteString(w, `<form method="post" action="/session"><input type="hidden" name="csrf" value="t1"><input name="user"></form>`)
+		case "/session":
+			cookie, err := r.Cookie("session")
+			if err != nil || cookie.Value != "s1" || r.FormValue("csrf") != "t1" || r.FormValue("user") != "bob" || r.Referer() == "" {
+				w.WriteHeader(stdhttp.StatusForbidden)
+				return
+			}
+			_, _ = io.WriteString(w, "welcome bob")
+		}
+	}))
+	defer server.Close()
+	tag := "form-session"
+	page, err := await(http.NewRequest(func(request http.Request) {
+		request.URL = server.URL + "/login"
+		request.CookieJarTag = &tag
+	}).HTMLSelection())
+	if err != nil {
+		t.Fatal(err)
+	}
+	form, err := page.Result.Form("form")
+	if err != nil {
+		t.Fatal(err)
+	}
+	request, err := form.Set("user", "bob").Request()
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := await(request.String())
+	if err != nil || res.Result != "welcome bob" {
+		t.Fatalf("login failed: %d %q %v", request.StatusCode, res.Result, err)
+	}
+}
+
+func TestHTMLFormCharset(t *testing.T) {
+	mock := http.NewMockTransport()
+	mock.On(http.MatchURL("https://example.com/gbk")).Respond(http.MockResponse{
+		StatusCode: 200,
+		Header:     map[string][]string{"Content-Type": {"text/html; charset=gbk"}},
+		Body: []byte(`<form action="/search"><input name="q"></form>
+<form id="jp" method="post" action="/post" accept-charset="x-unknown shift_jis"><input name="q"></form>`),
+	})
+	page, err := await(http.NewRequest(func(request http.Request) {
+		request.URL = "https://example.com/gbk"
+		request.Transport = mock
+	}).HTMLSelection())
+	if err != nil {
+		t.Fatal(err)
+	}
+	search, err := page.Result.Form("form")
+	if err != nil {
+		t.Fatal(err)
+	}
+	timeout := http.Duration(time.Second)
+	request, err := search.Set("q", "中文").Request(func(request http.Request) {
+		request.CustomizedHeaderList = append(request.CustomizedHeaderList, []string{"X-First", "1"})
+		request.Timeout = &timeout
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := harURL(t, request); got != "https://example.com/search?q=%D6%D0%CE%C4" {
+		t.Fatalf("unexpected GBK query %s", got)
+	}
+	if request.Timeout != &timeout || request.CustomizedHeaderList[len(request.CustomizedHeaderList)-1][0] != "X-First" {
+		t.Fatal("init was not applied")
+	}
+	jp, err := page.Result.Form("#jp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if request, err = jp.Set("q", "日本€").Request(); err != nil {
+		t.Fatal(err)
+	}
+	if got := request.RequestForm[0][1]; got != "\x93\xfa\x96\x7b&#8364;" {
+		t.Fatalf("unexpected Shift_JIS value %q", got)
+	}
+}
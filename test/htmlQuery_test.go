@@ -0,0 +1,123 @@
+package test
+
+import (
+	"errors"
+	"github.com/TelephoneTan/GoHTTPRequest/net/http"
+	"strings"
+	"testing"
+)
+
+const queryPage = `<!doctype html>
+<html lang="en-US"><head><title> Catalog </title><base href="/shop/"></head>
+<body>
+<div id="main" class="content wide">
+  <h1>Items <small>(3)</small></h1>
+  <ul class="items">
+    <li class="item" data-id="1"><a href="one.html">One</a></li>
+    <li class="item sale" data-id="2"><a href="../two.html" rel="next nofollow">Two</a></li>
+    <li class="item" data-id="3"><a href="https://cdn.example.org/three">Three</a><img src="//img.example.org/3.png"></li>
+  </ul>
+  <p>First   paragraph
+     spans lines.</p><p></p>
+  <script>var ignored = 1;</script>
+</div>
+<input type="checkbox" name="agree" checked>
+</body></html>`
+
+func TestHTMLSelection(t *testing.T) {
+	doc, err := http.ParseHTMLSelection(queryPage, "https://example.com/catalog/index.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := func(selector string) int {
+		s, err := doc.QuerySelectorAll(selector)
+		if err != nil {
+			t.Fatalf("%s: %v", selector, err)
+		}
+		return s.Len()
+	}
+	cases := map[string]int{
+		"li":                        3,
+		"ul.items > li.item":        3,
+		"#main li":                  3,
+		"div > li":                  0,
+		"li.sale + li":              1,
+		"li:first-child ~ li":       2,
+		"li:nth-child(odd)":         2,
+		"li:nth-child(2n+1)":        2,
+		"li:nth-last-child(1)":      1,
+		"li:not(.sale)":             2,
+		"li:has(img)":               1,
+		"[data-id]":                 3,
+		"[data-id='2']":             1,
+		"a[rel~=nofollow]":          1,
+		"html[lang|=en]":            1,
+		"a[href^=https]":            1,
+		"a[href$='.html']":          2,
+		"a[href*=TWO i]":            1,
+		"p:empty":                   1,
+		"input:checked":             1,
+		"h1, li.sale, #missing":     2,
+		"body *:first-of-type":      12,
+		":root":                     1,
+		"div.content.wide p + p":    1,
+		"li:nth-of-type(3) > img":   1,
+		"ul li:last-child a[href]":  1,
+		"*":                         20,
+		"li:nth-child(-n+2)":        2,
+		"  ul   >   li  ":           3,
+		"div#main.content > h1 > *": 1,
+	}
+	for selector, want := range cases {
+		if got := count(selector); got != want {
+			t.Errorf("%q matched %d, want %d", selector, got, want)
+		}
+	}
+	for _, selector := range []string{"", "li >", "[data-id", "li:nope", "a[href=]", "li:nth-child(x)", "li,", "#"} {
+		if _, err := doc.QuerySelectorAll(selector); !errors.Is(err, http.ErrSelectorSyntax) {
+			t.Errorf("%q: expected syntax error, got %v", selector, err)
+		}
+	}
+	main, _ := doc.QuerySelector("#main")
+	if text := main.Text(); !strings.HasPrefix(text, "Items (3) One Two Three First paragraph spans lines.") || strings.Contains(text, "ignored") {
+		t.Errorf("unexpected text %q", text)
+	}
+	links, _ := doc.QuerySelectorAll("a")
+	if got := strings.Join(links.URLs("href"), " "); got != "https://example.com/shop/one.html https://example.com/two.html https://cdn.example.org/three" {
+		t.Errorf("unexpected links %s", got)
+	}
+	if got := strings.Join(links.Texts(), ","); got != "One,Two,Three" {
+		t.Errorf("unexpected texts %s", got)
+	}
+	img, _ := doc.QuerySelector("img")
+	if src, ok := img.URL("src"); !ok || src != "https://img.example.org/3.png" {
+		t.Errorf("unexpected src %s", src)
+	}
+	if rel, ok := links.Eq(1).Attr("rel"); !ok || rel != "next nofollow" {
+		t.Errorf("unexpected rel %s", rel)
+	}
+	if _, ok := links.Eq(5).Attr("href"); ok {
+		t.Error("out of range selection has attributes")
+	}
+	sale := links.Filter(http.MustCompileSelector("li.sale > a"))
+	if sale.Len() != 1 || sale.HTML() != `<a href="../two.html" rel="next nofollow">Two</a>` {
+		t.Errorf("unexpected filter result %s", sale.HTML())
+	}
+}
+
+func TestRequestHTMLSelection(t *testing.T) {
+	mock := http.NewMockTransport()
+	mock.On(http.MatchURL("/start")).Respond(http.MockResponse{StatusCode: 302, Header: map[string][]string{"Location": {"/docs/page"}}})
+	mock.On(http.MatchURL("/docs/page")).Respond(http.MockHTML(200, `<a href="next">next</a>`))
+	res, err := await(http.NewRequest(func(request http.Request) {
+		request.URL = "https://example.com/start"
+		request.Transport = mock
+	}).HTMLSelection())
+	if err != nil {
+		t.Fatal(err)
+	}
+	link, _ := res.Result.QuerySelector("a")
+	if href, _ := link.URL("href"); href != "https://example.com/docs/next" {
+		t.Errorf("link not resolved against final URL: %s", href)
+	}
+}
@@ -0,0 +1,91 @@
+package test
+
+import (
+	"errors"
+	"github.com/TelephoneTan/GoHTTPRequest/net/http"
+	"testing"
+)
+
+type record struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestJSONStreamNDJSON(t *testing.T) {
+	server := serve("{\"id\":1,\"name\":\"a\"}\n\n{\"id\":\"bad\"}\r\n{\"id\":3,\"name\":\"c\"}")
+	defer server.Close()
+	var lines []int
+	var malformed []int
+	res, err := await(http.JSONStream[record](http.NewRequest(func(request http.Request) {
+		request.URL = server.URL
+	}), func(r http.JSONRecord[record]) bool {
+		lines = append(lines, r.Line)
+		return true
+	}, func(d http.JSONStreamDecoder[record]) {
+		d.SkipMalformed = true
+		d.OnMalformed = func(e *http.JSONRecordError) {
+			malformed = append(malformed, e.Line)
+		}
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Result != 2 || len(lines) != 2 || lines[0] != 1 || lines[1] != 4 {
+		t.Fatalf("unexpected records %d %v", res.Result, lines)
+	}
+	if len(malformed) != 1 || malformed[0] != 3 {
+		t.Fatalf("unexpected malformed %v", malformed)
+	}
+	_, err = await(http.JSONStreamAll[record](http.NewRequest(func(request http.Request) {
+		request.URL = server.URL
+	})))
+	var recordErr *http.JSONRecordError
+	if !errors.As(err, &recordErr) || recordErr.Line != 3 {
+		t.Fatalf("expected failure at line 3, got %v", err)
+	}
+}
+
+func TestJSONStreamSeqAndConcatenated(t *testing.T) {
+	seq := serve("\x1e{\"id\":1}\n\x1e{\"id\":2}\n\x1e12")
+	defer seq.Close()
+	res, err := await(http.JSONStreamAll[record](http.NewRequest(func(request http.Request) {
+		request.URL = seq.URL
+	}), func(d http.JSONStreamDecoder[record]) {
+		d.Format = http.JSONSeq
+		d.SkipMalformed = true
+	}))
+	if err != nil || len(res.Result) != 2 || res.Result[1].ID != 2 {
+		t.Fatalf("unexpected json-seq result %v %v", res.Result, err)
+	}
+	concatenated := serve("{\"id\":1}{\"id\":2}\n[1]\n  {\"id\":4}")
+	defer concatenated.Close()
+	var lines []int
+	_, err = await(http.JSONStream[record](http.NewRequest(func(request http.Request) {
+		request.URL = concatenated.URL
+	}), func(r http.JSONRecord[record]) bool {
+		lines = append(lines, r.Line)
+		return true
+	}, func(d http.JSONStreamDecoder[record]) {
+		d.Format = http.ConcatenatedJSON
+		d.SkipMalformed = true
+	}))
+	if err != nil || len(lines) != 3 || lines[2] != 3 {
+		t.Fatalf("unexpected concatenated result %v %v", lines, err)
+	}
+	broken := serve("{\"id\":1}\n{\"id\":}")
+	defer broken.Close()
+	var reported []*http.JSONRecordError
+	_, err = await(http.JSONStreamAll[record](http.NewRequest(func(request http.Request) {
+		request.URL = broken.URL
+	}), func(d http.JSONStreamDecoder[record]) {
+		d.Format = http.ConcatenatedJSON
+		d.SkipMalformed = true
+		d.OnMalformed = func(e *http.JSONRecordError) {
+			reported = append(reported, e)
+		}
+	}))
+	var recordErr *http.JSONRecordError
+	if !errors.As(err, &recordErr) || len(reported) != 1 || reported[0] != recordErr || recordErr.Line != 2 {
+		t.Fatalf("unexpected concatenated syntax error %v %v", reported, err)
+	}
+}
@@ -0,0 +1,104 @@
+package test
+
+import (
+	"bytes"
+	"github.com/TelephoneTan/GoHTTPRequest/net/http"
+	"github.com/TelephoneTan/GoHTTPRequest/net/http/method"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestLoggingRedaction(t *testing.T) {
+	server := serve(`{"token":"secret-token","name":"x"}`)
+	defer server.Close()
+	buffer := &bytes.Buffer{}
+	request := http.NewRequest(func(request http.Request) {
+		request.Method = method.POST
+		request.URL = server.URL + "/path?api_key=secret-key&q=1"
+		request.RequestForm = [][]string{{"password", "secret-password"}, {"user", "u"}}
+		request.CustomizedHeaderList = [][]string{{"Cookie", "session=secret-cookie"}}
+		request.Authenticator = &http.BearerAuth{Token: "secret-bearer"}
+		request.Logger = http.NewRequestLogger(slog.New(slog.NewJSONHandler(buffer, nil)), func(logger http.RequestLogger) {
+			logger.Verbosity = http.LogBodies
+			logger.RedactFields = []string{"api_key", "password", "token"}
+		})
+	})
+	if _, err := await(request.String()); err != nil {
+		t.Fatal(err)
+	}
+	logs := buffer.String()
+	for _, secret := range []string{"secret-token", "secret-key", "secret-password", "secret-cookie", "secret-bearer"} {
+		if strings.Contains(logs, secret) {
+			t.Fatalf("%s leaked into logs:\n%s", secret, logs)
+		}
+	}
+	for _, event := range []string{"http request start", "http response", "http request finish", `"attempt":1`, `"status":200`, `\"name\":\"x\"`} {
+		if !strings.Contains(logs, event) {
+			t.Fatalf("%s missing from logs:\n%s", event, logs)
+		}
+	}
+}
+
+func TestLoggingCountsDrainedBytes(t *testing.T) {
+	server := serve(strings.Repeat("x", 4096))
+	defer server.Close()
+	buffer := &bytes.Buffer{}
+	request := http.NewRequest(func(request http.Request) {
+		request.URL = server.URL
+		request.Logger = http.NewRequestLogger(slog.New(slog.NewJSONHandler(buffer, nil)))
+	})
+	res, err := await(request.Stream())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := res.Result.Reader.Read(make([]byte, 16)); err != nil {
+		t.Fatal(err)
+	}
+	res.Result.Done()
+	if logs := buffer.String(); !strings.Contains(logs, `"response_size":4096`) {
+		t.Fatalf("drained bytes missing from response_size:\n%s", logs)
+	}
+}
+
+func TestLoggingBodiesKeepsUploadAccounting(t *testing.T) {
+	server := serve("ok")
+	defer server.Close()
+	body := strings.Repeat("x", 1024)
+	observer := &bytesOutObserver{}
+	buffer := &bytes.Buffer{}
+	var uploads []http.Progress
+	_, err := await(http.NewRequest(func(request http.Request) {
+		request.Method = method.POST
+		request.URL = server.URL
+		request.RequestString = body
+		request.Metrics = observer
+		request.OnUploadProgress = func(p http.Progress) {
+			uploads = append(uploads, p)
+		}
+		request.Logger = http.NewRequestLogger(slog.New(slog.NewJSONHandler(buffer, nil)), func(logger http.RequestLogger) {
+			logger.Verbosity = http.LogBodies
+		})
+	}).String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buffer.String(), `"body":"`+body[:16]) {
+		t.Fatalf("request body missing from logs:\n%s", buffer.String())
+	}
+	if n := observer.bytesOut.Load(); n != int64(len(body)) {
+		t.Fatalf("unexpected bytes out %d", n)
+	}
+	done := 0
+	for _, p := range uploads {
+		if p.Transferred > int64(len(body)) {
+			t.Fatalf("upload progress overshoot %+v", p)
+		}
+		if p.Done {
+			done++
+		}
+	}
+	if done != 1 {
+		t.Fatalf("upload progress finished %d times", done)
+	}
+}
@@ -0,0 +1,69 @@
+package test
+
+import (
+	"bytes"
+	"github.com/TelephoneTan/GoHTTPRequest/net/http"
+	"github.com/TelephoneTan/GoHTTPRequest/net/http/method"
+	gohttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	server := httptest.NewServer(gohttp.HandlerFunc(func(w gohttp.ResponseWriter, r *gohttp.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(gohttp.StatusNotFound)
+		}
+		_, _ = w.Write([]byte("hello"))
+	}))
+	defer server.Close()
+	metrics := http.NewMetrics()
+	for _, path := range []string{"/", "/", "/missing"} {
+		request := http.NewRequest(func(request http.Request) {
+			request.Method = method.POST
+			request.URL = server.URL + path
+			request.RequestString = "abc"
+			request.Metrics = metrics
+		})
+		if _, err := await(request.ByteSlice()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	failed := http.NewRequest(func(request http.Request) {
+		request.URL = "http://127.0.0.1:1"
+		request.Metrics = metrics
+	})
+	if _, err := await(failed.Send()); err == nil {
+		t.Fatal("expected connection failure")
+	}
+	snapshot := metrics.Snapshot()
+	if len(snapshot.InFlight) != 0 || len(snapshot.Series) != 3 {
+		t.Fatalf("unexpected snapshot %+v", snapshot)
+	}
+	host := strings.TrimPrefix(server.URL, "http://")
+	refused, ok, missing := snapshot.Series[0], snapshot.Series[1], snapshot.Series[2]
+	if ok.Host != host || ok.StatusCode != 200 || ok.Requests != 2 || ok.BytesIn != 10 || ok.BytesOut != 6 || ok.ErrorClass != "" {
+		t.Fatalf("unexpected series %+v", ok)
+	}
+	if missing.StatusCode != 404 || missing.ErrorClass != http.ErrorClassClient {
+		t.Fatalf("unexpected series %+v", missing)
+	}
+	if refused.Host != "127.0.0.1:1" || refused.ErrorClass != http.ErrorClassConnection {
+		t.Fatalf("unexpected series %+v", refused)
+	}
+	buffer := &bytes.Buffer{}
+	if err := snapshot.WritePrometheus(buffer); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		`http_client_requests_total{host="` + host + `",method="POST",status="200",error_class=""} 2`,
+		`http_client_request_duration_seconds_bucket{host="` + host + `",method="POST",status="200",error_class="",le="+Inf"} 2`,
+		`http_client_response_bytes_total{host="` + host + `",method="POST",status="404",error_class="client_error"} 5`,
+		"# TYPE http_client_requests_in_flight gauge",
+	} {
+		if !strings.Contains(buffer.String(), line) {
+			t.Fatalf("%s missing from exposition:\n%s", line, buffer.String())
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package test
+
+import (
+	"errors"
+	"github.com/TelephoneTan/GoHTTPRequest/net/http"
+	"github.com/TelephoneTan/GoHTTPRequest/net/http/method"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestMockTransport(t *testing.T) {
+	mock := http.NewMockTransport(func(mock http.MockTransport) {
+		mock.FailOnUnmatched = true
+	})
+	users := mock.On(http.MatchMethod(method.POST), http.MatchURL("https://api.example.com/users/*"), http.MatchHeader("X-Token", "t"), http.MatchJSON(map[string]any{"name": "a"})).
+		Respond(http.MockJSON(201, map[string]any{"id": 1})).
+		Times(1)
+	page := mock.On(http.MatchURL("/page"), http.MatchQuery("q", "go")).
+		Respond(http.MockHTML(200, "<title>first</title>"), http.MockHTML(200, "<title>second</title>"))
+	mock.On(http.MatchURL("/slow")).Respond(http.MockResponse{StatusCode: 200, Delay: http.Duration(time.Second)})
+	mock.On(http.MatchURL("/broken")).Respond(http.MockError(io.ErrUnexpectedEOF))
+	newRequest := func(init func(request http.Request)) http.Request {
+		return http.NewRequest(func(request http.Request) {
+			request.Transport = mock
+			init(request)
+		})
+	}
+	res, err := await(newRequest(func(request http.Request) {
+		request.Method = method.POST
+		request.URL = "https://api.example.com/users/new"
+		request.CustomizedHeaderList = [][]string{{"X-Token", "t"}}
+		request.RequestString = `{"name":"a"}`
+	}).Json())
+	if err != nil || res.Request.StatusCode != 201 || res.Result.(map[string]any)["id"] != float64(1) {
+		t.Fatalf("unexpected json result %v %v", res.Result, err)
+	}
+	for _, want := range []string{"first", "second", "second"} {
+		doc, err := await(newRequest(func(request http.Request) {
+			request.URL = "https://example.com/page?q=go"
+		}).HTMLDocument())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if title := doc.Result.FirstChild.FirstChild.FirstChild.FirstChild.Data; title != want {
+			t.Fatalf("got title %q, want %q", title, want)
+		}
+	}
+	stream, err := await(newRequest(func(request http.Request) {
+		request.URL = "https://example.com/page?q=go"
+	}).Stream())
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs, _ := io.ReadAll(stream.Result.Reader)
+	stream.Result.Done()
+	if string(bs) != "<title>second</title>" || page.Calls() != 4 {
+		t.Fatalf("unexpected stream %q after %d calls", bs, page.Calls())
+	}
+	timeout := http.Duration(50 * time.Millisecond)
+	if _, err = await(newRequest(func(request http.Request) {
+		request.URL = "https://example.com/slow"
+		request.Timeout = &timeout
+	}).Send()); err == nil {
+		t.Fatal("expected timeout")
+	}
+	if _, err = await(newRequest(func(request http.Request) {
+		request.URL = "https://example.com/broken"
+	}).Send()); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected mocked error, got %v", err)
+	}
+	if err = mock.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = await(newRequest(func(request http.Request) {
+		request.URL = "https://example.com/other"
+	}).Send()); !errors.Is(err, http.ErrMockUnmatched) {
+		t.Fatalf("expected unmatched error, got %v", err)
+	}
+	if err = mock.Verify(); err == nil || users.Calls() != 1 {
+		t.Fatal("expected verification to report the unmatched request")
+	}
+}
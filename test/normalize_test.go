@@ -0,0 +1,77 @@
+package test
+
+import (
+	"errors"
+	"github.com/TelephoneTan/GoHTTPRequest/net/http"
+	"testing"
+)
+
+func TestNormalizeURL(t *testing.T) {
+	yes := true
+	profile := func(p http.IDNAProfile) *http.IDNAProfile {
+		return &p
+	}
+	full := &http.URLNormalization{
+		IDNAProfile:              profile(http.UTS46NonTransitional),
+		LowercaseHost:            &yes,
+		NormalizePercentEncoding: &yes,
+		RemoveDotSegments:        &yes,
+		StripDefaultPort:         &yes,
+		RemoveFragment:           &yes,
+	}
+	cases := []struct {
+		policy *http.URLNormalization
+		in     string
+		want   string
+	}{
+		{nil, "http://例え．テスト/a?b=2&a=1", "http://xn--r8jz45g.xn--zckzah/a?a=1&b=2"},
+		{nil, "http://[::1]:8080/x", "http://[::1]:8080/x"},
+		{nil, "https://example.com:443/a/../b#frag", "https://example.com:443/a/../b#frag"},
+		{full, "https://Bücher.EXAMPLE:443/a/./b/../c/%7euser/%2f%e2%82%ac#frag", "https://xn--bcher-kva.example/a/c/~user/%2F%E2%82%AC"},
+		{full, "http://example.com:8080/../x", "http://example.com:8080/x"},
+		{full, "http://faß.de/", "http://xn--fa-hia.de/"},
+		{&http.URLNormalization{IDNAProfile: profile(http.UTS46Transitional)}, "http://faß.de/", "http://fass.de/"},
+		{&http.URLNormalization{}, "http://under_score.example/", "http://under_score.example/"},
+	}
+	for _, c := range cases {
+		if got, err := http.NormalizeURL(c.in, c.policy); err != nil || got != c.want {
+			t.Errorf("%s: got %q %v, want %q", c.in, got, err, c.want)
+		}
+	}
+	failures := []struct {
+		policy *http.URLNormalization
+		in     string
+	}{
+		{&http.URLNormalization{StrictIDNA: &yes}, "http://under_score.example/"},
+		{&http.URLNormalization{IDNAProfile: profile(http.IDNA2008)}, "http://BÜCHER.example/"},
+		{nil, "http://exa mple.com/"},
+	}
+	for _, c := range failures {
+		if got, err := http.NormalizeURL(c.in, c.policy); err == nil {
+			t.Errorf("%s: expected error, got %q", c.in, got)
+		}
+	}
+	request := http.NewRequest(func(request http.Request) {
+		request.URL = "https://Bücher.EXAMPLE:443/a/./b/../c/%7euser/%2f%e2%82%ac#frag"
+		request.URLNormalization = full
+	})
+	if got := harURL(t, request); got != cases[3].want {
+		t.Errorf("request URL %q differs from NormalizeURL", got)
+	}
+	invalid := http.NewRequest(func(request http.Request) {
+		request.URL = "http://under_score.example/"
+		request.URLNormalization = &http.URLNormalization{StrictIDNA: &yes}
+	})
+	if _, err := invalid.HAREntry(); !errors.Is(err, http.ErrInvalidHost) {
+		t.Errorf("HAREntry: %v", err)
+	}
+	if _, err := invalid.Curl(); !errors.Is(err, http.ErrInvalidHost) {
+		t.Errorf("Curl: %v", err)
+	}
+	if _, err := invalid.Serialize(); !errors.Is(err, http.ErrInvalidHost) {
+		t.Errorf("Serialize: %v", err)
+	}
+	if _, err := await(invalid.Send()); !errors.Is(err, http.ErrInvalidHost) {
+		t.Errorf("Send: %v", err)
+	}
+}
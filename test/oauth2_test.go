@@ -0,0 +1,74 @@
+package test
+
+import (
+	"encoding/json"
+	"github.com/TelephoneTan/GoHTTPRequest/net/http"
+	"github.com/TelephoneTan/GoPromise/async/promise"
+	gohttp "net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestOAuth2ClientCredentials(t *testing.T) {
+	var issued atomic.Int32
+	tokenServer := httptest.NewServer(gohttp.HandlerFunc(func(w gohttp.ResponseWriter, r *gohttp.Request) {
+		id, secret, _ := r.BasicAuth()
+		if id != "client" || secret != "s3cret" {
+			w.WriteHeader(gohttp.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":"invalid_client"}`))
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+		n := issued.Add(1)
+		if r.PostFormValue("grant_type") == "refresh_token" && r.PostFormValue("refresh_token") != "r"+strconv.Itoa(int(n-1)) {
+			w.WriteHeader(gohttp.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token":  "t" + strconv.Itoa(int(n)),
+			"refresh_token": "r" + strconv.Itoa(int(n)),
+			"token_type":    "bearer",
+			"expires_in":    3600,
+		})
+	}))
+	defer tokenServer.Close()
+	var revoked atomic.Bool
+	apiServer := httptest.NewServer(gohttp.HandlerFunc(func(w gohttp.ResponseWriter, r *gohttp.Request) {
+		if auth := r.Header.Get("Authorization"); auth == "Bearer t1" && revoked.Load() || auth == "" {
+			w.WriteHeader(gohttp.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+	defer apiServer.Close()
+	oauth2 := http.NewOAuth2(tokenServer.URL, http.ClientCredentialsGrant, func(o http.OAuth2) {
+		o.ClientID = "client"
+		o.ClientSecret = "s3cret"
+	})
+	get := func() promise.Promise[http.Result[string]] {
+		return http.NewRequest(func(request http.Request) {
+			request.URL = apiServer.URL
+			request.Authenticator = oauth2
+		}).String()
+	}
+	var all []promise.Promise[http.Result[string]]
+	for i := 0; i < 10; i++ {
+		all = append(all, get())
+	}
+	for _, p := range all {
+		if res, err := await(p); err != nil || res.Result != "Bearer t1" {
+			t.Fatalf("unexpected response %q %v", res.Result, err)
+		}
+	}
+	if issued.Load() != 1 {
+		t.Fatalf("expected a single token request, got %d", issued.Load())
+	}
+	revoked.Store(true)
+	if res, err := await(get()); err != nil || res.Result != "Bearer t2" {
+		t.Fatalf("expected retry with refreshed token, got %q %v", res.Result, err)
+	}
+}
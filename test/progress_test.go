@@ -0,0 +1,47 @@
+package test
+
+import (
+	"github.com/TelephoneTan/GoHTTPRequest/net/http"
+	"github.com/TelephoneTan/GoHTTPRequest/net/http/method"
+	"io"
+	gohttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestProgress(t *testing.T) {
+	server := httptest.NewServer(gohttp.HandlerFunc(func(w gohttp.ResponseWriter, r *gohttp.Request) {
+		bs, _ := io.ReadAll(r.Body)
+		_, _ = w.Write(bs)
+	}))
+	defer server.Close()
+	body := strings.Repeat("x", 256*1024)
+	var upload, download []http.Progress
+	_, err := await(http.NewRequest(func(request http.Request) {
+		request.Method = method.POST
+		request.URL = server.URL
+		request.RequestString = body
+		request.OnUploadProgress = func(p http.Progress) {
+			upload = append(upload, p)
+		}
+		request.OnDownloadProgress = func(p http.Progress) {
+			download = append(download, p)
+		}
+	}).ByteSlice())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, events := range map[string][]http.Progress{"upload": upload, "download": download} {
+		if len(events) == 0 {
+			t.Fatalf("no %s progress", name)
+		}
+		last := events[len(events)-1]
+		if !last.Done || last.Transferred != int64(len(body)) || last.ETA != 0 {
+			t.Fatalf("unexpected final %s progress %+v", name, last)
+		}
+	}
+	if upload[0].Total != int64(len(body)) {
+		t.Fatalf("unexpected upload total %d", upload[0].Total)
+	}
+}
@@ -0,0 +1,87 @@
+package test
+
+import (
+	"errors"
+	"github.com/TelephoneTan/GoHTTPRequest/net/http"
+	"testing"
+)
+
+func TestExpandURITemplate(t *testing.T) {
+	variables := map[string]any{
+		"var":   "value",
+		"hello": "Hello World!",
+		"path":  "/foo/bar",
+		"list":  []string{"red", "green", "blue"},
+		"keys":  [][]string{{"semi", ";"}, {"dot", "."}, {"comma", ","}},
+		"x":     1024,
+		"y":     768,
+		"empty": "",
+	}
+	cases := map[string]string{
+		"{var}":             "value",
+		"{hello}":           "Hello%20World%21",
+		"{+path}/here":      "/foo/bar/here",
+		"{#path,x}/here":    "#/foo/bar,1024/here",
+		"{var:3}":           "val",
+		"{list}":            "red,green,blue",
+		"{list*}":           "red,green,blue",
+		"{keys}":            "semi,%3B,dot,.,comma,%2C",
+		"{keys*}":           "semi=%3B,dot=.,comma=%2C",
+		"X{.list*}":         "X.red.green.blue",
+		"{/list*,path:4}":   "/red/green/blue/%2Ffoo",
+		"{;x,y,empty}":      ";x=1024;y=768;empty",
+		"{?x,y,empty}":      "?x=1024&y=768&empty=",
+		"{?list*}":          "?list=red&list=green&list=blue",
+		"?fixed=yes{&x}":    "?fixed=yes&x=1024",
+		"{?undefined,x}":    "?x=1024",
+		"/users/{var}/repo": "/users/value/repo",
+	}
+	for template, want := range cases {
+		if got, err := http.ExpandURITemplate(template, variables); err != nil || got != want {
+			t.Errorf("%s: got %q %v, want %q", template, got, err, want)
+		}
+	}
+	for _, template := range []string{"{var", "var}", "{}", "{=var}", "{list:2}", "{bad-name}"} {
+		if _, err := http.ExpandURITemplate(template, variables); !errors.Is(err, http.ErrURITemplate) {
+			t.Errorf("%s: expected template error, got %v", template, err)
+		}
+	}
+}
+
+func TestStructuredQuery(t *testing.T) {
+	styles := map[http.QueryArrayStyle]string{
+		http.QueryArrayRepeat:   "https://example.com/items/a%20b?z=1&a=x&id=3&id=4&tag=go&q=c%2Bd&flag",
+		http.QueryArrayBrackets: "https://example.com/items/a%20b?z=1&a=x&id[]=3&id[]=4&tag[]=go&q=c%2Bd&flag",
+		http.QueryArrayIndex:    "https://example.com/items/a%20b?z=1&a=x&id[0]=3&id[1]=4&tag[0]=go&q=c%2Bd&flag",
+		http.QueryArrayComma:    "https://example.com/items/a%20b?z=1&a=x&id=3,4&tag=go&q=c%2Bd&flag",
+	}
+	for style, want := range styles {
+		style := style
+		request := http.NewRequest(func(request http.Request) {
+			request.URL = "https://example.com/items/{name}?z=1&a=x"
+			request.URLVariables = map[string]any{"name": "a b"}
+			request.QueryList = [][]string{{"id", "3", "4"}, {"tag[]", "go"}, {"q", "c+d"}, {"flag"}}
+			request.QueryArrayStyle = &style
+			request.PreserveRawQuery = new(bool)
+			*request.PreserveRawQuery = true
+		})
+		if got := harURL(t, request); got != want {
+			t.Errorf("%s: got %s, want %s", style, got, want)
+		}
+	}
+	raw := "https://example.com/sign?b=2&a=x+y&sig=abc%2Fdef%3d"
+	preserve := true
+	request := http.NewRequest(func(request http.Request) {
+		request.URL = raw
+		request.PreserveRawQuery = &preserve
+	})
+	if got := harURL(t, request); got != raw {
+		t.Errorf("raw query not preserved: %s", got)
+	}
+	request = http.NewRequest(func(request http.Request) {
+		request.URL = raw
+	})
+	if got := harURL(t, request); got != "https://example.com/sign?a=x%2By&b=2&sig=abc%2Fdef%3D" {
+		t.Errorf("default encoding changed: %s", got)
+	}
+}
@@ -0,0 +1,110 @@
+package test
+
+import (
+	"errors"
+	"github.com/TelephoneTan/GoHTTPRequest/net/http"
+	"github.com/TelephoneTan/GoHTTPRequest/net/http/method"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestQueueDelivery(t *testing.T) {
+	dir := t.TempDir()
+	mock := http.NewMockTransport()
+	flaky := mock.On(http.MatchURL("/flaky")).Respond(http.MockText(503, "down"), http.MockText(200, "ok"))
+	rejected := mock.On(http.MatchURL("/rejected")).Respond(http.MockText(400, "bad"))
+	accepted := mock.On(http.MatchURL("/accepted")).Respond(http.MockText(201, "created"))
+	online := atomic.Bool{}
+	zero := http.Duration(0)
+	newQueue := func() http.Queue {
+		return http.NewQueue(dir, func(queue http.Queue) {
+			queue.MinBackoff = &zero
+			queue.Online = online.Load
+			queue.Prepare = func(request http.Request) {
+				request.Transport = mock
+			}
+		})
+	}
+	queue := newQueue()
+	enqueue := func(path string, priority int) http.QueueItem {
+		item, err := queue.Enqueue(http.NewRequest(func(request http.Request) {
+			request.Method = method.POST
+			request.URL = "https://example.com" + path
+			request.RequestString = path
+		}), func(item http.QueueItem) {
+			item.Priority = priority
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return item
+	}
+	flakyItem := enqueue("/flaky", 1)
+	rejectedItem := enqueue("/rejected", 0)
+	acceptedItem := enqueue("/accepted", 5)
+	if delivered, err := queue.Flush(); err != nil || delivered != 0 {
+		t.Fatalf("offline flush delivered %d %v", delivered, err)
+	}
+	queue = newQueue()
+	if queue.Len() != 3 {
+		t.Fatalf("expected 3 persisted items, got %d", queue.Len())
+	}
+	online.Store(true)
+	if delivered, err := queue.Flush(); err != nil || delivered != 1 {
+		t.Fatalf("first flush delivered %d %v", delivered, err)
+	}
+	if accepted.Calls() != 1 || flaky.Calls() != 1 || rejected.Calls() != 1 {
+		t.Fatal("unexpected call counts")
+	}
+	if accepted.Requests()[0].Header.Get("Idempotency-Key") != acceptedItem.IdempotencyKey {
+		t.Fatal("missing idempotency key")
+	}
+	if item, _ := queue.Item(flakyItem.ID); item.Status != http.QueuePending || item.Attempts != 1 || item.LastStatusCode != 503 {
+		t.Fatalf("unexpected flaky item %+v", item)
+	}
+	if dead := queue.DeadLetters(); len(dead) != 1 || dead[0].ID != rejectedItem.ID {
+		t.Fatalf("unexpected dead letters %+v", dead)
+	}
+	if delivered, err := queue.Flush(); err != nil || delivered != 1 {
+		t.Fatalf("second flush delivered %d %v", delivered, err)
+	}
+	keys := flaky.Requests()
+	if keys[0].Header.Get("Idempotency-Key") != keys[1].Header.Get("Idempotency-Key") {
+		t.Fatal("idempotency key changed between attempts")
+	}
+	if _, err := queue.Item(flakyItem.ID); !errors.Is(err, http.ErrQueueItemNotFound) {
+		t.Fatalf("delivered item still queued: %v", err)
+	}
+	if items := queue.Items(); len(items) != 1 || items[0].ID != rejectedItem.ID {
+		t.Fatalf("unexpected items %+v", items)
+	}
+	if err := queue.Requeue(rejectedItem.ID); err != nil || queue.Len() != 1 {
+		t.Fatalf("requeue failed: %v", err)
+	}
+	if queue = newQueue(); queue.Len() != 1 || len(queue.Items()) != 1 {
+		t.Fatalf("expected only the requeued item on disk, got %d", queue.Len())
+	}
+}
+
+func TestQueueExpiry(t *testing.T) {
+	queue := http.NewQueue(t.TempDir())
+	item, err := queue.Enqueue(http.NewRequest(func(request http.Request) {
+		request.URL = "https://example.com/"
+	}), func(item http.QueueItem) {
+		expiresAt := time.Now().Add(-time.Second)
+		item.ExpiresAt = &expiresAt
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delivered, err := queue.Flush(); err != nil || delivered != 0 {
+		t.Fatalf("expired item delivered %d %v", delivered, err)
+	}
+	if item, _ = queue.Item(item.ID); item.Status != http.QueueDead || item.LastError != http.ErrQueueExpired.Error() {
+		t.Fatalf("unexpected item %+v", item)
+	}
+	if _, err = queue.Item("missing"); !errors.Is(err, http.ErrQueueItemNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
@@ -0,0 +1,62 @@
+package test
+
+import (
+	"errors"
+	"github.com/TelephoneTan/GoHTTPRequest/net/http"
+	gohttp "net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(gohttp.HandlerFunc(func(w gohttp.ResponseWriter, r *gohttp.Request) {
+		if hits.Add(1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(gohttp.StatusTooManyRequests)
+		}
+	}))
+	defer server.Close()
+	limiter := http.NewRateLimiter(10)
+	send := func() (http.Request, error) {
+		request := http.NewRequest(func(request http.Request) {
+			request.URL = server.URL
+			request.RateLimiter = limiter
+		})
+		_, err := await(request.Send())
+		return request, err
+	}
+	if request, err := send(); err != nil || request.StatusCode != gohttp.StatusTooManyRequests {
+		t.Fatalf("unexpected first response %v", err)
+	}
+	limiter.FailFast = true
+	if _, err := send(); !errors.Is(err, http.ErrRateLimited) {
+		t.Fatalf("expected fail-fast rate limit error, got %v", err)
+	}
+	limiter.FailFast = false
+	start := time.Now()
+	if request, err := send(); err != nil || request.StatusCode != gohttp.StatusOK {
+		t.Fatalf("unexpected response after waiting %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 800*time.Millisecond {
+		t.Fatalf("Retry-After was not honoured, waited %v", elapsed)
+	}
+	request := http.NewRequest(func(request http.Request) {
+		request.URL = server.URL
+		request.RateLimiter = limiter
+	})
+	limiter.Observe(limiter.KeyOf(mustParse(server.URL)), gohttp.StatusTooManyRequests, gohttp.Header{"Retry-After": {"5"}})
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		request.Cancel()
+	}()
+	start = time.Now()
+	if _, err := await(request.Send()); err == nil {
+		t.Fatal("expected cancelled wait to fail")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("Cancel did not stop the wait, waited %v", elapsed)
+	}
+}
@@ -0,0 +1,123 @@
+package test
+
+import (
+	"errors"
+	"github.com/TelephoneTan/GoHTTPRequest/net/http"
+	"github.com/TelephoneTan/GoHTTPRequest/net/http/method"
+	"io"
+	stdhttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func redirectServer(t *testing.T, tls bool) (*httptest.Server, *[]string) {
+	var seen []string
+	handler := stdhttp.HandlerFunc(func(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+		body, _ := io.ReadAll(r.Body)
+		seen = append(seen, r.Method+" "+r.URL.Path+" "+string(body)+" "+r.Header.Get("Authorization"))
+		switch r.URL.Path {
+		case "/307":
+			stdhttp.Redirect(w, r, "/303", stdhttp.StatusTemporaryRedirect)
+		case "/303":
+			stdhttp.Redirect(w, r, "/done", stdhttp.StatusSeeOther)
+		case "/302":
+			stdhttp.Redirect(w, r, "/done", stdhttp.StatusFound)
+		case "/away":
+			stdhttp.Redirect(w, r, r.URL.Query().Get("to"), stdhttp.StatusFound)
+		default:
+			_, _ = io.WriteString(w, "done")
+		}
+	})
+	var server *httptest.Server
+	if tls {
+		server = httptest.NewTLSServer(handler)
+	} else {
+		server = httptest.NewServer(handler)
+	}
+	t.Cleanup(server.Close)
+	return server, &seen
+}
+
+func TestRedirectHistoryAndMethods(t *testing.T) {
+	server, seen := redirectServer(t, false)
+	request := http.NewRequest(func(request http.Request) {
+		request.Method = method.POST
+		request.URL = server.URL + "/307"
+		request.RequestString = "payload"
+	})
+	if _, err := await(request.String()); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(*seen, "|") != "POST /307 payload |POST /303 payload |GET /done  " {
+		t.Fatalf("unexpected hops %q", *seen)
+	}
+	if request.FinalURL != server.URL+"/done" || len(request.RedirectHistory) != 2 {
+		t.Fatalf("unexpected history %+v %s", request.RedirectHistory, request.FinalURL)
+	}
+	hop := request.RedirectHistory[0]
+	if hop.URL != server.URL+"/307" || hop.Method != "POST" || hop.StatusCode != 307 {
+		t.Fatalf("unexpected first hop %+v", hop)
+	}
+	*seen = nil
+	request = http.NewRequest(func(request http.Request) {
+		request.Method = method.POST
+		request.URL = server.URL + "/302"
+		request.RequestString = "kept"
+		request.Redirect = &http.RedirectPolicy{MethodRewrite: map[int]method.Method{302: ""}}
+	})
+	if _, err := await(request.String()); err != nil {
+		t.Fatal(err)
+	}
+	if (*seen)[1] != "POST /done kept " {
+		t.Fatalf("method not preserved: %q", *seen)
+	}
+	one := 1
+	request = http.NewRequest(func(request http.Request) {
+		request.URL = server.URL + "/307"
+		request.Redirect = &http.RedirectPolicy{MaxRedirects: &one}
+	})
+	if _, err := await(request.String()); !errors.Is(err, http.ErrTooManyRedirects) || len(request.RedirectHistory) != 2 {
+		t.Fatalf("expected too many redirects, got %v", err)
+	}
+}
+
+func TestRedirectCrossOriginPolicy(t *testing.T) {
+	origin, _ := redirectServer(t, false)
+	other, seen := redirectServer(t, false)
+	send := func(policy *http.RedirectPolicy) error {
+		*seen = nil
+		_, err := await(http.NewRequest(func(request http.Request) {
+			request.URL = origin.URL + "/away?to=" + other.URL + "/done"
+			request.CustomizedHeaderList = [][]string{{"Authorization", "Bearer secret"}}
+			request.Redirect = policy
+		}).String())
+		return err
+	}
+	if err := send(nil); err != nil || (*seen)[0] != "GET /done  " {
+		t.Fatalf("authorization leaked across origins: %q %v", *seen, err)
+	}
+	keep := false
+	if err := send(&http.RedirectPolicy{StripAuthorization: &keep}); err != nil || (*seen)[0] != "GET /done  Bearer secret" {
+		t.Fatalf("authorization not kept: %q %v", *seen, err)
+	}
+	secure, _ := redirectServer(t, true)
+	block := true
+	insecure := true
+	_, err := await(http.NewRequest(func(request http.Request) {
+		request.URL = secure.URL + "/away?to=" + other.URL + "/done"
+		request.InsecureSkipVerify = &insecure
+		request.Redirect = &http.RedirectPolicy{BlockDowngrade: &block}
+	}).String())
+	if !errors.Is(err, http.ErrRedirectBlocked) {
+		t.Fatalf("expected downgrade to be blocked, got %v", err)
+	}
+	_, err = await(http.NewRequest(func(request http.Request) {
+		request.URL = secure.URL + "/away?to=" + other.URL + "/done"
+		request.InsecureSkipVerify = &insecure
+		request.Redirect = &http.RedirectPolicy{SameScheme: &block}
+	}).String())
+	if !errors.Is(err, http.ErrRedirectBlocked) {
+		t.Fatalf("expected scheme change to be blocked, got %v", err)
+	}
+}
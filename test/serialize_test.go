@@ -0,0 +1,154 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"github.com/TelephoneTan/GoHTTPRequest/net/http"
+	"github.com/TelephoneTan/GoHTTPRequest/net/http/method"
+	"github.com/TelephoneTan/GoPromise/async/promise"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func compact(t *testing.T, s string) string {
+	buffer := &bytes.Buffer{}
+	if err := json.Compact(buffer, []byte(s)); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.String()
+}
+
+func TestSerializeGolden(t *testing.T) {
+	mock := http.NewMockTransport()
+	route := mock.On(http.MatchMethod(method.POST), http.MatchURL("https://example.com/upload"), http.MatchBody("file body\n")).
+		Respond(http.MockResponse{
+			StatusCode: 201,
+			Header:     map[string][]string{"Content-Type": {"application/json"}, "X-Multi": {"a", "b"}},
+			Body:       []byte(`{"ok":true}`),
+		})
+	file, err := os.Open(filepath.Join("testdata", "upload.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag := "serialize-golden"
+	request := http.NewRequest(func(request http.Request) {
+		request.Method = method.POST
+		request.URL = "https://example.com/upload?b=2&a=1"
+		request.RequestFile = file
+		request.CustomizedHeaderList = [][]string{{"X-Trace", "1"}}
+		request.CookieJar = http.NewCookieJar(tag)
+		request.SetCookies = [][]string{{"https://example.com/", "session=abc; Path=/; Domain=example.com; Expires=Fri, 01 Jan 2100 00:00:00 GMT; Secure; HttpOnly"}}
+		request.Transport = mock
+	})
+	if _, err = await(request.ByteSlice()); err != nil {
+		t.Fatal(err)
+	}
+	request.Timing = nil
+	serialized, err := request.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	testdata, err := filepath.Abs("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	quoted, _ := json.Marshal(testdata)
+	testdata = strings.Trim(string(quoted), `"`)
+	portable := strings.ReplaceAll(serialized, testdata, "$TESTDATA")
+	golden := filepath.Join("testdata", "request.golden.json")
+	if *update {
+		indented := &bytes.Buffer{}
+		_ = json.Indent(indented, []byte(portable), "", "  ")
+		if err = os.WriteFile(golden, append(indented.Bytes(), '\n'), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if portable != compact(t, string(want)) {
+		t.Fatalf("serialized request differs from golden file:\n%s", portable)
+	}
+	restored, err := http.NewRequest(func(request http.Request) {
+		request.Transport = mock
+	}).Deserialize(strings.ReplaceAll(string(want), "$TESTDATA", testdata))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, err := restored.Serialize(); err != nil || again != serialized {
+		t.Fatalf("round trip differs:\n%s\n%v", again, err)
+	}
+	if restored.StatusCode != 201 || strings.Join(restored.ResponseHeaderMap["X-Multi"], ",") != "a,b" || restored.RequestFile == nil {
+		t.Fatalf("unexpected restored request %+v", restored)
+	}
+	resent := restored.Clone()
+	if res, err := await(resent.String()); err != nil || res.Result != `{"ok":true}` || route.Calls() != 2 {
+		t.Fatalf("resend failed: %v %v", res.Result, err)
+	}
+}
+
+func TestSerializeStreamingBodyAndErrors(t *testing.T) {
+	codec := http.FileBodyCodec{Dir: t.TempDir()}
+	body := strings.NewReader("streamed")
+	request := http.NewRequest(func(request http.Request) {
+		request.URL = "https://example.com/"
+		request.RequestBody = body
+		request.BodyCodec = codec
+	})
+	serialized, err := request.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if request.EncodedURL != "" || request.RequestBody != body {
+		t.Fatalf("Serialize mutated the request: %q %v", request.EncodedURL, request.RequestBody)
+	}
+	restored, err := http.NewRequest(func(request http.Request) {
+		request.BodyCodec = codec
+	}).Deserialize(serialized)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range []http.Request{request, restored} {
+		buffer := &bytes.Buffer{}
+		if _, err = buffer.ReadFrom(r.RequestBody); err != nil || buffer.String() != "streamed" {
+			t.Fatalf("unexpected body %q %v", buffer.String(), err)
+		}
+	}
+	unseekable := http.NewRequest(func(request http.Request) {
+		request.URL = "https://example.com/"
+		request.RequestBody = io.LimitReader(strings.NewReader("streamed"), 8)
+	})
+	if _, err = unseekable.Serialize(); !errors.Is(err, http.ErrUnserializable) {
+		t.Fatalf("expected unserializable body, got %v", err)
+	}
+	if _, err = http.NewRequest().Deserialize(`{"Version":99}`); !errors.Is(err, http.ErrUnsupportedVersion) {
+		t.Fatalf("expected version error, got %v", err)
+	}
+	if _, err = http.NewRequest().Deserialize(`{"ResponseHeaderMap":"bad"}`); err == nil {
+		t.Fatal("expected malformed header error")
+	}
+	semaphore := promise.NewSemaphore(1)
+	request = http.NewRequest(func(request http.Request) {
+		request.URL = "https://example.com/"
+		request.RequestSemaphore = semaphore
+	})
+	if _, err = request.Serialize(); !errors.Is(err, http.ErrUnserializable) {
+		t.Fatalf("expected unserializable semaphore, got %v", err)
+	}
+	http.RegisterSemaphore("serialize-test", semaphore)
+	defer http.RegisterSemaphore("serialize-test", nil)
+	if serialized, err = request.Serialize(); err != nil {
+		t.Fatal(err)
+	}
+	if restored, err = http.NewRequest().Deserialize(serialized); err != nil || restored.RequestSemaphore != semaphore {
+		t.Fatalf("semaphore not restored: %v", err)
+	}
+}
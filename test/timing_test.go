@@ -0,0 +1,37 @@
+package test
+
+import (
+	"encoding/json"
+	"github.com/TelephoneTan/GoHTTPRequest/net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTiming(t *testing.T) {
+	server := serve(strings.Repeat("x", 1024))
+	defer server.Close()
+	request := http.NewRequest(func(request http.Request) {
+		request.URL = server.URL
+	})
+	if _, err := await(request.ByteSlice()); err != nil {
+		t.Fatal(err)
+	}
+	timing := request.Timing
+	if timing == nil || timing.RemoteAddress != strings.TrimPrefix(server.URL, "http://") || timing.Total <= 0 || timing.TimeToFirstByte <= 0 || timing.TimeToFirstByte > timing.Total {
+		t.Fatalf("unexpected timing %+v", timing)
+	}
+	serialized, err := request.Serialize()
+	if err != nil || !strings.Contains(serialized, `"RemoteAddress":"`+timing.RemoteAddress+`"`) {
+		t.Fatalf("timing missing from serialized request: %s %v", serialized, err)
+	}
+	phase := http.PreciseDuration(250 * time.Microsecond)
+	bs, err := json.Marshal(&phase)
+	if err != nil || string(bs) != "0.25" {
+		t.Fatalf("sub-millisecond phase serialized as %s %v", bs, err)
+	}
+	var decoded http.PreciseDuration
+	if err := json.Unmarshal(bs, &decoded); err != nil || decoded != phase {
+		t.Fatalf("sub-millisecond phase decoded as %v %v", time.Duration(decoded), err)
+	}
+}
@@ -0,0 +1,74 @@
+package test
+
+import (
+	"context"
+	"github.com/TelephoneTan/GoHTTPRequest/net/http"
+	gohttp "net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTracingPropagation(t *testing.T) {
+	var traceParents, traceStates []string
+	server := httptest.NewServer(gohttp.HandlerFunc(func(w gohttp.ResponseWriter, r *gohttp.Request) {
+		traceParents = append(traceParents, r.Header.Get("traceparent"))
+		traceStates = append(traceStates, r.Header.Get("tracestate"))
+		if r.URL.Path == "/redirect" {
+			gohttp.Redirect(w, r, "/final", gohttp.StatusFound)
+			return
+		}
+		w.WriteHeader(gohttp.StatusTeapot)
+	}))
+	defer server.Close()
+	parent, err := http.ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "vendor=value")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracer := &http.InMemoryTracer{}
+	request := http.NewRequest(func(request http.Request) {
+		request.URL = server.URL + "/redirect"
+		request.Context = http.ContextWithSpanContext(context.Background(), parent)
+		request.Tracer = tracer
+	})
+	if _, err := await(request.Send()); err != nil {
+		t.Fatal(err)
+	}
+	spans := tracer.Spans()
+	if len(spans) != 3 || len(traceParents) != 2 {
+		t.Fatalf("unexpected spans %+v", spans)
+	}
+	root, first, second := spans[0], spans[1], spans[2]
+	if root.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || root.ParentSpanID != "00f067aa0ba902b7" || !root.Ended || len(root.Errors) != 1 || root.Attributes["http.response.status_code"] != 418 {
+		t.Fatalf("unexpected request span %+v", root)
+	}
+	for i, attempt := range []http.RecordedSpan{first, second} {
+		if attempt.ParentSpanID != root.SpanID || attempt.TraceID != root.TraceID || !attempt.Ended {
+			t.Fatalf("unexpected attempt span %+v", attempt)
+		}
+		if want := "00-" + attempt.TraceID + "-" + attempt.SpanID + "-01"; traceParents[i] != want || traceStates[i] != "vendor=value" {
+			t.Fatalf("got traceparent %q tracestate %q, want %q", traceParents[i], traceStates[i], want)
+		}
+	}
+	if first.Attributes["http.response.status_code"] != 302 || second.Attributes["http.request.resend_count"] != 1 {
+		t.Fatalf("unexpected attempt attributes %+v %+v", first.Attributes, second.Attributes)
+	}
+}
+
+func TestParseTraceParent(t *testing.T) {
+	for _, invalid := range []string{
+		"",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+	} {
+		if _, err := http.ParseTraceParent(invalid, ""); err == nil {
+			t.Fatalf("%q should be invalid", invalid)
+		}
+	}
+	sc, err := http.ParseTraceParent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-future", "")
+	if err != nil || sc.TraceParent() != "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00" {
+		t.Fatalf("unexpected %v %v", sc, err)
+	}
+}